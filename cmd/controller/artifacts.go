@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/controller"
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/transport"
+)
+
+// handleArtifacts serves /v1/jobs/{id}/artifacts[/{name}]: GET lists or downloads, PUT uploads
+func handleArtifacts(w http.ResponseWriter, r *http.Request, store *controller.Store, artifacts *controller.Artifacts) {
+	jobID := jobIDFromPath(r.URL.Path)
+	_, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/jobs/"), "/")
+	_, name, _ := strings.Cut(rest, "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, artifacts.List(jobID))
+	case name != "" && r.Method == http.MethodPut:
+		if status, msg := authorizeArtifactPut(store, jobID, r.Header.Get(transport.EngineIDHeader)); status != 0 {
+			http.Error(w, msg, status)
+			return
+		}
+		artifact, err := artifacts.Put(jobID, name, r.Header.Get("Content-Type"), r.Body)
+		switch {
+		case errors.Is(err, controller.ErrArtifactExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, controller.ErrArtifactTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			writeJSON(w, http.StatusCreated, artifact)
+		}
+	case name != "" && r.Method == http.MethodGet:
+		artifact, body, ok := artifacts.Open(jobID, name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		defer body.Close()
+
+		contentType := artifact.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(artifact.Size, 10))
+		w.Header().Set("X-Checksum-Sha256", artifact.SHA256)
+		io.Copy(w, body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorizeArtifactPut returns the status to reject an upload with, or 0 to accept it. Once a job
+// exists only the engine running it may add artifacts; before that operators stage its inputs
+func authorizeArtifactPut(store *controller.Store, jobID, engineID string) (int, string) {
+	if !store.Exists(jobID) {
+		if engineID != "" {
+			return http.StatusNotFound, fmt.Sprintf("job %s not found", jobID)
+		}
+		return 0, ""
+	}
+
+	engine, ok := store.ClaimedBy(jobID)
+	if !ok || engineID != engine {
+		return http.StatusForbidden, fmt.Sprintf("job %s is not running on this engine", jobID)
+	}
+
+	return 0, ""
+}
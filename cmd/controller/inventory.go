@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/inventory"
+)
+
+// loadInventories merges every comma-separated inventory file into inv
+func loadInventories(inv *inventory.Inventory, paths string) error {
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		snap, err := inventory.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := inv.Merge(snap); err != nil {
+			return fmt.Errorf("inventory %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// handleInventory serves the whole inventory: GET returns it, POST merges a JSON snapshot,
+// YAML document (application/yaml) or INI file (text/plain) into it
+func handleInventory(w http.ResponseWriter, r *http.Request, inv *inventory.Inventory) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, inv.Snapshot())
+	case http.MethodPost:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("read inventory payload: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		var snap inventory.Snapshot
+		switch contentType := r.Header.Get("Content-Type"); {
+		case strings.Contains(contentType, "yaml"):
+			snap, err = inventory.ParseYAML(data)
+		case strings.HasPrefix(contentType, "text/plain"):
+			snap, err = inventory.ParseINI(data)
+		default:
+			err = json.Unmarshal(data, &snap)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid inventory payload: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := inv.Merge(snap); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, inv.Snapshot())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleInventoryItem serves /v1/inventory/hosts/{name} and /v1/inventory/groups/{name}[/hosts]
+func handleInventoryItem(w http.ResponseWriter, r *http.Request, inv *inventory.Inventory) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/inventory/"), "/")
+	if len(parts) < 2 || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	kind, name := parts[0], parts[1]
+
+	switch {
+	case kind == "hosts" && len(parts) == 2:
+		handleInventoryHost(w, r, inv, name)
+	case kind == "groups" && len(parts) == 2:
+		handleInventoryGroup(w, r, inv, name)
+	case kind == "groups" && len(parts) == 3 && parts[2] == "hosts" && r.Method == http.MethodGet:
+		members, err := inv.GroupHosts(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, members)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func handleInventoryHost(w http.ResponseWriter, r *http.Request, inv *inventory.Inventory, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		host, ok := inv.Host(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, host)
+	case http.MethodPut:
+		var host inventory.Host
+		if err := json.NewDecoder(r.Body).Decode(&host); err != nil {
+			http.Error(w, fmt.Sprintf("invalid host payload: %v", err), http.StatusBadRequest)
+			return
+		}
+		host.Name = name
+		if err := inv.PutHost(host); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, host)
+	case http.MethodDelete:
+		if !inv.DeleteHost(name) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func handleInventoryGroup(w http.ResponseWriter, r *http.Request, inv *inventory.Inventory, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		group, ok := inv.Group(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, group)
+	case http.MethodPut:
+		var group inventory.Group
+		if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+			http.Error(w, fmt.Sprintf("invalid group payload: %v", err), http.StatusBadRequest)
+			return
+		}
+		group.Name = name
+		if err := inv.PutGroup(group); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, group)
+	case http.MethodDelete:
+		if !inv.DeleteGroup(name) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -5,43 +5,120 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/controller"
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/inventory"
 	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/transport"
 )
 
 func main() {
 	listen := flag.String("listen", ":8080", "controller address")
+	queues := flag.String("queues", "", "comma-separated named queues with weights, e.g. urgent=10,bulk=1")
+	inventoryPaths := flag.String("inventory", "", "comma-separated YAML or INI inventory files to load at startup")
+	artifactDir := flag.String("artifact-dir", filepath.Join(os.TempDir(), "orchestrator-artifacts"), "directory for job artifacts such as full output")
+	maxArtifact := flag.Int64("max-artifact-bytes", 1<<30, "largest artifact accepted, in bytes")
+	artifactRetention := flag.Duration("artifact-retention", 7*24*time.Hour, "remove a job's artifacts this long after its last upload; 0 keeps them")
+	maxLogs := flag.Int("max-log-bytes", controller.DefaultMaxLogBytes, "live output kept per job, in bytes; older output is dropped first")
 	flag.Parse()
 
 	store := controller.NewStore()
+	store.SetLogLimit(*maxLogs)
+	if err := configureQueues(store, *queues); err != nil {
+		log.Fatalf("configure queues: %v", err)
+	}
+
+	inv := inventory.New()
+	if err := loadInventories(inv, *inventoryPaths); err != nil {
+		log.Fatalf("load inventory: %v", err)
+	}
+	store.SetResolver(inv)
+
+	artifacts, err := controller.NewArtifacts(*artifactDir, *maxArtifact)
+	if err != nil {
+		log.Fatalf("artifacts: %v", err)
+	}
+	artifacts.SetRetention(*artifactRetention)
+
+	templates := controller.NewTemplates(func(name string) bool {
+		_, ok := inv.Host(name)
+		return ok
+	}, inv.HostVars)
+
+	scheduler := controller.NewScheduler(store)
+	workflows := controller.NewWorkflows(store)
+	fanouts := controller.NewFanOuts(store)
+	shells := controller.NewShells(artifacts)
+
+	// Promote scheduled jobs, expire stale ones, fire cron schedules and advance
+	// workflows and fan-outs and prune expired artifacts in the background
+	stop := make(chan struct{})
+	go store.RunTimers(stop)
+	go scheduler.Run(stop)
+	go workflows.Run(stop)
+	go fanouts.Run(stop)
+	go artifacts.Run(stop)
+
 	mux := http.NewServeMux()
 
-	// POST /v1/jobs -> user uploads a job definition
+	// POST /v1/jobs -> user uploads a job definition; jobs reading artifacts need them stored first.
+	// Shell jobs answer with the operator token their shell attaches with
+	// GET /v1/jobs?status=&queue=&host=&limit= -> list job summaries
 	mux.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
+		switch r.Method {
+		case http.MethodPost:
+			handleSubmit(w, r, store, fanouts, artifacts, shells)
+		case http.MethodGet:
+			handleList(w, r, store)
+		default:
 			http.NotFound(w, r)
-			return
 		}
-		handleSubmit(w, r, store)
 	})
 
 	// GET /v1/jobs/{id} -> user polls status/result
+	// GET /v1/jobs/{id}/summary -> compact status view
 	// POST /v1/jobs/{id}/results -> engine posts execution result
+	// POST /v1/jobs/{id}/cancel -> user cancels a queued or running job
+	// GET /v1/jobs/{id}/logs?offset=N -> user reads live output
+	// POST /v1/jobs/{id}/logs -> engine streams output; response carries the cancel flag
+	// GET /v1/jobs/{id}/artifacts -> list stored files
+	// PUT/GET /v1/jobs/{id}/artifacts/{name} -> upload or download one file; uploads are
+	// write-once and, once the job exists, only accepted from the engine running it
+	// GET /v1/jobs/{id}/shell -> operator attaches to a shell job over a WebSocket with its token
+	// GET /v1/jobs/{id}/shell/engine -> the engine running the shell attaches the other end
 	mux.HandleFunc("/v1/jobs/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/results") {
-			handleResult(w, r, store)
-			return
+		_, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/jobs/"), "/")
+		if r.Method != http.MethodGet {
+			store.Heartbeat(r.Header.Get(transport.EngineIDHeader))
 		}
 
-		if r.Method == http.MethodGet {
+		switch {
+		case action == "artifacts" || strings.HasPrefix(action, "artifacts/"):
+			handleArtifacts(w, r, store, artifacts)
+		case (action == "shell" || action == "shell/engine") && r.Method == http.MethodGet:
+			handleShell(w, r, store, shells, action == "shell/engine")
+		case action == "results" && r.Method == http.MethodPost:
+			handleResult(w, r, store, shells)
+		case action == "cancel" && r.Method == http.MethodPost:
+			handleCancel(w, r, store)
+		case action == "logs" && r.Method == http.MethodPost:
+			handleAppendLogs(w, r, store)
+		case action == "logs" && r.Method == http.MethodGet:
+			handleLogs(w, r, store)
+		case action == "summary" && r.Method == http.MethodGet:
+			handleSummary(w, r, store)
+		case action == "" && r.Method == http.MethodGet:
 			handleStatus(w, r, store)
-			return
+		default:
+			http.NotFound(w, r)
 		}
-
-		http.NotFound(w, r)
 	})
 
 	// GET /v1/queue/next -> engine long-polls for the next jobs
@@ -51,7 +128,132 @@ func main() {
 			return
 		}
 
-		handleNext(w, store)
+		handleNext(w, r, store)
+	})
+
+	// GET /v1/engines -> operators check which engines are alive and how busy they are
+	mux.HandleFunc("/v1/engines", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, store.Engines())
+	})
+
+	// GET /v1/queues -> operators inspect queue weights and depth
+	mux.HandleFunc("/v1/queues", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, store.Queues())
+	})
+
+	// POST /v1/schedules -> user registers a recurring job
+	// GET /v1/schedules -> list schedules
+	mux.HandleFunc("/v1/schedules", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handleScheduleCreate(w, r, scheduler)
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, scheduler.List())
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	// GET/DELETE /v1/schedules/{id} -> inspect or remove a schedule
+	// POST /v1/schedules/{id}/pause|resume -> toggle a schedule
+	mux.HandleFunc("/v1/schedules/", func(w http.ResponseWriter, r *http.Request) {
+		handleSchedule(w, r, scheduler)
+	})
+
+	// POST /v1/workflows -> user submits a DAG of jobs
+	// GET /v1/workflows -> list workflow summaries
+	mux.HandleFunc("/v1/workflows", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handleWorkflowSubmit(w, r, workflows)
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, workflows.List())
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	// GET /v1/workflows/{id} -> per-node workflow status
+	mux.HandleFunc("/v1/workflows/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/workflows/")
+		if r.Method != http.MethodGet || id == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		status, ok := workflows.Status(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+
+	// POST /v1/fanouts -> user runs one job template across many hosts in rolling batches
+	// GET /v1/fanouts -> list fan-out summaries
+	mux.HandleFunc("/v1/fanouts", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handleFanOutSubmit(w, r, fanouts)
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, fanouts.List())
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	// GET /v1/fanouts/{id} -> aggregate per-host status
+	mux.HandleFunc("/v1/fanouts/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/fanouts/")
+		if r.Method != http.MethodGet || id == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		status, ok := fanouts.Status(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+
+	// GET/POST /v1/inventory -> read or merge hosts and groups
+	mux.HandleFunc("/v1/inventory", func(w http.ResponseWriter, r *http.Request) {
+		handleInventory(w, r, inv)
+	})
+
+	// GET/PUT/DELETE /v1/inventory/hosts/{name} and /v1/inventory/groups/{name}
+	mux.HandleFunc("/v1/inventory/", func(w http.ResponseWriter, r *http.Request) {
+		handleInventoryItem(w, r, inv)
+	})
+
+	// POST /v1/templates -> store a job template; GET lists them
+	mux.HandleFunc("/v1/templates", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handleTemplatePut(w, r, templates, "")
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, templates.List())
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	// GET/PUT/DELETE /v1/templates/{name}
+	// POST /v1/templates/{name}/render -> validate parameters and return the rendered job
+	mux.HandleFunc("/v1/templates/", func(w http.ResponseWriter, r *http.Request) {
+		handleTemplate(w, r, templates)
 	})
 
 	log.Printf("controller listening on %s", *listen)
@@ -59,24 +261,66 @@ func main() {
 }
 
 // handleSubmit ingests a job, validates it, and queues it for the engine
-func handleSubmit(w http.ResponseWriter, r *http.Request, store *controller.Store) {
+// Jobs targeting an inventory group become a single-batch fan-out tracked under the job ID
+func handleSubmit(w http.ResponseWriter, r *http.Request, store *controller.Store, fanouts *controller.FanOuts, artifacts *controller.Artifacts, shells *controller.Shells) {
 	var job jobs.JobDefinition
 	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
 		http.Error(w, fmt.Sprintf("invalid job payload: %v", err), http.StatusBadRequest)
 		return
 	}
 
+	// Each host of a group runs as <id>-<host>, so inputs must name the job they are stored under.
+	// Shells are attached one at a time with the token only a single-host submit returns
+	if job.TargetGroup != "" {
+		if job.Kind() == jobs.JobShell {
+			http.Error(w, "shell jobs cannot target a group; submit one per host", http.StatusBadRequest)
+			return
+		}
+		if err := job.ValidateSharedInputs(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// Artifacts the job reads must be stored first so engines never claim a job they cannot complete
+	for _, ref := range job.Inputs() {
+		if _, ok := artifacts.Get(ref.Job, ref.Name); !ok {
+			http.Error(w, fmt.Sprintf("artifact %s/%s not found; PUT it to /v1/jobs/%s/artifacts/%s first",
+				ref.Job, ref.Name, ref.Job, ref.Name), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if job.TargetGroup != "" {
+		status, err := fanouts.Submit(jobs.FanOutDefinition{
+			ID:          job.ID,
+			Hosts:       nonEmpty(job.TargetHost),
+			Group:       job.TargetGroup,
+			Template:    job,
+			MaxFailures: math.MaxInt32, // one batch; every host runs regardless of failures
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, status)
+		return
+	}
+
 	if err := store.Enqueue(job); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if job.Type == jobs.JobShell {
+		writeJSON(w, http.StatusAccepted, shellTicket{ID: job.ID, Token: shells.Issue(job.ID)})
+		return
+	}
 
 	w.WriteHeader(http.StatusAccepted)
 }
 
 // handleResult records the result emitted by an engine
-func handleResult(w http.ResponseWriter, r *http.Request, store *controller.Store) {
-	jobID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/jobs/"), "/results")
+func handleResult(w http.ResponseWriter, r *http.Request, store *controller.Store, shells *controller.Shells) {
+	jobID := jobIDFromPath(r.URL.Path)
 	if jobID == "" {
 		http.Error(w, "missing job id", http.StatusBadRequest)
 		return
@@ -90,6 +334,12 @@ func handleResult(w http.ResponseWriter, r *http.Request, store *controller.Stor
 	if result.JobID == "" {
 		result.JobID = jobID
 	}
+	if job, _, ok := store.Definition(result.JobID); ok && job.Type == jobs.JobShell {
+		// The engine cannot see the recording the relay keeps; attach it here
+		if recording, ok := shells.Finish(result); ok {
+			result.Artifacts = append(result.Artifacts, recording)
+		}
+	}
 	if err := store.Complete(result); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -125,9 +375,103 @@ func handleStatus(w http.ResponseWriter, r *http.Request, store *controller.Stor
 	json.NewEncoder(w).Encode(result)
 }
 
+// handleList returns job summaries filtered by query parameters
+func handleList(w http.ResponseWriter, r *http.Request, store *controller.Store) {
+	q := r.URL.Query()
+	filter := controller.JobFilter{
+		Status: jobs.Status(q.Get("status")),
+		Queue:  q.Get("queue"),
+		Host:   q.Get("host"),
+	}
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", raw), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	writeJSON(w, http.StatusOK, store.List(filter))
+}
+
+// jobIDFromPath extracts {id} from /v1/jobs/{id}/...
+func jobIDFromPath(path string) string {
+	id, _, _ := strings.Cut(strings.TrimPrefix(path, "/v1/jobs/"), "/")
+	return id
+}
+
+// handleSummary returns the compact status view of one job
+func handleSummary(w http.ResponseWriter, r *http.Request, store *controller.Store) {
+	summary, ok := store.Summary(jobIDFromPath(r.URL.Path))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// handleCancel withdraws a queued job or asks the engine to abort a running one
+func handleCancel(w http.ResponseWriter, r *http.Request, store *controller.Store) {
+	jobID := jobIDFromPath(r.URL.Path)
+	if _, ok := store.Summary(jobID); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := store.Cancel(jobID, "cancelled by user"); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	summary, _ := store.Summary(jobID)
+	writeJSON(w, http.StatusAccepted, summary)
+}
+
+// handleAppendLogs stores output chunks streamed by the engine
+func handleAppendLogs(w http.ResponseWriter, r *http.Request, store *controller.Store) {
+	var chunks []jobs.LogChunk
+	if err := json.NewDecoder(r.Body).Decode(&chunks); err != nil {
+		http.Error(w, fmt.Sprintf("invalid log payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	cancel, err := store.AppendLogs(jobIDFromPath(r.URL.Path), chunks)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Cancel bool `json:"cancel"`
+	}{Cancel: cancel})
+}
+
+// handleLogs returns streamed output starting at ?offset=
+func handleLogs(w http.ResponseWriter, r *http.Request, store *controller.Store) {
+	offset := 0
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid offset %q", raw), http.StatusBadRequest)
+			return
+		}
+		offset = parsed
+	}
+
+	page, ok := store.Logs(jobIDFromPath(r.URL.Path), offset)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
 // handleNext return the next pending job or 204 No Content when idle
-func handleNext(w http.ResponseWriter, store *controller.Store) {
-	job, ok := store.Next()
+func handleNext(w http.ResponseWriter, r *http.Request, store *controller.Store) {
+	job, ok := store.Next(r.Header.Get(transport.EngineIDHeader))
 	if !ok {
 		w.WriteHeader(http.StatusNoContent)
 		return
@@ -136,3 +480,197 @@ func handleNext(w http.ResponseWriter, store *controller.Store) {
 
 	json.NewEncoder(w).Encode(job)
 }
+
+// configureQueues parses the -queues flag ("name=weight,...") into the store
+func configureQueues(store *controller.Store, spec string) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, rawWeight, found := strings.Cut(entry, "=")
+		weight := 1
+		if found {
+			parsed, err := strconv.Atoi(strings.TrimSpace(rawWeight))
+			if err != nil {
+				return fmt.Errorf("queue %s weight %q: %w", name, rawWeight, err)
+			}
+			weight = parsed
+		}
+
+		if err := store.ConfigureQueue(name, weight); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleScheduleCreate registers a recurring job from a schedule definition
+func handleScheduleCreate(w http.ResponseWriter, r *http.Request, scheduler *controller.Scheduler) {
+	var def jobs.ScheduleDefinition
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		http.Error(w, fmt.Sprintf("invalid schedule payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	status, err := scheduler.Add(def)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, status)
+}
+
+// handleSchedule serves per-schedule reads, deletion and pause/resume actions
+func handleSchedule(w http.ResponseWriter, r *http.Request, scheduler *controller.Scheduler) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/schedules/"), "/")
+	if id == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		status, ok := scheduler.Get(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	case action == "" && r.Method == http.MethodDelete:
+		if !scheduler.Delete(id) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case (action == "pause" || action == "resume") && r.Method == http.MethodPost:
+		status, err := scheduler.SetPaused(id, action == "pause")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleWorkflowSubmit validates a workflow DAG and starts it
+func handleWorkflowSubmit(w http.ResponseWriter, r *http.Request, workflows *controller.Workflows) {
+	var def jobs.WorkflowDefinition
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		http.Error(w, fmt.Sprintf("invalid workflow payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	status, err := workflows.Submit(def)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// handleFanOutSubmit expands a fan-out into per-host jobs and starts the first batch
+func handleFanOutSubmit(w http.ResponseWriter, r *http.Request, fanouts *controller.FanOuts) {
+	var def jobs.FanOutDefinition
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		http.Error(w, fmt.Sprintf("invalid fan-out payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	status, err := fanouts.Submit(def)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// handleTemplatePut stores a template; name overrides the payload name when routed by path
+func handleTemplatePut(w http.ResponseWriter, r *http.Request, templates *controller.Templates, name string) {
+	var tmpl jobs.JobTemplate
+	if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
+		http.Error(w, fmt.Sprintf("invalid template payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	if name != "" {
+		tmpl.Name = name
+	}
+
+	if err := templates.Put(tmpl); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, tmpl)
+}
+
+// handleTemplate serves per-template reads, updates, deletion and rendering
+func handleTemplate(w http.ResponseWriter, r *http.Request, templates *controller.Templates) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/templates/"), "/")
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		tmpl, ok := templates.Get(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, tmpl)
+	case action == "" && r.Method == http.MethodPut:
+		handleTemplatePut(w, r, templates, name)
+	case action == "" && r.Method == http.MethodDelete:
+		if !templates.Delete(name) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case action == "render" && r.Method == http.MethodPost:
+		var req struct {
+			Params map[string]string `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid render payload: %v", err), http.StatusBadRequest)
+			return
+		}
+		if _, ok := templates.Get(name); !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		job, err := templates.Render(name, req.Params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// nonEmpty wraps a single optional value into a slice
+func nonEmpty(v string) []string {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+
+	return []string{v}
+}
+
+// writeJSON encodes v with the given status code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/controller"
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/transport"
+)
+
+// shellTicket answers the submit of a shell job; Token must accompany the operator's attach
+type shellTicket struct {
+	ID    string `json:"id"`
+	Token string `json:"shell_token"`
+}
+
+// handleShell upgrades /v1/jobs/{id}/shell[/engine] to a WebSocket and joins it to the job's
+// relay. The operator may attach while the job waits for an engine, presenting the token issued
+// on submit; the engine only once it runs the job, and only the engine that claimed it
+func handleShell(w http.ResponseWriter, r *http.Request, store *controller.Store, shells *controller.Shells, engine bool) {
+	job, status, ok := store.Definition(jobIDFromPath(r.URL.Path))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if job.Type != jobs.JobShell {
+		http.Error(w, fmt.Sprintf("job %s is not a shell job", job.ID), http.StatusBadRequest)
+		return
+	}
+
+	role := controller.ShellOperator
+	ready := status == jobs.StatusPending || status == jobs.StatusRunning
+	if engine {
+		role = controller.ShellEngine
+		ready = status == jobs.StatusRunning
+	}
+	if engine && ready {
+		if claimant, ok := store.ClaimedBy(job.ID); !ok || claimant != r.Header.Get(transport.EngineIDHeader) {
+			http.Error(w, fmt.Sprintf("job %s is not running on this engine", job.ID), http.StatusForbidden)
+			return
+		}
+	} else if !engine && !shells.Authorized(job.ID, r.Header.Get(transport.ShellTokenHeader)) {
+		http.Error(w, fmt.Sprintf("job %s: missing or wrong shell token", job.ID), http.StatusForbidden)
+		return
+	}
+	if !ready {
+		http.Error(w, fmt.Sprintf("job %s is %s", job.ID, status), http.StatusConflict)
+		return
+	}
+
+	conn, err := transport.UpgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	if err := shells.Attach(job, role, conn); err != nil {
+		log.Printf("shell %s: %v", job.ID, err)
+	}
+}
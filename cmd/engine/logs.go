@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/transport"
+)
+
+// logFlushInterval is how often buffered output is shipped; it also bounds cancellation latency
+const logFlushInterval = time.Second
+
+// logShipper buffers live output per job until the next flush to the controller
+type logShipper struct {
+	mu     sync.Mutex
+	chunks map[string][]jobs.LogChunk
+}
+
+func newLogShipper() *logShipper {
+	return &logShipper{chunks: make(map[string][]jobs.LogChunk)}
+}
+
+// write is installed as the executor's OutputSink
+func (l *logShipper) write(jobID, stream string, p []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.chunks[jobID] = append(l.chunks[jobID], jobs.LogChunk{
+		Stream: stream,
+		Data:   string(p),
+		Time:   time.Now().UTC(),
+	})
+}
+
+func (l *logShipper) drain(jobID string) []jobs.LogChunk {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	chunks := l.chunks[jobID]
+	delete(l.chunks, jobID)
+
+	return chunks
+}
+
+// follow ships output every interval until done is closed, then flushes what is left.
+// When the controller reports a cancellation request, cancel aborts the running job
+func (l *logShipper) follow(jobID string, tr *transport.HTTPTransport, cancel context.CancelFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			if chunks := l.drain(jobID); len(chunks) > 0 {
+				if _, err := tr.AppendLogs(jobID, chunks); err != nil {
+					log.Printf("job %s log flush failed: %v", jobID, err)
+				}
+			}
+			return
+		case <-ticker.C:
+			cancelled, err := tr.AppendLogs(jobID, l.drain(jobID))
+			if err != nil {
+				log.Printf("job %s log flush failed: %v", jobID, err)
+				continue
+			}
+			if cancelled {
+				log.Printf("job %s cancellation requested by controller", jobID)
+				cancel()
+			}
+		}
+	}
+}
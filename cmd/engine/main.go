@@ -31,6 +31,10 @@ type TransportConfig struct {
 	ControllerURL       string `yaml:"controller_url"`
 	PollIntervalSeconds int    `yaml:"poll_interval_seconds"`
 	HTTPTimeoutSeconds  int    `yaml:"http_timeout_seconds"`
+	// EngineID names this engine in the controller's health view; defaults to hostname-pid
+	EngineID string `yaml:"engine_id"`
+	// ArtifactIdleTimeoutSeconds aborts artifact uploads and downloads that move no data for that long
+	ArtifactIdleTimeoutSeconds int `yaml:"artifact_idle_timeout_seconds"`
 }
 
 // ExecutionConfig owns everything related to remote execution policy
@@ -40,8 +44,34 @@ type ExecutionConfig struct {
 	DialTimeoutSeconds int `yaml:"dial_timeout_seconds"`
 	//JobTimeoutSeconds bounds the entire remote execution (command + streaming output)
 	JobTimeoutSeconds int `yaml:"job_timeout_seconds"`
-	//HostKeyFingerprints pins trusted server keys (map keyed by host or host:port string)
+	//HostKeyFingerprints pins trusted server keys (map keyed by host:port, host or inventory host name)
 	HostKeyFingerprints map[string]string `yaml:"host_key_fingerprints"`
+	// KillGraceSeconds is how long a timed-out command gets after SIGTERM before SIGKILL
+	KillGraceSeconds int `yaml:"kill_grace_seconds"`
+	// MaxOutputBytes caps stdout and stderr each for every job; jobs may only lower it
+	MaxOutputBytes int64 `yaml:"max_output_bytes"`
+	// SpoolDir holds full output of upload_output jobs until it is sent to the controller
+	SpoolDir string `yaml:"spool_dir"`
+	// AllowedPaths lists remote directories upload and download jobs may touch
+	AllowedPaths []string `yaml:"allowed_paths"`
+	// ScriptDir is the remote directory scripts with file delivery are written to
+	ScriptDir string `yaml:"script_dir"`
+	// BecomeUsers lists the accounts jobs may switch to with sudo or su
+	BecomeUsers []string `yaml:"become_users"`
+	// AllowShell permits interactive shell jobs; they end after ShellIdleTimeoutSeconds without
+	// operator input and after ShellMaxSeconds in total, in place of job_timeout_seconds
+	AllowShell              bool `yaml:"allow_shell"`
+	ShellIdleTimeoutSeconds int  `yaml:"shell_idle_timeout_seconds"`
+	ShellMaxSeconds         int  `yaml:"shell_max_seconds"`
+	// AllowExpect permits jobs that answer terminal prompts with expect steps
+	AllowExpect bool `yaml:"allow_expect"`
+	// PoolConnections reuses SSH connections between jobs for the same host, user and credentials,
+	// with up to PoolMaxSessions jobs per connection; idle ones close after PoolIdleSeconds and are
+	// checked with keepalives every PoolKeepaliveSeconds
+	PoolConnections      bool `yaml:"pool_connections"`
+	PoolMaxSessions      int  `yaml:"pool_max_sessions"`
+	PoolIdleSeconds      int  `yaml:"pool_idle_seconds"`
+	PoolKeepaliveSeconds int  `yaml:"pool_keepalive_seconds"`
 }
 
 func main() {
@@ -62,11 +92,30 @@ func main() {
 		BaseURL:      strings.TrimRight(cfg.Transport.ControllerURL, "/"),
 		Client:       httpClient,
 		PollInterval: pollInterval(cfg.Transport.PollIntervalSeconds),
+		EngineID:     engineID(cfg.Transport.EngineID),
+
+		ArtifactIdleTimeout: timeoutOrDefault(cfg.Transport.ArtifactIdleTimeoutSeconds, transport.DefaultArtifactIdleTimeout),
 	}
 
+	logs := newLogShipper()
 	exec := &executor.SSHExecutor{
 		AllowedCommands: buildAllowlist(cfg.Execution.AllowedCommands),
 		DialTimeout:     timeoutOrDefault(cfg.Execution.DialTimeoutSeconds, 10*time.Second),
+		OutputSink:      logs.write,
+		KillGrace:       timeoutOrDefault(cfg.Execution.KillGraceSeconds, 5*time.Second),
+		MaxOutputBytes:  cfg.Execution.MaxOutputBytes,
+		ArtifactSink:    tr.UploadArtifact,
+		SpoolDir:        cfg.Execution.SpoolDir,
+		ArtifactSource:  tr.DownloadArtifact,
+		AllowedPaths:    cfg.Execution.AllowedPaths,
+		ScriptDir:       cfg.Execution.ScriptDir,
+		BecomeUsers:     cfg.Execution.BecomeUsers,
+		ShellAttach: func(ctx context.Context, jobID string) (executor.ShellConn, error) {
+			return tr.AttachShell(ctx, jobID)
+		},
+		AllowShell:       cfg.Execution.AllowShell,
+		ShellIdleTimeout: timeoutOrDefault(cfg.Execution.ShellIdleTimeoutSeconds, executor.DefaultShellIdleTimeout),
+		AllowExpect:      cfg.Execution.AllowExpect,
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -82,6 +131,16 @@ func main() {
 		cancel()
 	}()
 
+	if cfg.Execution.PoolConnections {
+		exec.Pool = &executor.Pool{
+			MaxSessions: cfg.Execution.PoolMaxSessions,
+			IdleTimeout: timeoutOrDefault(cfg.Execution.PoolIdleSeconds, executor.DefaultPoolIdleTimeout),
+			Keepalive:   timeoutOrDefault(cfg.Execution.PoolKeepaliveSeconds, executor.DefaultPoolKeepalive),
+		}
+		// Evict idle connections and keep the rest checked; closes them all on shutdown
+		go exec.Pool.Run(stop)
+	}
+
 	log.Printf("engine start: polling controller %s for jobs", cfg.Transport.ControllerURL)
 
 	for {
@@ -95,8 +154,19 @@ func main() {
 			continue
 		}
 
-		jobCtx, jobCancel := context.WithTimeout(ctx, timeoutOrDefault(cfg.Execution.JobTimeoutSeconds, 2*time.Minute))
+		jobCtx, jobCancel := context.WithTimeout(ctx, jobTimeout(*job, cfg.Execution))
+
+		// Stream output while the job runs; the heartbeat also delivers user cancellations
+		execDone := make(chan struct{})
+		shipped := make(chan struct{})
+		go func() {
+			logs.follow(job.ID, tr, jobCancel, execDone)
+			close(shipped)
+		}()
+
 		result, execErr := exec.Execute(jobCtx, *job, buildCredentials(*job, cfg.Execution))
+		close(execDone)
+		<-shipped
 		jobCancel()
 		if execErr != nil {
 			// Execute already encoded errors into the result struct; we still log
@@ -151,6 +221,20 @@ func timeoutOrDefault(seconds int, fallback time.Duration) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// jobTimeout honours a per-job timeout but never exceeds the engine's job_timeout_seconds,
+// or shell_max_seconds for interactive shells
+func jobTimeout(job jobs.JobDefinition, execCfg ExecutionConfig) time.Duration {
+	limit := timeoutOrDefault(execCfg.JobTimeoutSeconds, 2*time.Minute)
+	if job.Kind() == jobs.JobShell {
+		limit = timeoutOrDefault(execCfg.ShellMaxSeconds, 4*time.Hour)
+	}
+	if job.TimeoutSeconds <= 0 {
+		return limit
+	}
+
+	return min(time.Duration(job.TimeoutSeconds)*time.Second, limit)
+}
+
 // buildAllowlist turns the slice into a constant-time lookup map
 func buildAllowlist(commands []string) map[string]struct{} {
 	if len(commands) == 0 {
@@ -179,19 +263,42 @@ func stopped(stop <-chan struct{}) bool {
 }
 
 // buildCredentials trusts the per-job credential bundle provided from the CLI
+// Fingerprints pinned in engine.yaml win over the inventory-provided one on the job. The controller has
+// already resolved inventory names, so pins are looked up by the address dialed, then by inventory name
 func buildCredentials(job jobs.JobDefinition, execCfg ExecutionConfig) executor.SSHCredentials {
 	address := fmt.Sprintf("%s:%d", job.TargetHost, effectivePort(job.TargetPort))
-	fpKey := job.TargetHost
-	if job.TargetPort != 0 {
-		fpKey = fmt.Sprintf("%s:%d", job.TargetHost, job.TargetPort)
+
+	var fingerprint string
+	for _, key := range []string{address, job.TargetHost, job.Metadata["inventory_host"]} {
+		if fp := execCfg.HostKeyFingerprints[key]; key != "" && fp != "" {
+			fingerprint = fp
+			break
+		}
+	}
+	if fingerprint == "" {
+		fingerprint = job.HostKeyFingerprint
 	}
 
 	return executor.SSHCredentials{
 		Address:     address,
 		Username:    job.Credentials.Username,
 		Password:    job.Credentials.Password,
-		Fingerprint: execCfg.HostKeyFingerprints[fpKey],
+		Fingerprint: fingerprint,
+	}
+}
+
+// engineID returns the configured name or a hostname-pid default
+func engineID(configured string) string {
+	if strings.TrimSpace(configured) != "" {
+		return strings.TrimSpace(configured)
 	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "engine"
+	}
+
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
 }
 
 // effectivePort uses 22 as default when omitted
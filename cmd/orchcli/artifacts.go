@@ -0,0 +1,95 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// runArtifacts implements `orchcli artifacts ID` (list) and `orchcli artifacts ID NAME` (download)
+func runArtifacts(args []string) {
+	fs := flag.NewFlagSet("artifacts", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	outPath := fs.String("O", "", "write the artifact to this file instead of stdout")
+	raw := fs.Bool("raw", false, "do not decompress .gz artifacts")
+	rest := parseArgs(fs, args)
+	if len(rest) == 0 || len(rest) > 2 {
+		fail(exitUsage, "usage: orchcli artifacts ID [NAME]")
+	}
+
+	api := conn.api()
+	jobID := rest[0]
+	if len(rest) == 1 {
+		var list []jobs.Artifact
+		if _, err := api.do(http.MethodGet, jobPath(jobID, "artifacts"), nil, &list, http.StatusOK); err != nil {
+			failRequest(err)
+		}
+		emit(conn.output, list, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "NAME\tSIZE\tSHA256")
+			for _, a := range list {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", a.Name, a.Size, a.SHA256)
+			}
+		})
+		return
+	}
+
+	name := rest[1]
+	body, err := api.download(jobPath(jobID, "artifacts", url.PathEscape(name)))
+	if err != nil {
+		failRequest(err)
+	}
+	defer body.Close()
+
+	var src io.Reader = body
+	if !*raw && strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			fail(exitFailure, "decompress %s: %v", name, err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	var dst io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(filepath.Clean(*outPath))
+		if err != nil {
+			fail(exitFailure, "create %s: %v", *outPath, err)
+		}
+		defer f.Close()
+		dst = f
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		fail(exitFailure, "download %s: %v", name, err)
+	}
+}
+
+// download streams a response body without buffering it; the caller closes it
+func (a *apiClient) download(path string) (io.ReadCloser, error) {
+	resp, err := a.client.Get(a.baseURL + path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s not found", path)
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("controller returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+}
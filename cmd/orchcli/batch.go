@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// jobDoc is one job parsed from a file along with where it came from
+type jobDoc struct {
+	job  jobs.JobDefinition
+	file string
+	doc  int // 1-based document index within the file
+	line int // line the document starts on
+	// node is the document's top-level mapping with file line numbers, used to point lint findings at fields
+	node *yaml.Node
+}
+
+func (d jobDoc) position() string {
+	return fmt.Sprintf("%s: document %d (line %d)", d.file, d.doc, d.line)
+}
+
+// loadJobs parses every job in a file. YAML files (.yaml/.yml) may hold several "---" documents,
+// JSON-lines files (.jsonl/.ndjson) hold one job per line, and JSON files hold one object,
+// several concatenated objects or an array of objects
+func loadJobs(path string) ([]jobDoc, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAMLJobs(path, data)
+	case ".jsonl", ".ndjson":
+		return parseJSONLines(path, data)
+	default:
+		return parseJSONJobs(path, data)
+	}
+}
+
+func parseYAMLJobs(path string, data []byte) ([]jobDoc, error) {
+	var docs []jobDoc
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		var node yaml.Node
+		if err := dec.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("%s: document %d: %w", path, i, err)
+		}
+		if len(node.Content) == 0 || node.Content[0].Tag == "!!null" {
+			continue // empty document between separators
+		}
+
+		doc := jobDoc{file: path, doc: i, line: node.Content[0].Line, node: node.Content[0]}
+		if err := node.Decode(&doc.job); err != nil {
+			return nil, fmt.Errorf("%s: %w", doc.position(), err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+func parseJSONLines(path string, data []byte) ([]jobDoc, error) {
+	var docs []jobDoc
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		doc := jobDoc{file: path, doc: len(docs) + 1, line: i + 1, node: nodeFor([]byte(line), i+1)}
+		if err := json.Unmarshal([]byte(line), &doc.job); err != nil {
+			return nil, fmt.Errorf("%s: %w", doc.position(), err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+func parseJSONJobs(path string, data []byte) ([]jobDoc, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var list []json.RawMessage
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, jsonErrorAt(data, err))
+		}
+
+		docs := make([]jobDoc, 0, len(list))
+		searchFrom := 0
+		for i, raw := range list {
+			// Locate each element so errors can point at a line
+			offset := bytes.Index(data[searchFrom:], raw) + searchFrom
+			searchFrom = offset + len(raw)
+
+			line := lineAt(data, int64(offset))
+			doc := jobDoc{file: path, doc: i + 1, line: line, node: nodeFor(raw, line)}
+			if err := json.Unmarshal(raw, &doc.job); err != nil {
+				return nil, fmt.Errorf("%s: %w", doc.position(), err)
+			}
+			docs = append(docs, doc)
+		}
+		return docs, nil
+	}
+
+	var docs []jobDoc
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		start := dec.InputOffset()
+		var job jobs.JobDefinition
+		if err := dec.Decode(&job); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			// Syntax errors carry an offset into the stream, type errors one into the document
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				typeErr.Offset += start
+			}
+			return nil, fmt.Errorf("%s: document %d: %w", path, i, jsonErrorAt(data, err))
+		}
+		// InputOffset sits before any whitespace preceding the document; skip it for the line number
+		for start < int64(len(data)) && strings.ContainsRune(" \t\r\n", rune(data[start])) {
+			start++
+		}
+		line := lineAt(data, start)
+		docs = append(docs, jobDoc{job: job, file: path, doc: i, line: line, node: nodeFor(data[start:dec.InputOffset()], line)})
+	}
+
+	return docs, nil
+}
+
+// nodeFor parses a JSON document as YAML (a superset) so fields carry line numbers,
+// shifting them to where the document starts in its file. It returns nil when that fails
+func nodeFor(raw []byte, firstLine int) *yaml.Node {
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil || len(root.Content) == 0 {
+		return nil
+	}
+
+	var shift func(n *yaml.Node)
+	shift = func(n *yaml.Node) {
+		n.Line += firstLine - 1
+		for _, child := range n.Content {
+			shift(child)
+		}
+	}
+	shift(root.Content[0])
+
+	return root.Content[0]
+}
+
+// jsonErrorAt annotates syntax errors with the line they occurred on
+func jsonErrorAt(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("line %d: %w", lineAt(data, syntaxErr.Offset), err)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("line %d: %w", lineAt(data, typeErr.Offset), err)
+	}
+
+	return err
+}
+
+func lineAt(data []byte, offset int64) int {
+	offset = min(max(offset, 0), int64(len(data)))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// batchOutcome is the submission and, unless detached, final result of one job in a batch
+type batchOutcome struct {
+	doc    jobDoc
+	result jobs.Result
+	err    error
+}
+
+// submitBatch prepares and validates every job before submitting any, then submits them
+// concurrently (bounded by parallel), optionally waits for results, prints a summary and exits
+func submitBatch(conn *connFlags, creds *credFlags, docs []jobDoc, parallel int, detach bool) {
+	targetUser, bundle, err := creds.resolve(conn.settings(), docs[0].job)
+	if err != nil {
+		fail(exitUsage, "credentials: %v", err)
+	}
+	explicitUser := creds.explicitUsername(conn.settings())
+
+	var problems []string
+	seen := make(map[string]string, len(docs))
+	for i := range docs {
+		job := &docs[i].job
+		job.ID = ensureJobID(job.ID)
+		if creds.targetUser != "" || job.TargetUser == "" {
+			job.TargetUser = targetUser
+		}
+		job.Checksum = ensureChecksum(job.ChecksumInput())
+		job.Credentials = jobs.CredentialBundle{
+			Username: firstNonEmpty(explicitUser, job.TargetUser, bundle.Username),
+			Password: bundle.Password,
+		}
+
+		if err := job.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", docs[i].position(), err))
+		}
+		if prev, dup := seen[job.ID]; dup {
+			problems = append(problems, fmt.Sprintf("%s: job id %s already used at %s", docs[i].position(), job.ID, prev))
+		}
+		seen[job.ID] = docs[i].position()
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		fail(exitUsage, "%d of %d jobs invalid; nothing submitted", len(problems), len(docs))
+	}
+
+	outcomes := runBatch(conn.api(), docs, parallel, detach)
+
+	emit(conn.output, batchReport(outcomes, detach), func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tHOST\tSTATUS\tEXIT\tERROR")
+		for _, out := range outcomes {
+			status, exit, errText := "submitted", "-", ""
+			switch {
+			case out.err != nil:
+				status, errText = "error", out.err.Error()
+			case !detach:
+				status, exit, errText = string(out.result.Status), fmt.Sprint(out.result.ExitCode), out.result.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", out.doc.job.ID, out.doc.job.TargetHost, status, exit, errText)
+		}
+	})
+
+	code := exitOK
+	for _, out := range outcomes {
+		switch {
+		case out.err != nil:
+			code = exitUnavailable
+		case !detach && code == exitOK:
+			code = exitCodeFor(out.result)
+		}
+		if code != exitOK {
+			break
+		}
+	}
+	os.Exit(code)
+}
+
+// runBatch submits docs with at most parallel requests in flight and, unless detached, waits for
+// each job's result. Outcomes are in document order
+func runBatch(api *apiClient, docs []jobDoc, parallel int, detach bool) []batchOutcome {
+	outcomes := make([]batchOutcome, len(docs))
+	sem := make(chan struct{}, max(parallel, 1))
+	var wg sync.WaitGroup
+	for i, doc := range docs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			out := batchOutcome{doc: doc}
+			out.err = submitJob(api.client, api.baseURL, doc.job)
+			switch {
+			case out.err != nil || detach:
+			case doc.job.TargetGroup != "":
+				var status jobs.FanOutStatus
+				if status, out.err = awaitFanOut(api, doc.job.ID, false); out.err == nil {
+					out.result = fanOutResult(status)
+				}
+			default:
+				out.result, out.err = awaitResult(api, doc.job.ID)
+			}
+			outcomes[i] = out
+		}()
+	}
+	wg.Wait()
+
+	return outcomes
+}
+
+// batchReport is the machine-readable form of a batch summary
+func batchReport(outcomes []batchOutcome, detach bool) []map[string]any {
+	report := make([]map[string]any, 0, len(outcomes))
+	for _, out := range outcomes {
+		row := map[string]any{
+			"id":       out.doc.job.ID,
+			"host":     out.doc.job.TargetHost,
+			"source":   out.doc.position(),
+			"detached": detach,
+		}
+		if out.err != nil {
+			row["error"] = out.err.Error()
+		} else if !detach {
+			row["result"] = out.result
+		}
+		report = append(report, row)
+	}
+
+	return report
+}
+
+// awaitResult polls quietly until a job reaches a final state
+func awaitResult(api *apiClient, jobID string) (jobs.Result, error) {
+	for {
+		var result jobs.Result
+		code, err := api.do(http.MethodGet, jobPath(jobID), nil, &result, http.StatusOK, http.StatusAccepted)
+		if err != nil {
+			var nf errNotFound
+			if errors.As(err, &nf) {
+				return jobs.Result{}, err
+			}
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		if code == http.StatusOK {
+			return result, nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// positions renders the ID, document index and line of each parsed job
+func positions(docs []jobDoc) string {
+	var out []string
+	for _, d := range docs {
+		out = append(out, fmt.Sprintf("%s@%d:%d", d.job.ID, d.doc, d.line))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestParseJobFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string, []byte) ([]jobDoc, error)
+		data  string
+		want  string
+	}{
+		{
+			name:  "yaml documents",
+			parse: parseYAMLJobs,
+			data:  "id: a\ncommand: uptime\n---\n# comment\nid: b\ncommand: df\n",
+			want:  "a@1:1 b@2:5",
+		},
+		{
+			name:  "empty yaml documents keep their index",
+			parse: parseYAMLJobs,
+			data:  "---\nid: a\n---\n---\nid: c\n",
+			want:  "a@1:2 c@3:5",
+		},
+		{
+			name:  "json lines skip blank lines",
+			parse: parseJSONLines,
+			data:  "{\"id\":\"a\"}\n\n{\"id\":\"b\"}\n",
+			want:  "a@1:1 b@2:3",
+		},
+		{
+			name:  "json array",
+			parse: parseJSONJobs,
+			data:  "[\n  {\"id\": \"a\"},\n\n  {\"id\": \"b\"}\n]\n",
+			want:  "a@1:2 b@2:4",
+		},
+		{
+			name:  "concatenated json objects",
+			parse: parseJSONJobs,
+			data:  "{\"id\": \"a\"}\n\n{\n  \"id\": \"b\"\n}\n",
+			want:  "a@1:1 b@2:3",
+		},
+	}
+	for _, tt := range tests {
+		docs, err := tt.parse("jobs", []byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := positions(docs); got != tt.want {
+			t.Errorf("%s: parsed %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseJobFileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string, []byte) ([]jobDoc, error)
+		data  string
+		want  string
+	}{
+		{"yaml syntax", parseYAMLJobs, "id: a\n---\nid: [b\n", "jobs: document 2:"},
+		{"yaml type", parseYAMLJobs, "id: a\n---\nid: b\ntarget_port: many\n", "jobs: document 2 (line 3)"},
+		{"json lines", parseJSONLines, "{\"id\":\"a\"}\n\n{\"id\":\n", "jobs: document 2 (line 3)"},
+		{"json array syntax", parseJSONJobs, "[\n  {\"id\": \"a\"},\n  {\"id\" \"b\"}\n]", "jobs: line 3"},
+		{"json array type", parseJSONJobs, "[\n  {\"id\": \"a\"},\n  {\"target_port\": \"b\"}\n]", "jobs: document 2 (line 3)"},
+		{"concatenated json syntax", parseJSONJobs, "{\"id\": \"a\"}\n\n{\"id\" \"b\"}\n", "jobs: document 2: line 3"},
+		{"concatenated json type", parseJSONJobs, "{\"id\": \"a\"}\n\n{\"id\": 7}\n", "jobs: document 2: line 3"},
+	}
+	for _, tt := range tests {
+		_, err := tt.parse("jobs", []byte(tt.data))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want prefix %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRunBatchConcurrency(t *testing.T) {
+	tests := []struct {
+		parallel int
+		want     int // most submissions in flight at once
+	}{
+		{0, 1},
+		{1, 1},
+		{3, 3},
+		{10, 6},
+	}
+	const total = 6
+	for _, tt := range tests {
+		var mu sync.Mutex
+		inFlight, most := 0, 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var job jobs.JobDefinition
+			json.NewDecoder(r.Body).Decode(&job)
+
+			mu.Lock()
+			inFlight++
+			most = max(most, inFlight)
+			mu.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+
+			if job.ID == "job-4" {
+				http.Error(w, "queue full", http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}))
+
+		docs := make([]jobDoc, total)
+		for i := range docs {
+			docs[i] = jobDoc{job: jobs.JobDefinition{ID: fmt.Sprintf("job-%d", i)}, file: "jobs.yaml", doc: i + 1}
+		}
+		outcomes := runBatch(&apiClient{client: server.Client(), baseURL: server.URL}, docs, tt.parallel, true)
+		server.Close()
+
+		if most != tt.want {
+			t.Errorf("parallel %d: %d submissions in flight at most, want %d", tt.parallel, most, tt.want)
+		}
+		for i, out := range outcomes {
+			if out.doc.job.ID != docs[i].job.ID {
+				t.Errorf("parallel %d: outcome %d is for %s", tt.parallel, i, out.doc.job.ID)
+			}
+			if failed := out.err != nil; failed != (i == 4) {
+				t.Errorf("parallel %d: %s submitted with error %v", tt.parallel, out.doc.job.ID, out.err)
+			}
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+type command struct {
+	summary string
+	run     func(args []string)
+}
+
+var commands map[string]command
+
+func init() {
+	// Assigned in init because usage() reads the map it is part of
+	commands = map[string]command{
+		"submit":       {"submit a job file and wait for its result (--detach to return immediately)", runSubmit},
+		"status":       {"show the current status of a job", runStatus},
+		"list":         {"list jobs, optionally filtered by status, queue or host", runList},
+		"wait":         {"block until a job finishes and exit with its outcome", runWait},
+		"logs":         {"print a job's output (--follow to stream until it finishes)", runLogs},
+		"cancel":       {"cancel a queued or running job", runCancel},
+		"result":       {"print the final result of a job", runResult},
+		"artifacts":    {"list a job's stored artifacts, or download one with NAME", runArtifacts},
+		"exec":         {"run an ad-hoc command on one or more hosts without a job file", runExec},
+		"script":       {"run a local script on one or more hosts through a remote interpreter", runScript},
+		"shell":        {"open an interactive shell on a host, relayed and recorded by the controller", runShell},
+		"upload":       {"copy a local file to a host over SFTP, with optional mode and owner", runUpload},
+		"download":     {"copy a file from a host over SFTP to a local path", runDownload},
+		"run-template": {"render a controller template with -p key=value and submit it", runTemplate},
+		"top":          {"full-screen live view of queues, engines and jobs; open output or cancel from the keyboard", runTop},
+		"validate":     {"lint job files offline and report problems with file and line", runValidate},
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: orchcli COMMAND [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(os.Stderr, "\nrun 'orchcli COMMAND -h' for command flags")
+}
+
+// connFlags are the connection and output settings shared by every subcommand.
+// Precedence for the controller URL: -controller flag, ORCHCLI_CONTROLLER, profile, built-in default
+type connFlags struct {
+	fs          *flag.FlagSet
+	controller  string
+	timeout     time.Duration
+	output      string
+	profileName string
+
+	loaded  bool
+	profile profile
+}
+
+func (c *connFlags) register(fs *flag.FlagSet) {
+	c.fs = fs
+	fs.StringVar(&c.controller, "controller", "http://localhost:8080", "controller base URL (env ORCHCLI_CONTROLLER)")
+	fs.DurationVar(&c.timeout, "http-timeout", 30*time.Second, "timeout for each controller request")
+	fs.StringVar(&c.output, "o", formatTable, "output format: table, json or yaml")
+	fs.StringVar(&c.profileName, "profile", os.Getenv("ORCHCLI_PROFILE"), "profile from the orchcli profile file (env ORCHCLI_PROFILE)")
+}
+
+// settings loads the selected profile once
+func (c *connFlags) settings() profile {
+	if !c.loaded {
+		p, err := loadProfile(c.profileName)
+		if err != nil {
+			fail(exitUsage, "%v", err)
+		}
+		c.profile, c.loaded = p, true
+	}
+
+	return c.profile
+}
+
+// flagSet reports whether name was passed explicitly on the command line
+func (c *connFlags) flagSet(name string) bool {
+	set := false
+	c.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// api validates the shared settings and returns a controller client
+func (c *connFlags) api() *apiClient {
+	if !validFormat(c.output) {
+		fail(exitUsage, "unknown output format %q", c.output)
+	}
+
+	prof := c.settings()
+	controller := c.controller
+	if !c.flagSet("controller") {
+		if env := os.Getenv("ORCHCLI_CONTROLLER"); env != "" {
+			controller = env
+		} else if prof.Controller != "" {
+			controller = prof.Controller
+		}
+	}
+
+	baseURL, err := normalizeControllerURL(controller)
+	if err != nil {
+		fail(exitUsage, "controller URL invalid: %v", err)
+	}
+
+	transport, err := prof.TLS.transport()
+	if err != nil {
+		fail(exitUsage, "profile tls: %v", err)
+	}
+	token, err := prof.token()
+	if err != nil {
+		fail(exitUsage, "profile token: %v", err)
+	}
+	if token != "" {
+		transport = tokenTransport{token: token, next: transport}
+	}
+
+	return &apiClient{
+		client:  &http.Client{Timeout: c.timeout, Transport: transport},
+		baseURL: baseURL,
+	}
+}
+
+// parseArgs parses flags that may appear before or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(exitUsage)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// requireID returns the single job ID argument of a subcommand
+func requireID(fs *flag.FlagSet, args []string) string {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		fail(exitUsage, "usage: orchcli %s [flags] ID", fs.Name())
+	}
+
+	return args[0]
+}
+
+// apiClient wraps the controller's job endpoints
+type apiClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+// errNotFound distinguishes unknown jobs from transport failures
+type errNotFound struct{ jobID string }
+
+func (e errNotFound) Error() string { return fmt.Sprintf("job %s not found", e.jobID) }
+
+// do issues a request and decodes a JSON reply into out when the status matches want
+func (a *apiClient) do(method, path string, query url.Values, out any, want ...int) (int, error) {
+	u := a.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	for _, code := range want {
+		if resp.StatusCode == code {
+			if out == nil {
+				return resp.StatusCode, nil
+			}
+			return resp.StatusCode, json.Unmarshal(body, out)
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, errNotFound{jobID: strings.Split(strings.TrimPrefix(path, "/v1/jobs/"), "/")[0]}
+	}
+
+	return resp.StatusCode, fmt.Errorf("controller returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func jobPath(jobID string, parts ...string) string {
+	return "/v1/jobs/" + strings.Join(append([]string{url.PathEscape(jobID)}, parts...), "/")
+}
+
+// failRequest exits with a code matching the request error
+func failRequest(err error) {
+	if nf, ok := err.(errNotFound); ok {
+		fail(exitFailure, "%v", nf)
+	}
+	fail(exitUnavailable, "%v", err)
+}
+
+// runSubmit implements `orchcli submit [--detach] FILE...` and the legacy `-job FILE` form.
+// A single job streams through submitAndWait; several jobs are validated up front and submitted as a batch
+func runSubmit(args []string) {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	var creds credFlags
+	creds.register(fs)
+	jobFile := fs.String("job", "", "path to a job file (JSON, YAML or JSON lines)")
+	detach := fs.Bool("detach", false, "print the job ID and return without waiting")
+	parallel := fs.Int("parallel", 4, "maximum concurrent submissions when the files hold several jobs")
+	var secrets stringList
+	fs.Var(&secrets, "secret", "expect secret as NAME=value, or NAME to copy it from the local environment (repeatable)")
+	paths := parseArgs(fs, args)
+
+	if *jobFile != "" {
+		paths = append([]string{*jobFile}, paths...)
+	}
+	if len(paths) == 0 {
+		fail(exitUsage, "usage: orchcli submit [--detach] FILE...")
+	}
+
+	var docs []jobDoc
+	for _, path := range paths {
+		fileDocs, err := loadJobs(path)
+		if err != nil {
+			fail(exitUsage, "load jobs: %v", err)
+		}
+		docs = append(docs, fileDocs...)
+	}
+	if len(docs) == 0 {
+		fail(exitUsage, "no jobs found in %s", strings.Join(paths, ", "))
+	}
+	for _, raw := range secrets {
+		name, value := secretFlag("secret", raw)
+		for i := range docs {
+			if docs[i].job.Secrets == nil {
+				docs[i].job.Secrets = map[string]string{}
+			}
+			docs[i].job.Secrets[name] = value
+		}
+	}
+
+	if len(docs) == 1 {
+		submitAndWait(&conn, &creds, docs[0].job, *detach)
+		return
+	}
+	submitBatch(&conn, &creds, docs, *parallel, *detach)
+}
+
+// runStatus implements `orchcli status ID`
+func runStatus(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	jobID := requireID(fs, parseArgs(fs, args))
+
+	api := conn.api()
+	var summary jobs.JobSummary
+	if _, err := api.do(http.MethodGet, jobPath(jobID, "summary"), nil, &summary, http.StatusOK); err != nil {
+		if _, notFound := err.(errNotFound); notFound {
+			// Group submissions are tracked as fan-outs under the job ID
+			var status jobs.FanOutStatus
+			if _, ferr := api.do(http.MethodGet, fanOutPath(jobID), nil, &status, http.StatusOK); ferr == nil {
+				emit(conn.output, status, func(w *tabwriter.Writer) {
+					fanOutTable(w, status)
+				})
+				return
+			}
+		}
+		failRequest(err)
+	}
+
+	emit(conn.output, summary, func(w *tabwriter.Writer) {
+		summaryTable(w, []jobs.JobSummary{summary})
+	})
+}
+
+// runList implements `orchcli list [-status S] [-queue Q] [-host H] [-limit N]`
+func runList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	status := fs.String("status", "", "only jobs in this status")
+	queue := fs.String("queue", "", "only jobs in this queue")
+	host := fs.String("host", "", "only jobs targeting this host")
+	limit := fs.Int("limit", 50, "maximum number of jobs (0 for all)")
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		fail(exitUsage, "usage: orchcli list [flags]")
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{"status": *status, "queue": *queue, "host": *host} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	query.Set("limit", strconv.Itoa(*limit))
+
+	api := conn.api()
+	var summaries []jobs.JobSummary
+	if _, err := api.do(http.MethodGet, "/v1/jobs", query, &summaries, http.StatusOK); err != nil {
+		failRequest(err)
+	}
+
+	emit(conn.output, summaries, func(w *tabwriter.Writer) {
+		summaryTable(w, summaries)
+	})
+}
+
+// runWait implements `orchcli wait ID`, exiting with the job's outcome
+func runWait(args []string) {
+	fs := flag.NewFlagSet("wait", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	timeout := fs.Duration("timeout", 0, "give up after this long (0 waits forever)")
+	jobID := requireID(fs, parseArgs(fs, args))
+
+	api := conn.api()
+	result, err := pollResult(api.client, api.baseURL, jobID, *timeout)
+	if _, notFound := err.(errNotFound); notFound {
+		waitFanOut(api, conn.output, jobID, err)
+	}
+	if err != nil {
+		if errors.Is(err, errWaitTimeout) {
+			fail(exitNotRun, "%v", err)
+		}
+		fail(exitUnavailable, "wait: %v", err)
+	}
+
+	emit(conn.output, result, func(w *tabwriter.Writer) {
+		resultTable(w, result)
+	})
+	os.Exit(exitCodeFor(result))
+}
+
+// runResult implements `orchcli result ID`
+func runResult(args []string) {
+	fs := flag.NewFlagSet("result", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	jobID := requireID(fs, parseArgs(fs, args))
+
+	api := conn.api()
+	var result jobs.Result
+	code, err := api.do(http.MethodGet, jobPath(jobID), nil, &result, http.StatusOK, http.StatusAccepted)
+	if err != nil {
+		failRequest(err)
+	}
+	if code == http.StatusAccepted {
+		fail(exitNotRun, "job %s has no result yet (status=%s)", jobID, result.Status)
+	}
+
+	emit(conn.output, result, func(w *tabwriter.Writer) {
+		resultTable(w, result)
+	})
+	os.Exit(exitCodeFor(result))
+}
+
+// runCancel implements `orchcli cancel ID`
+func runCancel(args []string) {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	jobID := requireID(fs, parseArgs(fs, args))
+
+	api := conn.api()
+	var summary jobs.JobSummary
+	if _, err := api.do(http.MethodPost, jobPath(jobID, "cancel"), nil, &summary, http.StatusAccepted); err != nil {
+		failRequest(err)
+	}
+
+	emit(conn.output, summary, func(w *tabwriter.Writer) {
+		summaryTable(w, []jobs.JobSummary{summary})
+	})
+}
+
+// runLogs implements `orchcli logs ID [--follow]`
+func runLogs(args []string) {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	follow := fs.Bool("follow", false, "keep streaming until the job finishes")
+	fs.BoolVar(follow, "f", false, "shorthand for --follow")
+	jobID := requireID(fs, parseArgs(fs, args))
+
+	api := conn.api()
+	done, streamed, err := streamLogs(api, jobID, *follow, os.Stdout, os.Stderr)
+	if err != nil {
+		failRequest(err)
+	}
+	if done && !streamed {
+		printStoredOutput(api, jobID)
+	}
+}
+
+// streamLogs copies a job's streamed output to stdout/stderr, polling until the job is done when follow is set.
+// It reports whether the job finished and whether any output was streamed at all
+func streamLogs(api *apiClient, jobID string, follow bool, stdout, stderr io.Writer) (bool, bool, error) {
+	offset, streamed := 0, false
+	for {
+		var page jobs.LogPage
+		_, err := api.do(http.MethodGet, jobPath(jobID, "logs"), url.Values{"offset": {strconv.Itoa(offset)}}, &page, http.StatusOK)
+		if err != nil {
+			return false, streamed, err
+		}
+
+		if page.Dropped > 0 {
+			fmt.Fprintf(stderr, "\n[%d output chunks dropped by the controller's log limit]\n", page.Dropped)
+		}
+		for _, chunk := range page.Chunks {
+			streamed = true
+			if chunk.Stream == "stderr" {
+				fmt.Fprint(stderr, chunk.Data)
+			} else {
+				fmt.Fprint(stdout, chunk.Data)
+			}
+		}
+		offset = page.Next
+
+		if page.Done || !follow {
+			return page.Done, streamed, nil
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// printStoredOutput falls back to the output captured in the final result when nothing was streamed
+func printStoredOutput(api *apiClient, jobID string) {
+	var result jobs.Result
+	if _, err := api.do(http.MethodGet, jobPath(jobID), nil, &result, http.StatusOK); err != nil {
+		failRequest(err)
+	}
+
+	fmt.Fprint(os.Stdout, result.Stdout)
+	fmt.Fprint(os.Stderr, result.Stderr)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// credFlags select where the target user and SSH credentials come from.
+// Order of precedence: named credential, --password-stdin, --password-file, ORCHCLI_PASSWORD, prompt
+type credFlags struct {
+	nonInteractive bool
+	targetUser     string
+	username       string
+	passwordFile   string
+	passwordStdin  bool
+	credential     string
+}
+
+func (c *credFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&c.nonInteractive, "non-interactive", false, "never prompt; fail when a value is missing")
+	fs.StringVar(&c.targetUser, "target-user", os.Getenv("ORCHCLI_TARGET_USER"), "target user (env ORCHCLI_TARGET_USER)")
+	fs.StringVar(&c.username, "username", os.Getenv("ORCHCLI_USERNAME"), "SSH username, defaults to the target user (env ORCHCLI_USERNAME)")
+	fs.StringVar(&c.passwordFile, "password-file", "", "read the SSH password from this file")
+	fs.BoolVar(&c.passwordStdin, "password-stdin", false, "read the SSH password from stdin")
+	fs.StringVar(&c.credential, "credential", "", "named credential from the selected profile")
+}
+
+// interactive reports whether prompting is allowed: not disabled, stdin is a terminal and not consumed by --password-stdin
+func (c *credFlags) interactive() bool {
+	return !c.nonInteractive && !c.passwordStdin && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// resolve fills in the target user and credentials for job, prompting only when allowed
+func (c *credFlags) resolve(prof profile, job jobs.JobDefinition) (string, jobs.CredentialBundle, error) {
+	var ref credentialRef
+	if c.credential != "" {
+		var ok bool
+		if ref, ok = prof.Credentials[c.credential]; !ok {
+			return "", jobs.CredentialBundle{}, fmt.Errorf("credential %s not defined in profile", c.credential)
+		}
+	}
+
+	interactive := c.interactive()
+	reader := bufio.NewReader(os.Stdin)
+
+	targetUser := firstNonEmpty(c.targetUser, job.TargetUser)
+	if interactive && c.targetUser == "" {
+		targetUser = promptUser(reader, targetUser)
+	}
+	if strings.TrimSpace(targetUser) == "" {
+		return "", jobs.CredentialBundle{}, errors.New("target user missing: set target_user, --target-user or ORCHCLI_TARGET_USER")
+	}
+
+	username := firstNonEmpty(c.username, ref.Username, targetUser)
+
+	password, err := c.password(ref)
+	if err != nil {
+		return "", jobs.CredentialBundle{}, err
+	}
+	if password == "" {
+		if !interactive {
+			return "", jobs.CredentialBundle{}, errors.New("password missing: use --password-stdin, --password-file, --credential or ORCHCLI_PASSWORD")
+		}
+		return targetUser, promptCredentials(reader, username), nil
+	}
+
+	return targetUser, jobs.CredentialBundle{Username: username, Password: password}, nil
+}
+
+// explicitUsername is the SSH username set by flag, environment or named credential, if any
+func (c *credFlags) explicitUsername(prof profile) string {
+	return firstNonEmpty(c.username, prof.Credentials[c.credential].Username)
+}
+
+// password reads the secret from the first configured non-interactive source
+func (c *credFlags) password(ref credentialRef) (string, error) {
+	switch {
+	case c.credential != "":
+		if ref.PasswordEnv != "" {
+			if v := os.Getenv(ref.PasswordEnv); v != "" {
+				return v, nil
+			}
+		}
+		if ref.PasswordFile != "" {
+			return readPasswordFile(expandHome(ref.PasswordFile))
+		}
+		return "", fmt.Errorf("credential %s has neither a populated password_env nor a password_file", c.credential)
+	case c.passwordStdin:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("read password from stdin: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case c.passwordFile != "":
+		return readPasswordFile(expandHome(c.passwordFile))
+	default:
+		return os.Getenv("ORCHCLI_PASSWORD"), nil
+	}
+}
+
+// readPasswordFile returns the first line of a password file
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read password file: %w", err)
+	}
+
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimRight(line, "\r"), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return fmt.Errorf("empty value")
+	}
+	*s = append(*s, v)
+	return nil
+}
+
+// execOutcome is what one host produced for an ad-hoc command
+type execOutcome struct {
+	host           string
+	result         jobs.Result
+	stdout, stderr bytes.Buffer
+	streamed       bool
+	err            error
+}
+
+// runExec implements `orchcli exec --host H [--host H2] --user U [flags] -- COMMAND [ARGS...]`
+func runExec(args []string) {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	var creds credFlags
+	creds.register(fs)
+	var hosts stringList
+	fs.Var(&hosts, "host", "target host or inventory name (repeatable to fan out)")
+	user := fs.String("user", "", "target user")
+	port := fs.Int("port", 0, "SSH port (default 22 or the inventory port)")
+	var terminal terminalFlags
+	terminal.register(fs, "request a pseudo-terminal")
+	timeout := fs.Duration("timeout", 0, "execution timeout, capped by the engine's job_timeout_seconds")
+	jobID := fs.String("id", "", "job ID (random when omitted; suffixed with the host when fanning out)")
+	metadata := paramFlags{}
+	fs.Var(metadata, "m", "metadata as key=value (repeatable)")
+	var input inputFlags
+	input.register(fs)
+
+	flagArgs, command := splitCommand(args)
+	if err := fs.Parse(flagArgs); err != nil {
+		os.Exit(exitUsage)
+	}
+	if command == nil {
+		command = fs.Args()
+	}
+	if len(hosts) == 0 || len(command) == 0 {
+		fail(exitUsage, "usage: orchcli exec --host H [--host H2 ...] [--user U] [flags] -- COMMAND [ARGS...]")
+	}
+
+	job := jobs.JobDefinition{
+		TargetUser: *user,
+		TargetPort: *port,
+		Command:    command[0],
+		Arguments:  command[1:],
+		Metadata:   map[string]string(metadata),
+	}
+	if *timeout > 0 {
+		job.TimeoutSeconds = int((*timeout + time.Second - 1) / time.Second)
+	}
+	api := conn.api()
+	base := ensureJobID(*jobID)
+	input.apply(api, &job, base)
+	terminal.apply(&job)
+
+	targetUser, bundle, err := creds.resolve(conn.settings(), job)
+	if err != nil {
+		fail(exitUsage, "credentials: %v", err)
+	}
+	job.TargetUser = targetUser
+	job.Credentials = bundle
+	job.Checksum = ensureChecksum(job.ChecksumInput())
+
+	os.Exit(runAdHoc(api, job, hosts, base))
+}
+
+// runAdHoc runs job on every host and returns the exit code. A single host streams its output
+// live under the base ID; several hosts run concurrently as base-host and print grouped output
+func runAdHoc(api *apiClient, job jobs.JobDefinition, hosts []string, base string) int {
+	if len(hosts) == 1 {
+		job.ID = base
+		job.TargetHost = hosts[0]
+		out := runOnHost(api, job, true)
+		if out.err != nil {
+			fail(exitUnavailable, "%s: %v", out.host, out.err)
+		}
+		if !out.streamed {
+			printOutput(out.result)
+		}
+		if out.result.Error != "" && out.result.Status != jobs.StatusSucceeded {
+			log.Printf("job %s %s: %s", out.result.JobID, out.result.Status, out.result.Error)
+		}
+		return exitCodeFor(out.result)
+	}
+
+	outcomes := make([]*execOutcome, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		hostJob := job
+		hostJob.ID = fmt.Sprintf("%s-%s", base, host)
+		hostJob.TargetHost = host
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outcomes[i] = runOnHost(api, hostJob, false)
+		}()
+	}
+	wg.Wait()
+
+	return printGrouped(outcomes)
+}
+
+// splitCommand separates flags from the command following "--"; nil means no separator was given
+func splitCommand(args []string) ([]string, []string) {
+	idx := slices.Index(args, "--")
+	if idx < 0 {
+		return args, nil
+	}
+
+	return args[:idx], args[idx+1:]
+}
+
+// runOnHost submits the job, follows its output (live to the terminal when stream is set) and fetches the result
+func runOnHost(api *apiClient, job jobs.JobDefinition, stream bool) *execOutcome {
+	out := &execOutcome{host: job.TargetHost}
+
+	if err := job.Validate(); err != nil {
+		out.err = err
+		return out
+	}
+	if err := submitJob(api.client, api.baseURL, job); err != nil {
+		out.err = fmt.Errorf("submit: %w", err)
+		return out
+	}
+
+	var err error
+	if stream {
+		_, out.streamed, err = streamLogs(api, job.ID, true, os.Stdout, os.Stderr)
+	} else {
+		_, out.streamed, err = streamLogs(api, job.ID, true, &out.stdout, &out.stderr)
+	}
+	if err != nil {
+		out.err = fmt.Errorf("logs: %w", err)
+		return out
+	}
+
+	if _, err := api.do(http.MethodGet, jobPath(job.ID), nil, &out.result, http.StatusOK); err != nil {
+		out.err = fmt.Errorf("result: %w", err)
+	}
+
+	return out
+}
+
+// printGrouped prints each host's output under a header and returns the exit code of the first failing host
+func printGrouped(outcomes []*execOutcome) int {
+	code := exitOK
+	for _, out := range outcomes {
+		hostCode := exitUnavailable
+		switch {
+		case out.err != nil:
+			fmt.Printf("==> %s: error: %v\n", out.host, out.err)
+		default:
+			hostCode = exitCodeFor(out.result)
+			fmt.Printf("==> %s: %s (exit %d)\n", out.host, out.result.Status, out.result.ExitCode)
+			if out.streamed {
+				os.Stdout.Write(out.stdout.Bytes())
+				os.Stderr.Write(out.stderr.Bytes())
+			} else {
+				fmt.Print(out.result.Stdout)
+				fmt.Fprint(os.Stderr, out.result.Stderr)
+			}
+			if out.result.Error != "" && out.result.Status != jobs.StatusSucceeded {
+				fmt.Printf("error: %s\n", out.result.Error)
+			}
+		}
+
+		if code == exitOK {
+			code = hostCode
+		}
+	}
+
+	return code
+}
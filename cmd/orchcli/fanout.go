@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// Jobs submitted with a target_group run as a fan-out under the job's ID: the controller tracks
+// them at /v1/fanouts/{id} and each host runs as its own job, <id>-<host>
+
+func fanOutPath(id string) string {
+	return "/v1/fanouts/" + url.PathEscape(id)
+}
+
+// awaitFanOut polls a fan-out until every batch settled. verbose logs progress as it changes
+func awaitFanOut(api *apiClient, id string, verbose bool) (jobs.FanOutStatus, error) {
+	last := ""
+	for {
+		var status jobs.FanOutStatus
+		if _, err := api.do(http.MethodGet, fanOutPath(id), nil, &status, http.StatusOK); err != nil {
+			var nf errNotFound
+			if errors.As(err, &nf) {
+				return jobs.FanOutStatus{}, fmt.Errorf("fan-out %s not found", id)
+			}
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		if status.State != jobs.WorkflowRunning {
+			return status, nil
+		}
+
+		progress := fmt.Sprintf("batch %d/%d, %d succeeded, %d failed", status.Batch, status.TotalBatches, status.Succeeded, status.Failed)
+		if verbose && progress != last {
+			log.Printf("fan-out %s: %s", id, progress)
+			last = progress
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// fanOutResult folds a settled fan-out into one result so it exits like a single job: failed with
+// the first failed host's exit code, or not run when hosts were skipped or never finished
+func fanOutResult(status jobs.FanOutStatus) jobs.Result {
+	result := jobs.Result{JobID: status.ID, Status: jobs.StatusSucceeded}
+	for _, h := range status.Hosts {
+		switch {
+		case h.Status == jobs.StatusSucceeded:
+		case h.Status == jobs.StatusFailed || h.Status == jobs.StatusTimedOut:
+			if result.Status != jobs.StatusFailed {
+				result.Status, result.ExitCode = jobs.StatusFailed, h.ExitCode
+			}
+		case result.Status == jobs.StatusSucceeded:
+			result.Status = jobs.StatusSkipped
+		}
+	}
+	if result.Status != jobs.StatusSucceeded {
+		result.Error = fmt.Sprintf("fan-out %s %s: %d of %d hosts failed", status.ID, status.State, status.Failed, len(status.Hosts))
+	}
+
+	return result
+}
+
+// fanOutTable prints a fan-out's state followed by one row per host
+func fanOutTable(w *tabwriter.Writer, status jobs.FanOutStatus) {
+	fmt.Fprintf(w, "Fan-out:\t%s\n", status.ID)
+	fmt.Fprintf(w, "State:\t%s\n", status.State)
+	fmt.Fprintf(w, "Batch:\t%d/%d\n", status.Batch, status.TotalBatches)
+	fmt.Fprintf(w, "Hosts:\t%d succeeded, %d failed of %d\n\n", status.Succeeded, status.Failed, len(status.Hosts))
+	fmt.Fprintln(w, "HOST\tJOB\tBATCH\tSTATUS\tEXIT\tERROR")
+	for _, h := range status.Hosts {
+		exit := "-"
+		if h.Status.Final() {
+			exit = fmt.Sprint(h.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", h.Host, h.JobID, h.Batch, h.Status, exit, h.Error)
+	}
+}
+
+// waitFanOut waits for the fan-out a group submission created and exits with its outcome.
+// notFound is reported when no fan-out has that ID either
+func waitFanOut(api *apiClient, format, id string, notFound error) {
+	var status jobs.FanOutStatus
+	if _, err := api.do(http.MethodGet, fanOutPath(id), nil, &status, http.StatusOK); err != nil {
+		failRequest(notFound)
+	}
+
+	status, err := awaitFanOut(api, id, true)
+	if err != nil {
+		fail(exitUnavailable, "wait: %v", err)
+	}
+	emit(format, status, func(w *tabwriter.Writer) {
+		fanOutTable(w, status)
+	})
+	os.Exit(exitCodeFor(fanOutResult(status)))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// maxInlineStdin is the largest stdin embedded in the job; bigger input is stored as an artifact
+const maxInlineStdin = 64 << 10
+
+// inputFlags are the stdin, environment, working directory and become flags shared by exec and script
+type inputFlags struct {
+	env        paramFlags
+	secretEnv  stringList
+	stdin      string
+	workdir    string
+	become     string
+	becomeUser string
+}
+
+func (f *inputFlags) register(fs *flag.FlagSet) {
+	f.env = paramFlags{}
+	fs.Var(f.env, "env", "environment variable as NAME=value (repeatable)")
+	fs.Var(&f.secretEnv, "secret-env", "secret environment variable as NAME=value, or NAME to copy it from the local environment (repeatable)")
+	fs.StringVar(&f.stdin, "stdin", "", "file fed to the command's stdin (\"-\" reads orchcli's stdin)")
+	fs.StringVar(&f.workdir, "workdir", "", "absolute remote directory to run in")
+	fs.StringVar(&f.become, "become", "", "run as another user via sudo or su (su needs --tty)")
+	fs.StringVar(&f.becomeUser, "become-user", "", "user to become (default root)")
+}
+
+// apply sets the job's environment and stdin. Large input is stored under base, which every
+// per-host job references
+func (f *inputFlags) apply(api *apiClient, job *jobs.JobDefinition, base string) {
+	for _, raw := range f.secretEnv {
+		name, value := secretFlag("secret-env", raw)
+		f.env[name] = value
+		job.SecretEnv = append(job.SecretEnv, name)
+	}
+	if len(f.env) > 0 {
+		job.Env = map[string]string(f.env)
+	}
+	job.WorkingDir = f.workdir
+	if f.become != "" || f.becomeUser != "" {
+		job.Become = &jobs.BecomeSpec{Method: firstNonEmpty(f.become, jobs.BecomeSudo), User: f.becomeUser}
+	}
+
+	if f.stdin == "" {
+		return
+	}
+	var data []byte
+	var err error
+	if f.stdin == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(filepath.Clean(f.stdin))
+	}
+	if err != nil {
+		fail(exitUsage, "read stdin: %v", err)
+	}
+
+	if len(data) <= maxInlineStdin {
+		job.Stdin = &jobs.StdinSource{Data: string(data)}
+		return
+	}
+	artifact, err := api.upload(jobPath(base, "artifacts", url.PathEscape("stdin")), bytes.NewReader(data))
+	if err != nil {
+		fail(exitUnavailable, "store stdin: %v", err)
+	}
+	job.Stdin = &jobs.StdinSource{Artifact: artifact.Name, ArtifactJob: base}
+}
+
+// secretFlag splits a NAME=value secret flag, reading the value from the local environment when
+// only NAME is given so it stays out of shell history
+func secretFlag(flagName, raw string) (string, string) {
+	name, value, ok := strings.Cut(raw, "=")
+	if !ok {
+		if value, ok = os.LookupEnv(name); !ok {
+			fail(exitUsage, "%s %s: not set in the local environment", flagName, name)
+		}
+	}
+
+	return name, value
+}
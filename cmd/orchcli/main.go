@@ -7,15 +7,15 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"golang.org/x/term"
@@ -24,59 +24,67 @@ import (
 )
 
 func main() {
-	jobPath := flag.String("job", "", "path to job.json")
-	controllerURL := flag.String("controller", "http://localhost:8080", "controller base URL")
-	flag.Parse()
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		// Legacy invocation: orchcli -job FILE -controller URL
+		runSubmit(os.Args[1:])
+		return
+	}
 
-	if strings.TrimSpace(*jobPath) == "" {
-		log.Fatal("-job is required")
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(exitUsage)
 	}
+	cmd.run(os.Args[2:])
+}
 
-	job, err := loadJob(*jobPath)
+// submitAndWait fills in ID, user, checksum and credentials, submits the job and, unless detached,
+// blocks on the result and exits with a code reflecting the outcome
+func submitAndWait(conn *connFlags, creds *credFlags, job jobs.JobDefinition, detach bool) {
+	targetUser, bundle, err := creds.resolve(conn.settings(), job)
 	if err != nil {
-		log.Fatalf("load job: %v", err)
+		fail(exitUsage, "credentials: %v", err)
 	}
 
-	reader := bufio.NewReader(os.Stdin)
 	job.ID = ensureJobID(job.ID)
-	job.TargetUser = promptUser(reader, job.TargetUser)
-	job.Checksum = ensureChecksum(job.Command)
-	job.Credentials = promptCredentials(reader, job.TargetUser)
+	job.TargetUser = targetUser
+	job.Checksum = ensureChecksum(job.ChecksumInput())
+	job.Credentials = bundle
 
 	if err := job.Validate(); err != nil {
-		log.Fatalf("job invalid: %v", err)
+		fail(exitUsage, "job invalid: %v", err)
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	// baseURL := strings.TrimRight(*controllerURL, "/")
-	baseURL, err := normalizeControllerURL(*controllerURL)
-	if err != nil {
-		log.Fatalf("controller URL invalid: %v", err)
+	api := conn.api()
+	if err := submitJob(api.client, api.baseURL, job); err != nil {
+		fail(exitUnavailable, "submit job: %v", err)
 	}
 
-	if err := submitJob(client, baseURL, job); err != nil {
-		log.Fatalf("submit job: %v", err)
+	if detach {
+		fmt.Println(job.ID)
+		return
 	}
-	log.Printf("job %s queued; waiting for result...", job.ID)
-
-	if err := pollResult(client, baseURL, job.ID); err != nil {
-		log.Fatalf("poll result: %v", err)
+	if job.TargetGroup != "" {
+		log.Printf("job %s fanned out across group %s; waiting for every host...", job.ID, job.TargetGroup)
+		status, err := awaitFanOut(api, job.ID, true)
+		if err != nil {
+			fail(exitUnavailable, "poll fan-out: %v", err)
+		}
+		emit(conn.output, status, func(w *tabwriter.Writer) {
+			fanOutTable(w, status)
+		})
+		os.Exit(exitCodeFor(fanOutResult(status)))
 	}
-}
+	log.Printf("job %s queued; waiting for result...", job.ID)
 
-// loadJob reads the user-supplied JSON job file
-func loadJob(path string) (jobs.JobDefinition, error) {
-	data, err := os.ReadFile(filepath.Clean(path))
+	result, err := pollResult(api.client, api.baseURL, job.ID, 0)
 	if err != nil {
-		return jobs.JobDefinition{}, err
-	}
-
-	var job jobs.JobDefinition
-	if err := json.Unmarshal(data, &job); err != nil {
-		return jobs.JobDefinition{}, err
+		fail(exitUnavailable, "poll result: %v", err)
 	}
-
-	return job, nil
+	emit(conn.output, result, func(w *tabwriter.Writer) {
+		resultTable(w, result)
+	})
+	os.Exit(exitCodeFor(result))
 }
 
 // ensureJobID fills in a random identifier when the file omits one
@@ -105,7 +113,7 @@ func promptUser(reader *bufio.Reader, current string) string {
 	return text
 }
 
-// ensureChecksum computes the command checksum so the controller/engine share the same digest
+// ensureChecksum computes the checksum of a job's ChecksumInput so the controller/engine share the same digest
 func ensureChecksum(command string) string {
 	sum := sha256.Sum256([]byte(command))
 	return hex.EncodeToString(sum[:])
@@ -120,7 +128,10 @@ func promptCredentials(reader *bufio.Reader, user string) jobs.CredentialBundle
 		username = strings.TrimSpace(text)
 	}
 
-	password := readSecret("Admin password")
+	password, err := readSecret("Admin password")
+	if err != nil {
+		fail(exitUsage, "read secret: %v", err)
+	}
 	return jobs.CredentialBundle{
 		Username: username,
 		Password: string(password),
@@ -128,15 +139,15 @@ func promptCredentials(reader *bufio.Reader, user string) jobs.CredentialBundle
 }
 
 // readSecret hides user input
-func readSecret(prompt string) []byte {
+func readSecret(prompt string) ([]byte, error) {
 	fmt.Printf("%s: ", prompt)
 	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
 	fmt.Println()
 	if err != nil {
-		log.Fatalf("read secret: %v", err)
+		return nil, err
 	}
 
-	return bytes.TrimSpace(secret)
+	return bytes.TrimSpace(secret), nil
 }
 
 // normalizeControllerURL ensures the controller flag can be provided as host:port
@@ -184,9 +195,22 @@ func submitJob(client *http.Client, baseURL string, job jobs.JobDefinition) erro
 	return nil
 }
 
-// pollResult keeps hitting /v1/jobs/{id} until the controller returns a finalized result
-func pollResult(client *http.Client, baseURL, jobID string) error {
+// errWaitTimeout is returned by pollResult when the job did not finish within its timeout
+var errWaitTimeout = errors.New("timed out")
+
+// pollResult keeps hitting /v1/jobs/{id} until the controller returns a finalized result.
+// A positive timeout bounds the wait
+func pollResult(client *http.Client, baseURL, jobID string, timeout time.Duration) (jobs.Result, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
 	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return jobs.Result{}, fmt.Errorf("%w after %s waiting for job %s", errWaitTimeout, timeout, jobID)
+		}
+
 		resp, err := client.Get(fmt.Sprintf("%s/v1/jobs/%s", baseURL, jobID))
 		if err != nil {
 			time.Sleep(2 * time.Second)
@@ -210,26 +234,14 @@ func pollResult(client *http.Client, baseURL, jobID string) error {
 		if resp.StatusCode == http.StatusOK {
 			var result jobs.Result
 			if err := json.Unmarshal(body, &result); err != nil {
-				return err
+				return jobs.Result{}, err
 			}
-			printResult(result)
-			return nil
+			return result, nil
 		}
 
-		return fmt.Errorf("controller returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
-}
-
-// printResult mirrors what users expect to see locally
-func printResult(result jobs.Result) {
-	log.Printf("job %s finished status=%s exit=%d", result.JobID, result.Status, result.ExitCode)
-	if strings.TrimSpace(result.Stdout) != "" {
-		fmt.Printf("----- stdout -----\n%s\n", result.Stdout)
-	}
-	if strings.TrimSpace(result.Stderr) != "" {
-		fmt.Printf("----- stderr -----\n%s\n", result.Stderr)
-	}
-	if strings.TrimSpace(result.Error) != "" {
-		fmt.Printf("error: %s\n", result.Error)
+		if resp.StatusCode == http.StatusNotFound {
+			return jobs.Result{}, errNotFound{jobID: jobID}
+		}
+		return jobs.Result{}, fmt.Errorf("controller returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
 	}
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// Process exit codes follow sysexits(3) where one fits so CI pipelines can branch on them.
+// A failed job exits with its remote exit code when the command produced one
+const (
+	exitOK          = 0
+	exitFailure     = 1  // job failed without a usable remote exit code
+	exitUsage       = 64 // EX_USAGE: bad arguments or an invalid job file
+	exitUnavailable = 69 // EX_UNAVAILABLE: controller unreachable or request rejected
+	exitNotRun      = 75 // EX_TEMPFAIL: job cancelled, expired, skipped, timed out or still running
+)
+
+// exitCodeFor maps a final result onto the CLI's exit code
+func exitCodeFor(result jobs.Result) int {
+	switch result.Status {
+	case jobs.StatusSucceeded:
+		return exitOK
+	case jobs.StatusFailed:
+		if result.ExitCode > 0 && result.ExitCode < 256 {
+			return result.ExitCode
+		}
+		return exitFailure
+	default:
+		return exitNotRun
+	}
+}
+
+// fail logs and exits with code
+func fail(code int, format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(code)
+}
+
+// Output formats selectable with -o
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatYAML
+}
+
+// emit writes v as JSON or YAML, or calls table for the human-readable layout
+func emit(format string, v any, table func(w *tabwriter.Writer)) {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			fail(exitFailure, "encode json: %v", err)
+		}
+	case formatYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			fail(exitFailure, "encode yaml: %v", err)
+		}
+		enc.Close()
+	default:
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		table(tw)
+		tw.Flush()
+	}
+}
+
+// summaryTable prints job summaries as aligned columns
+func summaryTable(w io.Writer, summaries []jobs.JobSummary) {
+	fmt.Fprintln(w, "ID\tSTATUS\tQUEUE\tHOST\tEXIT\tSUBMITTED\tDURATION")
+	for _, s := range summaries {
+		exit := "-"
+		if s.Status.Final() {
+			exit = fmt.Sprint(s.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.ID, s.Status, s.Queue, s.TargetHost, exit,
+			s.SubmittedAt.Local().Format(time.DateTime), duration(s))
+	}
+}
+
+// duration reports how long a job has been (or was) running
+func duration(s jobs.JobSummary) string {
+	if s.StartedAt.IsZero() {
+		return "-"
+	}
+
+	end := s.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	return end.Sub(s.StartedAt).Round(time.Second).String()
+}
+
+// resultTable prints a result as key/value rows followed by its output
+func resultTable(w *tabwriter.Writer, result jobs.Result) {
+	fmt.Fprintf(w, "Job:\t%s\n", result.JobID)
+	fmt.Fprintf(w, "Status:\t%s\n", result.Status)
+	fmt.Fprintf(w, "Exit code:\t%d\n", result.ExitCode)
+	if result.ExitSignal != "" {
+		signal := strings.TrimSpace("SIG" + result.ExitSignal + " " + result.SignalMessage)
+		if result.CoreDumped {
+			signal += " (core dumped)"
+		}
+		fmt.Fprintf(w, "Signal:\t%s\n", signal)
+	}
+	if result.ExitStatusMissing {
+		fmt.Fprintf(w, "Exit status:\tnot reported by the server\n")
+	}
+	if !result.StartedAt.IsZero() {
+		fmt.Fprintf(w, "Started:\t%s\n", result.StartedAt.Local().Format(time.DateTime))
+	}
+	fmt.Fprintf(w, "Finished:\t%s\n", result.FinishedAt.Local().Format(time.DateTime))
+	if result.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", result.Error)
+	}
+	if result.ErrorClass != "" {
+		fmt.Fprintf(w, "Error class:\t%s\n", result.ErrorClass)
+	}
+	if term := result.Metadata["pty_term"]; term != "" {
+		fmt.Fprintf(w, "Terminal:\t%s\n", strings.TrimSpace(fmt.Sprintf("%s %sx%s %s",
+			term, result.Metadata["pty_cols"], result.Metadata["pty_rows"], result.Metadata["pty_modes"])))
+	}
+	if result.StdoutTruncated || result.StderrTruncated {
+		fmt.Fprintf(w, "Output:\tstdout %d bytes%s, stderr %d bytes%s\n",
+			result.StdoutBytes, truncatedNote(result.StdoutTruncated), result.StderrBytes, truncatedNote(result.StderrTruncated))
+	}
+	for _, a := range result.Artifacts {
+		fmt.Fprintf(w, "Artifact:\t%s (%d bytes)\n", a.Name, a.Size)
+	}
+	for _, t := range result.Transcript {
+		if !t.Matched {
+			fmt.Fprintf(w, "Expect %d:\t%q not matched after %dms\n", t.Step, t.Expect, t.ElapsedMs)
+			continue
+		}
+		fmt.Fprintf(w, "Expect %d:\t%q matched after %dms, sent %q\n", t.Step, t.Expect, t.ElapsedMs, t.Sent)
+	}
+	w.Flush()
+
+	printOutput(result)
+}
+
+func truncatedNote(truncated bool) string {
+	if truncated {
+		return " (truncated)"
+	}
+
+	return ""
+}
+
+// printOutput writes captured stdout/stderr under ----- stdout ----- style banners
+func printOutput(result jobs.Result) {
+	if result.Stdout != "" {
+		fmt.Printf("----- stdout -----\n%s\n", result.Stdout)
+	}
+	if result.Stderr != "" {
+		fmt.Printf("----- stderr -----\n%s\n", result.Stderr)
+	}
+}
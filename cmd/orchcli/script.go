@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// runScript implements `orchcli script --host H [--host H2] [flags] FILE [-- ARGS...]`: the local
+// script is embedded in the job (or stored as an artifact with --artifact) and run by an interpreter on each host
+func runScript(args []string) {
+	fs := flag.NewFlagSet("script", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	var creds credFlags
+	creds.register(fs)
+	var hosts stringList
+	fs.Var(&hosts, "host", "target host or inventory name (repeatable to fan out)")
+	user := fs.String("user", "", "target user")
+	port := fs.Int("port", 0, "SSH port (default 22 or the inventory port)")
+	interpreter := fs.String("interpreter", "", "absolute path of the remote interpreter (default from the #! line, else /bin/sh)")
+	delivery := fs.String("delivery", "", "stdin (default) pipes the script in; file uploads it to a temporary file")
+	asArtifact := fs.Bool("artifact", false, "store the script on the controller instead of embedding it in the job")
+	var terminal terminalFlags
+	terminal.register(fs, "request a pseudo-terminal (implies --delivery file, as does --stdin)")
+	timeout := fs.Duration("timeout", 0, "execution timeout, capped by the engine's job_timeout_seconds")
+	jobID := fs.String("id", "", "job ID (random when omitted; suffixed with the host when fanning out)")
+	metadata := paramFlags{}
+	fs.Var(metadata, "m", "metadata as key=value (repeatable)")
+	var input inputFlags
+	input.register(fs)
+
+	flagArgs, scriptArgs := splitCommand(args)
+	if err := fs.Parse(flagArgs); err != nil {
+		os.Exit(exitUsage)
+	}
+	rest := fs.Args()
+	if scriptArgs == nil && len(rest) > 0 {
+		scriptArgs = rest[1:]
+		rest = rest[:1]
+	}
+	if len(hosts) == 0 || len(rest) != 1 {
+		fail(exitUsage, "usage: orchcli script --host H [--host H2 ...] [--interpreter PATH] [flags] FILE [-- ARGS...]")
+	}
+
+	file := rest[0]
+	body, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		fail(exitUsage, "read script: %v", err)
+	}
+	var interpreterArgs []string
+	if *interpreter == "" {
+		if *interpreter, interpreterArgs, err = shebangInterpreter(body); err != nil {
+			fail(exitUsage, "%s: %v", file, err)
+		}
+	}
+	// The terminal or --stdin input owns the interpreter's stdin, so the script goes to a file
+	if (terminal.tty || input.stdin != "") && *delivery == "" {
+		*delivery = jobs.ScriptFile
+	}
+
+	api := conn.api()
+	base := ensureJobID(*jobID)
+	spec := &jobs.ScriptSpec{Interpreter: *interpreter, InterpreterArgs: interpreterArgs, Delivery: *delivery}
+	if *asArtifact {
+		name := filepath.Base(file)
+		if jobs.ValidateArtifactName(name) != nil {
+			name = "script"
+		}
+		artifact := putPayload(api, base, name, file)
+		// Per-host jobs get their own IDs, so they all point back at the base ID for the script
+		spec.Artifact, spec.ArtifactJob, spec.SHA256 = artifact.Name, base, artifact.SHA256
+	} else {
+		spec.Body = string(body)
+	}
+
+	job := jobs.JobDefinition{
+		TargetUser: *user,
+		TargetPort: *port,
+		Type:       jobs.JobScript,
+		Script:     spec,
+		Arguments:  scriptArgs,
+		Metadata:   map[string]string(metadata),
+	}
+	if *timeout > 0 {
+		job.TimeoutSeconds = int((*timeout + time.Second - 1) / time.Second)
+	}
+	input.apply(api, &job, base)
+	terminal.apply(&job)
+
+	targetUser, bundle, err := creds.resolve(conn.settings(), job)
+	if err != nil {
+		fail(exitUsage, "credentials: %v", err)
+	}
+	job.TargetUser = targetUser
+	job.Credentials = bundle
+	job.Checksum = ensureChecksum(job.ChecksumInput())
+
+	os.Exit(runAdHoc(api, job, hosts, base))
+}
+
+// shebangInterpreter returns the interpreter named on the script's #! line and its options, or
+// /bin/sh without one. Like the kernel, it passes everything after the path as a single argument.
+// "#!/usr/bin/env NAME" is rejected since the remote PATH would decide what runs
+func shebangInterpreter(body []byte) (string, []string, error) {
+	if !bytes.HasPrefix(body, []byte("#!")) {
+		return "/bin/sh", nil, nil
+	}
+	line, _ := bufio.NewReader(bytes.NewReader(body[2:])).ReadString('\n')
+	line = strings.TrimSpace(line)
+	interpreter, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		interpreter, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	switch {
+	case interpreter == "":
+		return "/bin/sh", nil, nil
+	case path.Base(interpreter) == "env":
+		return "", nil, errors.New("#!/usr/bin/env scripts need --interpreter with an absolute path")
+	case arg == "":
+		return interpreter, nil, nil
+	default:
+		return interpreter, []string{arg}, nil
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/transport"
+)
+
+// submitShell submits a shell job and returns the operator token its shell attaches with
+func submitShell(api *apiClient, job jobs.JobDefinition) (string, error) {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := api.client.Post(api.baseURL+"/v1/jobs", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("controller rejected job (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var ticket struct {
+		Token string `json:"shell_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ticket); err != nil || ticket.Token == "" {
+		return "", fmt.Errorf("controller returned no shell token: %v", err)
+	}
+
+	return ticket.Token, nil
+}
+
+// resizePoll is how often the local terminal size is checked; polling avoids per-OS SIGWINCH handling
+const resizePoll = 250 * time.Millisecond
+
+// runShell implements `orchcli shell --host H [--user U] [flags]`
+func runShell(args []string) {
+	fs := flag.NewFlagSet("shell", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	var creds credFlags
+	creds.register(fs)
+	host := fs.String("host", "", "target host or inventory name")
+	user := fs.String("user", "", "target user")
+	port := fs.Int("port", 0, "SSH port (default 22 or the inventory port)")
+	idle := fs.Duration("idle-timeout", 0, "end the shell after this long without input, capped by the engine's limit")
+	jobID := fs.String("id", "", "job ID (random when omitted)")
+	metadata := paramFlags{}
+	fs.Var(metadata, "m", "metadata as key=value (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		os.Exit(exitUsage)
+	}
+	if *host == "" || fs.NArg() > 0 {
+		fail(exitUsage, "usage: orchcli shell --host H [--user U] [flags]")
+	}
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(stdout) {
+		fail(exitUsage, "shell needs a terminal on stdin and stdout")
+	}
+
+	spec := &jobs.TerminalSpec{Term: os.Getenv("TERM")}
+	spec.Cols, spec.Rows, _ = term.GetSize(stdout)
+	job := jobs.JobDefinition{
+		ID:         ensureJobID(*jobID),
+		Type:       jobs.JobShell,
+		TargetHost: *host,
+		TargetUser: *user,
+		TargetPort: *port,
+		AllowTTY:   true,
+		Terminal:   spec,
+		Metadata:   map[string]string(metadata),
+	}
+	if *idle > 0 {
+		job.IdleTimeoutSeconds = int((*idle + time.Second - 1) / time.Second)
+	}
+
+	api := conn.api()
+	targetUser, bundle, err := creds.resolve(conn.settings(), job)
+	if err != nil {
+		fail(exitUsage, "credentials: %v", err)
+	}
+	job.TargetUser = targetUser
+	job.Credentials = bundle
+	job.Checksum = ensureChecksum(job.ChecksumInput())
+	if err := job.Validate(); err != nil {
+		fail(exitUsage, "%v", err)
+	}
+
+	token, err := submitShell(api, job)
+	if err != nil {
+		fail(exitUnavailable, "submit: %v", err)
+	}
+	header := http.Header{}
+	header.Set(transport.ShellTokenHeader, token)
+	ws, err := transport.DialWebSocket(context.Background(), api.client, api.baseURL+jobPath(job.ID, "shell"), header)
+	if err != nil {
+		api.do(http.MethodPost, jobPath(job.ID, "cancel"), nil, nil, http.StatusAccepted)
+		fail(exitUnavailable, "attach: %v", err)
+	}
+	log.Printf("job %s: waiting for an engine to open the shell", job.ID)
+
+	ended, err := attachTerminal(ws, stdin, stdout)
+	if err != nil {
+		// The shell never started; do not leave it queued for an engine to open with nobody attached
+		api.do(http.MethodPost, jobPath(job.ID, "cancel"), nil, nil, http.StatusAccepted)
+		fail(exitUnavailable, "shell: %v", err)
+	}
+	if ended.Error != "" {
+		log.Printf("job %s: %s", job.ID, ended.Error)
+	}
+
+	result, err := pollResult(api.client, api.baseURL, job.ID, time.Minute)
+	if err != nil {
+		fail(exitUnavailable, "result: %v", err)
+	}
+	os.Exit(exitCodeFor(result))
+}
+
+// attachTerminal puts the local terminal in raw mode and relays it over ws until the shell ends.
+// It returns the engine's exit message, or an error when the relay failed before the shell ran
+func attachTerminal(ws *transport.WSConn, stdin, stdout int) (jobs.ShellMessage, error) {
+	defer ws.Close()
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return jobs.ShellMessage{}, fmt.Errorf("raw mode: %w", err)
+	}
+	defer term.Restore(stdin, state)
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 && ws.WriteMessage(transport.WSBinary, buf[:n]) != nil {
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		cols, rows, _ := term.GetSize(stdout)
+		ticker := time.NewTicker(resizePoll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			c, r, err := term.GetSize(stdout)
+			if err != nil || (c == cols && r == rows) {
+				continue
+			}
+			cols, rows = c, r
+			msg, _ := json.Marshal(jobs.ShellMessage{Type: jobs.ShellResize, Cols: cols, Rows: rows})
+			ws.WriteMessage(transport.WSText, msg)
+		}
+	}()
+
+	var ended jobs.ShellMessage
+	started := false
+	for {
+		op, data, err := ws.ReadMessage()
+		if err != nil {
+			if !started && ended.Type == "" {
+				return ended, fmt.Errorf("relay closed: %w", err)
+			}
+			return ended, nil
+		}
+
+		switch op {
+		case transport.WSBinary:
+			started = true
+			os.Stdout.Write(data)
+		case transport.WSText:
+			var msg jobs.ShellMessage
+			if json.Unmarshal(data, &msg) != nil {
+				continue
+			}
+			switch msg.Type {
+			case jobs.ShellExit:
+				ended = msg
+			case jobs.ShellError:
+				if !started && ended.Type == "" {
+					return msg, fmt.Errorf("%s", msg.Error)
+				}
+				ended = msg
+			}
+		}
+	}
+}
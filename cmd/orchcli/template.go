@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// paramFlags collects repeated -p key=value flags
+type paramFlags map[string]string
+
+func (p paramFlags) String() string {
+	pairs := make([]string, 0, len(p))
+	for k, v := range p {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (p paramFlags) Set(raw string) error {
+	key, value, ok := strings.Cut(raw, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected key=value, got %q", raw)
+	}
+	p[strings.TrimSpace(key)] = value
+	return nil
+}
+
+// runTemplate implements `orchcli run-template NAME -p key=value ...`
+func runTemplate(args []string) {
+	fs := flag.NewFlagSet("run-template", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	var creds credFlags
+	creds.register(fs)
+	jobID := fs.String("id", "", "job ID (random when omitted)")
+	detach := fs.Bool("detach", false, "print the job ID and return without waiting")
+	params := paramFlags{}
+	fs.Var(params, "p", "template parameter as key=value (repeatable)")
+
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fail(exitUsage, "usage: orchcli run-template NAME [-p key=value ...]")
+	}
+
+	api := conn.api()
+	job, err := renderTemplate(api.client, api.baseURL, rest[0], params)
+	if err != nil {
+		fail(exitUsage, "render template: %v", err)
+	}
+	job.ID = *jobID
+
+	// Checksum is computed by submitAndWait from the rendered command
+	submitAndWait(&conn, &creds, job, *detach)
+}
+
+// renderTemplate asks the controller to validate parameters and substitute them into the template
+func renderTemplate(client *http.Client, baseURL, name string, params map[string]string) (jobs.JobDefinition, error) {
+	payload, err := json.Marshal(struct {
+		Params map[string]string `json:"params"`
+	}{Params: params})
+	if err != nil {
+		return jobs.JobDefinition{}, err
+	}
+
+	resp, err := client.Post(
+		fmt.Sprintf("%s/v1/templates/%s/render", baseURL, url.PathEscape(name)),
+		"application/json",
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return jobs.JobDefinition{}, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return jobs.JobDefinition{}, fmt.Errorf("controller rejected parameters (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var job jobs.JobDefinition
+	if err := json.Unmarshal(body, &job); err != nil {
+		return jobs.JobDefinition{}, err
+	}
+
+	return job, nil
+}
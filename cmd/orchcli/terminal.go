@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/term"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// terminalFlags are the pseudo-terminal flags shared by exec and script
+type terminalFlags struct {
+	tty   bool
+	term  string
+	size  string
+	modes paramFlags
+}
+
+func (f *terminalFlags) register(fs *flag.FlagSet, usage string) {
+	f.modes = paramFlags{}
+	fs.BoolVar(&f.tty, "tty", false, usage)
+	fs.StringVar(&f.term, "term", "", "terminal type for --tty (default xterm)")
+	fs.StringVar(&f.size, "size", "", "terminal size for --tty as COLSxROWS (default this terminal's size, or 80x24)")
+	fs.Var(f.modes, "tty-mode", "terminal mode for --tty as name=value, e.g. echo=0 or ospeed=38400 (repeatable)")
+}
+
+// apply sets AllowTTY and the terminal settings on job
+func (f *terminalFlags) apply(job *jobs.JobDefinition) {
+	job.AllowTTY = f.tty
+	if !f.tty {
+		if f.term != "" || f.size != "" || len(f.modes) > 0 {
+			fail(exitUsage, "--term, --size and --tty-mode need --tty")
+		}
+		return
+	}
+
+	spec := &jobs.TerminalSpec{Term: f.term}
+	switch {
+	case f.size != "":
+		if _, err := fmt.Sscanf(f.size, "%dx%d", &spec.Cols, &spec.Rows); err != nil {
+			fail(exitUsage, "--size %q: want COLSxROWS", f.size)
+		}
+	case term.IsTerminal(int(os.Stdout.Fd())):
+		// Match the operator's terminal so remote output wraps where it will be shown
+		spec.Cols, spec.Rows, _ = term.GetSize(int(os.Stdout.Fd()))
+	}
+	for name, raw := range f.modes {
+		value, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			fail(exitUsage, "--tty-mode %s: %q is not a number", name, raw)
+		}
+		if spec.Modes == nil {
+			spec.Modes = map[string]uint32{}
+		}
+		spec.Modes[name] = uint32(value)
+	}
+	job.Terminal = spec
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/controller"
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// ANSI sequences used by the dashboard
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiCyan      = "\x1b[36m"
+	ansiDim       = "\x1b[2m"
+	ansiClear     = "\x1b[H\x1b[2J"
+	ansiAltScreen = "\x1b[?1049h\x1b[?25l"
+	ansiMainScr   = "\x1b[?25h\x1b[?1049l"
+)
+
+// recentLimit caps how many finished jobs the dashboard keeps on screen
+const recentLimit = 15
+
+// Keys the dashboard reacts to, decoded from raw terminal input
+type topKey int
+
+const (
+	keyNone topKey = iota
+	keyUp
+	keyDown
+	keyEnter
+	keyEscape
+	keyQuit
+	keyCancel
+	keyYes
+	keyNo
+)
+
+// topView holds everything the dashboard draws; it is only touched from the event loop
+type topView struct {
+	api *apiClient
+
+	queues  []controller.QueueInfo
+	engines []controller.EngineInfo
+	running []jobs.JobSummary
+	recent  []jobs.JobSummary
+	fetched time.Time
+	err     error
+
+	selected  int
+	confirm   string // job awaiting a y/n cancel confirmation
+	message   string
+	following string // job whose output is open, empty on the overview
+	output    []jobs.LogChunk
+	offset    int
+	outDone   bool
+}
+
+// runTop implements `orchcli top`, a full-screen live view of the controller
+func runTop(args []string) {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	interval := fs.Duration("interval", 2*time.Second, "refresh interval")
+	parseArgs(fs, args)
+
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		fail(exitUsage, "top needs an interactive terminal; use `orchcli list` in scripts")
+	}
+
+	view := &topView{api: conn.api()}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		fail(exitFailure, "raw terminal: %v", err)
+	}
+	fmt.Print(ansiAltScreen)
+	defer func() {
+		fmt.Print(ansiMainScr)
+		term.Restore(in, state)
+	}()
+
+	keys := make(chan topKey)
+	go readKeys(keys)
+
+	ticker := time.NewTicker(max(*interval, 200*time.Millisecond))
+	defer ticker.Stop()
+
+	view.refresh()
+	view.draw(out)
+	for {
+		select {
+		case k := <-keys:
+			if !view.handle(k) {
+				return
+			}
+		case <-ticker.C:
+			view.refresh()
+		}
+		view.draw(out)
+	}
+}
+
+// readKeys decodes raw stdin into dashboard keys until stdin closes
+func readKeys(keys chan<- topKey) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			keys <- keyQuit
+			return
+		}
+
+		b := buf[:n]
+		switch {
+		case len(b) >= 3 && b[0] == 0x1b && b[1] == '[' && b[2] == 'A':
+			keys <- keyUp
+		case len(b) >= 3 && b[0] == 0x1b && b[1] == '[' && b[2] == 'B':
+			keys <- keyDown
+		case b[0] == 0x1b:
+			keys <- keyEscape
+		case b[0] == 'k':
+			keys <- keyUp
+		case b[0] == 'j':
+			keys <- keyDown
+		case b[0] == '\r' || b[0] == '\n' || b[0] == 'l':
+			keys <- keyEnter
+		case b[0] == 'q' || b[0] == 0x03: // ctrl-c arrives as a byte in raw mode
+			keys <- keyQuit
+		case b[0] == 'c':
+			keys <- keyCancel
+		case b[0] == 'y' || b[0] == 'Y':
+			keys <- keyYes
+		case b[0] == 'n' || b[0] == 'N':
+			keys <- keyNo
+		}
+	}
+}
+
+// handle applies a key press and reports whether the dashboard should keep running
+func (v *topView) handle(k topKey) bool {
+	v.message = ""
+	if v.confirm != "" {
+		if k == keyYes {
+			v.cancel(v.confirm)
+		} else {
+			v.message = "cancel aborted"
+		}
+		v.confirm = ""
+		return true
+	}
+
+	rows := v.rows()
+	switch k {
+	case keyQuit:
+		if v.following == "" {
+			return false
+		}
+		v.closeOutput()
+	case keyEscape:
+		v.closeOutput()
+	case keyUp:
+		v.selected = max(v.selected-1, 0)
+	case keyDown:
+		v.selected = min(v.selected+1, max(len(rows)-1, 0))
+	case keyEnter:
+		if v.following == "" && len(rows) > 0 {
+			v.following, v.output, v.offset, v.outDone = rows[v.selected].ID, nil, 0, false
+			v.refreshOutput()
+		}
+	case keyCancel:
+		id := v.following
+		if id == "" && len(rows) > 0 {
+			id = rows[v.selected].ID
+		}
+		if id != "" {
+			v.confirm = id
+		}
+	}
+
+	return true
+}
+
+func (v *topView) closeOutput() {
+	v.following, v.output = "", nil
+}
+
+func (v *topView) cancel(jobID string) {
+	var summary jobs.JobSummary
+	if _, err := v.api.do(http.MethodPost, jobPath(jobID, "cancel"), nil, &summary, http.StatusAccepted); err != nil {
+		v.message = fmt.Sprintf("cancel %s: %v", jobID, err)
+		return
+	}
+	v.message = fmt.Sprintf("cancel requested for %s (%s)", jobID, summary.Status)
+	v.refresh()
+}
+
+// rows are the selectable jobs: running first, then recent completions
+func (v *topView) rows() []jobs.JobSummary {
+	return append(append([]jobs.JobSummary{}, v.running...), v.recent...)
+}
+
+// refresh reloads controller state; errors are shown rather than ending the session
+func (v *topView) refresh() {
+	v.err = nil
+	if v.following != "" {
+		v.refreshOutput()
+	}
+
+	var queues []controller.QueueInfo
+	if _, err := v.api.do(http.MethodGet, "/v1/queues", nil, &queues, http.StatusOK); err != nil {
+		v.err = err
+		return
+	}
+	var engines []controller.EngineInfo
+	if _, err := v.api.do(http.MethodGet, "/v1/engines", nil, &engines, http.StatusOK); err != nil {
+		v.err = err
+		return
+	}
+	var running []jobs.JobSummary
+	if _, err := v.api.do(http.MethodGet, "/v1/jobs", url.Values{"status": {string(jobs.StatusRunning)}}, &running, http.StatusOK); err != nil {
+		v.err = err
+		return
+	}
+	var latest []jobs.JobSummary
+	if _, err := v.api.do(http.MethodGet, "/v1/jobs", url.Values{"limit": {"200"}}, &latest, http.StatusOK); err != nil {
+		v.err = err
+		return
+	}
+
+	var recent []jobs.JobSummary
+	for _, s := range latest {
+		if s.Status.Final() {
+			recent = append(recent, s)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].FinishedAt.After(recent[j].FinishedAt) })
+	sort.Slice(running, func(i, j int) bool { return running[i].StartedAt.Before(running[j].StartedAt) })
+
+	v.queues, v.engines, v.running = queues, engines, running
+	v.recent = recent[:min(len(recent), recentLimit)]
+	v.selected = min(v.selected, max(len(v.running)+len(v.recent)-1, 0))
+	v.fetched = time.Now()
+}
+
+// refreshOutput appends newly streamed chunks for the open job
+func (v *topView) refreshOutput() {
+	if v.outDone {
+		return
+	}
+
+	var page jobs.LogPage
+	query := url.Values{"offset": {strconv.Itoa(v.offset)}}
+	if _, err := v.api.do(http.MethodGet, jobPath(v.following, "logs"), query, &page, http.StatusOK); err != nil {
+		v.message = err.Error()
+		return
+	}
+	v.output = append(v.output, page.Chunks...)
+	v.offset = page.Next
+	v.outDone = page.Done
+
+	// Jobs run by engines that predate log streaming only carry output in their result
+	if page.Done && len(v.output) == 0 {
+		var result jobs.Result
+		if _, err := v.api.do(http.MethodGet, jobPath(v.following), nil, &result, http.StatusOK); err == nil {
+			v.output = []jobs.LogChunk{{Stream: "stdout", Data: result.Stdout}, {Stream: "stderr", Data: result.Stderr}}
+		}
+	}
+}
+
+// draw renders the current view; lines end in \r\n because the terminal is raw
+func (v *topView) draw(fd int) {
+	width, height, err := term.GetSize(fd)
+	if err != nil || width < 40 || height < 10 {
+		width, height = 80, 24
+	}
+
+	var lines []string
+	if v.following != "" {
+		lines = v.outputLines(width, height)
+	} else {
+		lines = v.overviewLines(width, height)
+	}
+
+	var b strings.Builder
+	b.WriteString(ansiClear)
+	for i, line := range lines {
+		if i >= height-1 {
+			break
+		}
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	b.WriteString(v.footer(width))
+	os.Stdout.WriteString(b.String())
+}
+
+func (v *topView) overviewLines(width, height int) []string {
+	lines := []string{ansiBold + fit(fmt.Sprintf("orchcli top — %s — %s", v.api.baseURL, v.fetched.Format(time.TimeOnly)), width) + ansiReset}
+	if v.err != nil {
+		lines = append(lines, ansiRed+fit("controller unreachable: "+v.err.Error(), width)+ansiReset)
+	}
+
+	queueParts := make([]string, 0, len(v.queues))
+	for _, q := range v.queues {
+		queueParts = append(queueParts, fmt.Sprintf("%s=%d (w%d)", q.Name, q.Depth, q.Weight))
+	}
+	lines = append(lines, "", ansiBold+"QUEUES"+ansiReset+"  "+fit(strings.Join(queueParts, "  "), width-8))
+
+	engines, used := ansiBold+"ENGINES"+ansiReset, len("ENGINES")
+	for _, e := range v.engines {
+		color, state := ansiGreen, "up"
+		if !e.Healthy {
+			color, state = ansiRed, "stale "+time.Since(e.LastSeen).Round(time.Second).String()
+		}
+		plain := fmt.Sprintf("  %s %s, %d running", e.ID, state, e.Running)
+		if used+len(plain) > width {
+			engines += " …"
+			break
+		}
+		engines += fmt.Sprintf("  %s%s%s %s, %d running", color, e.ID, ansiReset, state, e.Running)
+		used += len(plain)
+	}
+	if len(v.engines) == 0 {
+		engines += "  " + ansiDim + "no engines have polled yet" + ansiReset
+	}
+	lines = append(lines, engines)
+
+	// Running jobs get priority for space; completions fill whatever is left
+	budget := height - len(lines) - 7
+	shownRunning := min(len(v.running), max(budget-min(len(v.recent), 5), budget/2))
+	shownRecent := min(len(v.recent), max(budget-shownRunning, 0))
+
+	header := fmt.Sprintf("  %-24s %-10s %-20s %-9s %-16s %s", "ID", "STATUS", "HOST", "ELAPSED", "ENGINE", "COMMAND")
+	lines = append(lines, "", fmt.Sprintf("%sRUNNING%s (%d)", ansiBold, ansiReset, len(v.running)), ansiDim+fit(header, width)+ansiReset)
+	for i, s := range v.running[:shownRunning] {
+		lines = append(lines, v.jobLine(i, s, s.Engine, width))
+	}
+
+	header = fmt.Sprintf("  %-24s %-10s %-20s %-9s %-16s %s", "ID", "STATUS", "HOST", "TOOK", "EXIT", "COMMAND")
+	lines = append(lines, "", fmt.Sprintf("%sRECENT%s", ansiBold, ansiReset), ansiDim+fit(header, width)+ansiReset)
+	for i, s := range v.recent[:shownRecent] {
+		lines = append(lines, v.jobLine(len(v.running)+i, s, strconv.Itoa(s.ExitCode), width))
+	}
+
+	return lines
+}
+
+// jobLine formats one job row; the status column is colored after padding so widths stay aligned
+func (v *topView) jobLine(index int, s jobs.JobSummary, extra string, width int) string {
+	marker := "  "
+	if index == v.selected {
+		marker = ansiBold + "> " + ansiReset
+	}
+
+	rest := fmt.Sprintf(" %-20s %-9s %-16s %s", fit(s.TargetHost, 20), duration(s), fit(extra, 16), s.Command)
+	status := fmt.Sprintf("%-10s", s.Status)
+	if s.CancelRequested && !s.Status.Final() {
+		status = fmt.Sprintf("%-10s", "cancelling")
+	}
+
+	return marker + fmt.Sprintf("%-24s ", fit(s.ID, 24)) + statusColor(s.Status) + status + ansiReset + fit(rest, max(width-37, 0))
+}
+
+func (v *topView) outputLines(width, height int) []string {
+	// Rebuild whole lines from chunks, which may end mid-line, remembering which stream started each
+	type outLine struct {
+		text, stream string
+		open         bool
+	}
+	var all []outLine
+	for _, chunk := range v.output {
+		for _, piece := range strings.SplitAfter(chunk.Data, "\n") {
+			if piece == "" {
+				continue
+			}
+			if n := len(all); n > 0 && all[n-1].open {
+				all[n-1].text += piece
+			} else {
+				all = append(all, outLine{text: piece, stream: chunk.Stream})
+			}
+			all[len(all)-1].open = !strings.HasSuffix(piece, "\n")
+		}
+	}
+
+	state := "streaming"
+	if v.outDone {
+		state = "finished"
+	}
+	lines := []string{ansiBold + fit(fmt.Sprintf("output of %s (%s)", v.following, state), width) + ansiReset, ""}
+
+	// Show the tail that fits on screen
+	for _, l := range all[max(len(all)-(height-3), 0):] {
+		text := fit(strings.TrimRight(l.text, "\r\n"), width)
+		if l.stream == "stderr" {
+			text = ansiRed + text + ansiReset
+		}
+		lines = append(lines, text)
+	}
+
+	return lines
+}
+
+func (v *topView) footer(width int) string {
+	switch {
+	case v.confirm != "":
+		return ansiYellow + fit(fmt.Sprintf("cancel %s? [y/N]", v.confirm), width) + ansiReset
+	case v.message != "":
+		return ansiYellow + fit(v.message, width) + ansiReset
+	case v.following != "":
+		return ansiDim + fit("esc/q back  c cancel job", width) + ansiReset
+	default:
+		return ansiDim + fit("↑/↓ or j/k select  enter open output  c cancel  q quit", width) + ansiReset
+	}
+}
+
+func statusColor(s jobs.Status) string {
+	switch s {
+	case jobs.StatusSucceeded:
+		return ansiGreen
+	case jobs.StatusFailed, jobs.StatusTimedOut:
+		return ansiRed
+	case jobs.StatusCancelled, jobs.StatusExpired, jobs.StatusSkipped:
+		return ansiYellow
+	case jobs.StatusRunning:
+		return ansiCyan
+	default:
+		return ""
+	}
+}
+
+// fit truncates s to n runes, marking the cut with an ellipsis
+func fit(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(r[:max(n, 0)])
+	}
+
+	return string(r[:n-1]) + "…"
+}
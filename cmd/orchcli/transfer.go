@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// runUpload implements `orchcli upload --host H [flags] LOCAL REMOTE`: the file is stored on the
+// controller as an artifact of the job, then the engine copies it to REMOTE over SFTP
+func runUpload(args []string) {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	var creds credFlags
+	creds.register(fs)
+	host := fs.String("host", "", "target host or inventory name")
+	user := fs.String("user", "", "target user")
+	port := fs.Int("port", 0, "SSH port (default 22 or the inventory port)")
+	mode := fs.String("mode", "", "octal permissions for the remote file, e.g. 0644")
+	owner := fs.String("owner", "", "numeric uid[:gid] for the remote file")
+	jobID := fs.String("id", "", "job ID (random when omitted)")
+	storeOnly := fs.Bool("store-only", false, "only store the payload under the job ID, for jobs that reference it with artifact_job")
+	detach := fs.Bool("detach", false, "print the job ID and return without waiting")
+	rest := parseArgs(fs, args)
+
+	if *storeOnly && len(rest) != 1 || !*storeOnly && (len(rest) != 2 || *host == "") {
+		fail(exitUsage, "usage: orchcli upload --host H [--user U] [--mode 0644] [--owner UID[:GID]] LOCAL REMOTE\n       orchcli upload --store-only [--id ID] LOCAL")
+	}
+
+	local := rest[0]
+	name := filepath.Base(local)
+	if jobs.ValidateArtifactName(name) != nil {
+		name = "payload"
+	}
+	id := ensureJobID(*jobID)
+	artifact := putPayload(conn.api(), id, name, local)
+
+	if *storeOnly {
+		emit(conn.output, artifact, func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "Job:\t%s\n", id)
+			fmt.Fprintf(w, "Artifact:\t%s\n", artifact.Name)
+			fmt.Fprintf(w, "Size:\t%d\n", artifact.Size)
+			fmt.Fprintf(w, "SHA256:\t%s\n", artifact.SHA256)
+		})
+		return
+	}
+
+	job := jobs.JobDefinition{
+		ID:         id,
+		TargetHost: *host,
+		TargetUser: *user,
+		TargetPort: *port,
+		Type:       jobs.JobUpload,
+		Transfer: &jobs.FileTransfer{
+			RemotePath: rest[1],
+			Artifact:   name,
+			Mode:       *mode,
+			Owner:      *owner,
+			SHA256:     artifact.SHA256,
+		},
+	}
+	submitAndWait(&conn, &creds, job, *detach)
+}
+
+// runDownload implements `orchcli download --host H [flags] REMOTE [LOCAL]`: the engine copies REMOTE
+// into a job artifact over SFTP, which is then saved to LOCAL ("-" for stdout)
+func runDownload(args []string) {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	var conn connFlags
+	conn.register(fs)
+	var creds credFlags
+	creds.register(fs)
+	host := fs.String("host", "", "target host or inventory name")
+	user := fs.String("user", "", "target user")
+	port := fs.Int("port", 0, "SSH port (default 22 or the inventory port)")
+	sum := fs.String("sha256", "", "expected SHA-256 of the remote file")
+	jobID := fs.String("id", "", "job ID (random when omitted)")
+	rest := parseArgs(fs, args)
+
+	if len(rest) == 0 || len(rest) > 2 || *host == "" {
+		fail(exitUsage, "usage: orchcli download --host H [--user U] [--sha256 HEX] REMOTE [LOCAL]")
+	}
+
+	remote := rest[0]
+	local := path.Base(remote)
+	if len(rest) == 2 {
+		local = rest[1]
+	}
+
+	job := jobs.JobDefinition{
+		ID:         ensureJobID(*jobID),
+		TargetHost: *host,
+		TargetUser: *user,
+		TargetPort: *port,
+		Type:       jobs.JobDownload,
+		Transfer:   &jobs.FileTransfer{RemotePath: remote, SHA256: *sum},
+	}
+	targetUser, bundle, err := creds.resolve(conn.settings(), job)
+	if err != nil {
+		fail(exitUsage, "credentials: %v", err)
+	}
+	job.TargetUser = targetUser
+	job.Credentials = bundle
+	job.Checksum = ensureChecksum(job.ChecksumInput())
+
+	out := runOnHost(conn.api(), job, false)
+	if out.err != nil {
+		fail(exitUnavailable, "%s: %v", out.host, out.err)
+	}
+	if out.result.Status != jobs.StatusSucceeded || len(out.result.Artifacts) == 0 {
+		emit(conn.output, out.result, func(w *tabwriter.Writer) {
+			resultTable(w, out.result)
+		})
+		os.Exit(max(exitCodeFor(out.result), exitFailure))
+	}
+
+	artifact := out.result.Artifacts[0]
+	n := saveArtifact(conn.api(), job.ID, artifact, local)
+	if local != "-" {
+		log.Printf("saved %d bytes from %s:%s to %s (sha256 %s)", n, job.TargetHost, remote, local, artifact.SHA256)
+	}
+}
+
+// putPayload stores a local file as a job artifact and checks the controller recorded the same digest
+func putPayload(api *apiClient, jobID, name, local string) jobs.Artifact {
+	f, err := os.Open(filepath.Clean(local))
+	if err != nil {
+		fail(exitUsage, "open %s: %v", local, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	artifact, err := api.upload(jobPath(jobID, "artifacts", url.PathEscape(name)), io.TeeReader(f, hash))
+	if err != nil {
+		fail(exitUnavailable, "store %s: %v", local, err)
+	}
+	if sent := hex.EncodeToString(hash.Sum(nil)); artifact.SHA256 != sent {
+		fail(exitUnavailable, "controller stored sha256 %s for %s, sent %s", artifact.SHA256, local, sent)
+	}
+
+	return artifact
+}
+
+// saveArtifact copies a job artifact to local ("-" for stdout) and verifies its digest
+func saveArtifact(api *apiClient, jobID string, artifact jobs.Artifact, local string) int64 {
+	body, err := api.download(jobPath(jobID, "artifacts", url.PathEscape(artifact.Name)))
+	if err != nil {
+		failRequest(err)
+	}
+	defer body.Close()
+
+	var dst io.Writer = os.Stdout
+	if local != "-" {
+		f, err := os.Create(filepath.Clean(local))
+		if err != nil {
+			fail(exitFailure, "create %s: %v", local, err)
+		}
+		defer f.Close()
+		dst = f
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(dst, hash), body)
+	if err != nil {
+		fail(exitFailure, "download %s: %v", artifact.Name, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != artifact.SHA256 {
+		fail(exitFailure, "%s has sha256 %s, expected %s", local, got, artifact.SHA256)
+	}
+
+	return n
+}
+
+// upload PUTs a request body to the controller and decodes the stored artifact
+func (a *apiClient) upload(path string, body io.Reader) (jobs.Artifact, error) {
+	req, err := http.NewRequest(http.MethodPut, a.baseURL+path, body)
+	if err != nil {
+		return jobs.Artifact{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// Payloads can be large, so the per-request timeout does not apply
+	client := *a.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return jobs.Artifact{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return jobs.Artifact{}, fmt.Errorf("controller returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var artifact jobs.Artifact
+	if err := json.NewDecoder(resp.Body).Decode(&artifact); err != nil {
+		return jobs.Artifact{}, err
+	}
+
+	return artifact, nil
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// Lint severities, named after SARIF levels
+const (
+	levelError   = "error"
+	levelWarning = "warning"
+)
+
+// lintRules describes every check `orchcli validate` runs, keyed by rule ID
+var lintRules = map[string]string{
+	"parse":             "file could not be parsed as JSON, JSON lines or YAML",
+	"invalid-job":       "job fails the controller's JobDefinition.Validate",
+	"unknown-field":     "field is not part of the job schema and will be ignored",
+	"port-range":        "target_port must be between 1 and 65535",
+	"invalid-hostname":  "target_host is neither a valid host name nor an IP address",
+	"relative-command":  "command is not an absolute path",
+	"checksum-mismatch": "checksum does not match the sha256 of the command or transfer",
+	"duplicate-id":      "job ID is used more than once",
+	"policy-blocked":    "command or script interpreter is not in the engine allowlist",
+	"timeout-capped":    "timeout_seconds exceeds the engine job timeout and will be capped",
+	"inline-password":   "job file stores a plaintext password",
+	"inline-secret-env": "job file stores the value of a secret environment variable",
+	"inline-secret":     "job file stores the value of an expect secret",
+}
+
+// finding is one problem reported by `orchcli validate`
+type finding struct {
+	File    string
+	Line    int
+	Field   string
+	Rule    string
+	Level   string
+	Message string
+}
+
+// enginePolicy is the subset of the engine configuration the linter checks commands against.
+// Both the full engine.yaml layout and a bare top-level allowed_commands list are accepted
+type enginePolicy struct {
+	Execution struct {
+		AllowedCommands   []string `yaml:"allowed_commands"`
+		JobTimeoutSeconds int      `yaml:"job_timeout_seconds"`
+	} `yaml:"execution"`
+	AllowedCommands []string `yaml:"allowed_commands"`
+}
+
+func loadPolicy(file string) (map[string]struct{}, int, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var p enginePolicy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, 0, fmt.Errorf("parse %s: %w", file, err)
+	}
+
+	allow := make(map[string]struct{})
+	for _, cmd := range append(p.Execution.AllowedCommands, p.AllowedCommands...) {
+		if cmd = strings.TrimSpace(cmd); cmd != "" {
+			allow[cmd] = struct{}{}
+		}
+	}
+
+	return allow, p.Execution.JobTimeoutSeconds, nil
+}
+
+// runValidate implements `orchcli validate [--policy engine.yaml] FILE...`
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	policyFile := fs.String("policy", "", "engine config or allowlist file to check commands against")
+	strict := fs.Bool("strict", false, "exit non-zero on warnings as well as errors")
+	output := fs.String("o", formatTable, "output format: table, json or yaml (json and yaml are SARIF-shaped)")
+	files := parseArgs(fs, args)
+
+	if len(files) == 0 {
+		fail(exitUsage, "usage: orchcli validate [--policy FILE] FILE...")
+	}
+	if !validFormat(*output) {
+		fail(exitUsage, "unknown output format %q", *output)
+	}
+
+	var allow map[string]struct{}
+	var engineTimeout int
+	if *policyFile != "" {
+		var err error
+		if allow, engineTimeout, err = loadPolicy(*policyFile); err != nil {
+			fail(exitUsage, "load policy: %v", err)
+		}
+	}
+
+	var findings []finding
+	seen := make(map[string]jobDoc)
+	for _, file := range files {
+		docs, err := loadJobs(file)
+		if err != nil {
+			findings = append(findings, finding{File: file, Line: 1, Rule: "parse", Level: levelError, Message: err.Error()})
+			continue
+		}
+
+		for _, doc := range docs {
+			findings = append(findings, lintJob(doc, allow, engineTimeout)...)
+
+			if doc.job.ID == "" {
+				continue
+			}
+			if prev, dup := seen[doc.job.ID]; dup {
+				findings = append(findings, doc.finding("id", "duplicate-id", levelError,
+					fmt.Sprintf("job ID %s already used at %s", doc.job.ID, prev.position())))
+				continue
+			}
+			seen[doc.job.ID] = doc
+		}
+	}
+
+	emit(*output, sarifReport(findings), func(w *tabwriter.Writer) {
+		for _, f := range findings {
+			message := f.Message
+			if f.Field != "" {
+				message = f.Field + ": " + message
+			}
+			fmt.Fprintf(w, "%s:%d\t%s\t%s\t[%s]\n", f.File, f.Line, f.Level, message, f.Rule)
+		}
+	})
+
+	errorsFound, warnings := 0, 0
+	for _, f := range findings {
+		if f.Level == levelError {
+			errorsFound++
+		} else {
+			warnings++
+		}
+	}
+	if *output == formatTable {
+		fmt.Fprintf(os.Stderr, "%d error(s), %d warning(s) in %d file(s)\n", errorsFound, warnings, len(files))
+	}
+	if errorsFound > 0 || (*strict && warnings > 0) {
+		os.Exit(exitUsage)
+	}
+}
+
+// lintJob runs every per-document check
+func lintJob(doc jobDoc, allow map[string]struct{}, engineTimeout int) []finding {
+	job := doc.job
+	var out []finding
+
+	out = append(out, unknownFields(doc, doc.node, reflect.TypeOf(jobs.JobDefinition{}), "")...)
+
+	// Validate the job the way `orchcli submit` would send it, with the fields it fills in supplied
+	filled := job
+	filled.ID = ensureJobID(job.ID)
+	filled.TargetUser = firstNonEmpty(job.TargetUser, "placeholder")
+	filled.Checksum = firstNonEmpty(job.Checksum, "placeholder")
+	filled.Credentials = jobs.CredentialBundle{Username: "placeholder", Password: "placeholder"}
+	if err := filled.Validate(); err != nil {
+		out = append(out, doc.finding("", "invalid-job", levelError, err.Error()))
+	}
+
+	if job.TargetPort < 0 || job.TargetPort > 65535 {
+		out = append(out, doc.finding("target_port", "port-range", levelError,
+			fmt.Sprintf("port %d out of range", job.TargetPort)))
+	}
+	if job.TargetHost != "" && !validHost(job.TargetHost) {
+		out = append(out, doc.finding("target_host", "invalid-hostname", levelError,
+			fmt.Sprintf("%q is not a valid host name or IP address", job.TargetHost)))
+	}
+	if job.Command != "" && !path.IsAbs(job.Command) {
+		out = append(out, doc.finding("command", "relative-command", levelWarning,
+			fmt.Sprintf("%q is not an absolute path; the remote PATH decides what runs", job.Command)))
+	}
+	if job.Checksum != "" {
+		sum := sha256.Sum256([]byte(job.ChecksumInput()))
+		if !strings.EqualFold(job.Checksum, hex.EncodeToString(sum[:])) {
+			out = append(out, doc.finding("checksum", "checksum-mismatch", levelError,
+				"checksum does not match the job's command or transfer; the engine will refuse the job"))
+		}
+	}
+	if program := job.Program(); allow != nil && program != "" {
+		if _, ok := allow[program]; !ok {
+			field := "command"
+			if job.Kind() == jobs.JobScript {
+				field = "script.interpreter"
+			}
+			out = append(out, doc.finding(field, "policy-blocked", levelError,
+				fmt.Sprintf("%s is not in the engine allowlist", program)))
+		}
+	}
+	if engineTimeout > 0 && job.TimeoutSeconds > engineTimeout {
+		out = append(out, doc.finding("timeout_seconds", "timeout-capped", levelWarning,
+			fmt.Sprintf("timeout %ds will be capped to the engine's %ds", job.TimeoutSeconds, engineTimeout)))
+	}
+	if job.Credentials.Password != "" {
+		out = append(out, doc.finding("credentials.password", "inline-password", levelWarning,
+			"plaintext password in job file; prefer --password-file or a profile credential"))
+	}
+	for _, name := range job.SecretEnv {
+		if job.Env[name] != "" {
+			out = append(out, doc.finding("env."+name, "inline-secret-env", levelWarning,
+				"secret value in job file; prefer orchcli exec --secret-env NAME to copy it from the environment"))
+		}
+	}
+	names := make([]string, 0, len(job.Secrets))
+	for name, value := range job.Secrets {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out = append(out, doc.finding("secrets."+name, "inline-secret", levelWarning,
+			"secret value in job file; prefer orchcli submit --secret NAME to copy it from the environment"))
+	}
+
+	return out
+}
+
+// unknownFields compares a mapping's keys with the yaml tags of t, recursing into nested structs
+func unknownFields(doc jobDoc, node *yaml.Node, t reflect.Type, prefix string) []finding {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	known := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		known[name] = t.Field(i).Type
+	}
+
+	var out []finding
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		ft, ok := known[key.Value]
+		if !ok {
+			out = append(out, finding{File: doc.file, Line: key.Line, Field: prefix + key.Value,
+				Rule: "unknown-field", Level: levelError, Message: "unknown field"})
+			continue
+		}
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft.NumField() > 0 && ft.PkgPath() == t.PkgPath() {
+			out = append(out, unknownFields(doc, value, ft, prefix+key.Value+".")...)
+		}
+		// Lists of structs, such as expect steps, are checked item by item
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct && ft.Elem().PkgPath() == t.PkgPath() && value.Kind == yaml.SequenceNode {
+			for n, item := range value.Content {
+				out = append(out, unknownFields(doc, item, ft.Elem(), fmt.Sprintf("%s%s[%d].", prefix, key.Value, n))...)
+			}
+		}
+	}
+
+	return out
+}
+
+// finding builds a finding located at field within the document, or at the document start
+func (d jobDoc) finding(field, rule, level, message string) finding {
+	return finding{File: d.file, Line: d.fieldLine(field), Field: field, Rule: rule, Level: level, Message: message}
+}
+
+// fieldLine finds the line of a dotted field path, falling back to the document start
+func (d jobDoc) fieldLine(field string) int {
+	node := d.node
+	line := d.line
+	for _, part := range strings.Split(field, ".") {
+		if node == nil || node.Kind != yaml.MappingNode || part == "" {
+			break
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == part {
+				line, next = node.Content[i].Line, node.Content[i+1]
+				break
+			}
+		}
+		node = next
+	}
+
+	return line
+}
+
+var hostLabelRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// validHost accepts IP addresses and RFC 1123 host names
+func validHost(host string) bool {
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return true
+	}
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if !hostLabelRe.MatchString(label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SARIF-shaped report so CI systems that ingest SARIF can annotate job files
+type sarifLog struct {
+	Version string     `json:"version" yaml:"version"`
+	Runs    []sarifRun `json:"runs" yaml:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool" yaml:"tool"`
+	Results []sarifResult `json:"results" yaml:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name  string      `json:"name" yaml:"name"`
+		Rules []sarifRule `json:"rules" yaml:"rules"`
+	} `json:"driver" yaml:"driver"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id" yaml:"id"`
+	ShortDescription sarifMessage `json:"shortDescription" yaml:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text" yaml:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId" yaml:"ruleId"`
+	Level     string          `json:"level" yaml:"level"`
+	Message   sarifMessage    `json:"message" yaml:"message"`
+	Locations []sarifLocation `json:"locations" yaml:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri" yaml:"uri"`
+		} `json:"artifactLocation" yaml:"artifactLocation"`
+		Region struct {
+			StartLine int `json:"startLine" yaml:"startLine"`
+		} `json:"region" yaml:"region"`
+	} `json:"physicalLocation" yaml:"physicalLocation"`
+	// LogicalLocations carries the job field the finding is about, when there is one
+	LogicalLocations []sarifLogical `json:"logicalLocations,omitempty" yaml:"logicalLocations,omitempty"`
+}
+
+type sarifLogical struct {
+	FullyQualifiedName string `json:"fullyQualifiedName" yaml:"fullyQualifiedName"`
+}
+
+func sarifReport(findings []finding) sarifLog {
+	var run sarifRun
+	run.Tool.Driver.Name = "orchcli validate"
+	ids := make([]string, 0, len(lintRules))
+	for id := range lintRules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: id, ShortDescription: sarifMessage{lintRules[id]}})
+	}
+
+	run.Results = make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(f.File)
+		loc.PhysicalLocation.Region.StartLine = f.Line
+		if f.Field != "" {
+			loc.LogicalLocations = []sarifLogical{{f.Field}}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Rule,
+			Level:     f.Level,
+			Message:   sarifMessage{f.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+
+	return sarifLog{Version: "2.1.0", Runs: []sarifRun{run}}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// lintFindings parses one YAML document and lints it, rendering findings as rule@line or rule@line:field
+func lintFindings(t *testing.T, data string, allow map[string]struct{}, engineTimeout int) string {
+	t.Helper()
+	docs, err := parseYAMLJobs("job.yaml", []byte(data))
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("parse %q: %d documents, %v", data, len(docs), err)
+	}
+
+	var out []string
+	for _, f := range lintJob(docs[0], allow, engineTimeout) {
+		out = append(out, findingKey(f))
+	}
+	sort.Strings(out)
+	return strings.Join(out, " ")
+}
+
+func findingKey(f finding) string {
+	key := fmt.Sprintf("%s@%d", f.Rule, f.Line)
+	if f.Field != "" {
+		key += ":" + f.Field
+	}
+	return key
+}
+
+func TestLintJob(t *testing.T) {
+	const base = "id: a\ntarget_host: web01\ncommand: /usr/bin/uptime\n"
+	tests := []struct {
+		name    string
+		data    string
+		allow   []string
+		timeout int
+		want    string
+	}{
+		{name: "clean", data: base},
+		{name: "invalid job", data: "id: a\ncommand: /usr/bin/uptime\n", want: "invalid-job@1"},
+		{name: "port range", data: base + "target_port: 70000\n", want: "port-range@4:target_port"},
+		{name: "invalid hostname", data: "id: a\ncommand: /usr/bin/uptime\ntarget_host: bad_host!\n", want: "invalid-hostname@3:target_host"},
+		{name: "ip address host", data: "id: a\ntarget_host: \"[::1]\"\ncommand: /usr/bin/uptime\n"},
+		{name: "relative command", data: "id: a\ntarget_host: web01\ncommand: uptime\n", want: "relative-command@3:command"},
+		{name: "checksum mismatch", data: base + "checksum: deadbeef\n", want: "checksum-mismatch@4:checksum"},
+		{name: "policy allows", data: base, allow: []string{"/usr/bin/uptime"}},
+		{name: "policy blocks", data: base, allow: []string{"/usr/bin/df"}, want: "policy-blocked@3:command"},
+		{name: "timeout within the engine cap", data: base + "timeout_seconds: 300\n", timeout: 300},
+		{name: "timeout capped", data: base + "timeout_seconds: 600\n", timeout: 300, want: "timeout-capped@4:timeout_seconds"},
+		{
+			name: "inline password",
+			data: base + "credentials:\n  username: ops\n  password: hunter2\n",
+			want: "inline-password@6:credentials.password",
+		},
+		{
+			name: "inline secret env",
+			data: base + "env:\n  REGION: eu\n  TOKEN: abc\nsecret_env: [TOKEN]\n",
+			want: "inline-secret-env@6:env.TOKEN",
+		},
+		{
+			name: "inline expect secrets",
+			data: base + "allow_tty: true\nexpect:\n  - expect: 'Password:'\n    send_secret: sudo\nsecrets:\n  sudo: x\n  db: y\n  unset: \"\"\n",
+			want: "inline-secret@10:secrets.db inline-secret@9:secrets.sudo",
+		},
+	}
+	for _, tt := range tests {
+		var allow map[string]struct{}
+		if tt.allow != nil {
+			allow = make(map[string]struct{})
+			for _, cmd := range tt.allow {
+				allow[cmd] = struct{}{}
+			}
+		}
+		if got := lintFindings(t, tt.data, allow, tt.timeout); got != tt.want {
+			t.Errorf("%s: findings %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string, []byte) ([]jobDoc, error)
+		data  string
+		want  string // findings of the last document
+	}{
+		{
+			name:  "top level",
+			parse: parseYAMLJobs,
+			data:  "id: a\ntarget_host: web01\ncomand: /usr/bin/uptime\n",
+			want:  "unknown-field@3:comand",
+		},
+		{
+			name:  "nested struct",
+			parse: parseYAMLJobs,
+			data:  "id: a\ntarget_host: web01\ncommand: /usr/bin/uptime\ncredentials:\n  username: ops\n  passwd: x\n",
+			want:  "unknown-field@6:credentials.passwd",
+		},
+		{
+			name:  "list of structs",
+			parse: parseYAMLJobs,
+			data:  "id: a\ntarget_host: web01\ncommand: /usr/bin/uptime\nallow_tty: true\nexpect:\n  - expect: a\n    send: b\n  - expect: c\n    sned: d\n",
+			want:  "unknown-field@9:expect[1].sned",
+		},
+		{
+			name:  "later yaml document",
+			parse: parseYAMLJobs,
+			data:  "id: a\n---\nid: b\n\ntarget_hots: web01\n",
+			want:  "unknown-field@5:target_hots",
+		},
+		{
+			name:  "json lines",
+			parse: parseJSONLines,
+			data:  "{\"id\": \"a\"}\n\n{\"id\": \"b\", \"colour\": \"red\"}\n",
+			want:  "unknown-field@3:colour",
+		},
+		{
+			name:  "json array",
+			parse: parseJSONJobs,
+			data:  "[\n  {\"id\": \"a\"},\n  {\n    \"id\": \"b\",\n    \"script\": {\"interpreter\": \"/bin/sh\", \"bdy\": \"x\"}\n  }\n]\n",
+			want:  "unknown-field@5:script.bdy",
+		},
+		{
+			name:  "concatenated json",
+			parse: parseJSONJobs,
+			data:  "{\"id\": \"a\"}\n{\n  \"id\": \"b\",\n  \"prio\": 1\n}\n",
+			want:  "unknown-field@4:prio",
+		},
+		{
+			name:  "map values are not fields",
+			parse: parseYAMLJobs,
+			data:  "id: a\nmetadata:\n  owner: ops\nenv:\n  ANYTHING: x\n",
+		},
+	}
+	for _, tt := range tests {
+		docs, err := tt.parse("jobs", []byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		doc := docs[len(docs)-1]
+
+		var got []string
+		for _, f := range lintJob(doc, nil, 0) {
+			if f.Rule == "unknown-field" {
+				got = append(got, findingKey(f))
+			}
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%s: unknown fields %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSARIFReport(t *testing.T) {
+	findings := []finding{
+		{File: "jobs/deploy.yaml", Line: 4, Field: "command", Rule: "relative-command", Level: levelWarning, Message: "not absolute"},
+		{File: "broken.json", Line: 1, Rule: "parse", Level: levelError, Message: "unexpected end of JSON input"},
+	}
+	data, err := json.Marshal(sarifReport(findings))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID               string `json:"id"`
+						ShortDescription struct {
+							Text string `json:"text"`
+						} `json:"shortDescription"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID  string `json:"ruleId"`
+				Level   string `json:"level"`
+				Message struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+					LogicalLocations []struct {
+						FullyQualifiedName string `json:"fullyQualifiedName"`
+					} `json:"logicalLocations"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("SARIF version %q with %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+
+	// Every rule is described once, in ID order
+	if len(run.Tool.Driver.Rules) != len(lintRules) {
+		t.Errorf("%d rules described, want %d", len(run.Tool.Driver.Rules), len(lintRules))
+	}
+	for i, rule := range run.Tool.Driver.Rules {
+		if rule.ShortDescription.Text != lintRules[rule.ID] {
+			t.Errorf("rule %s described as %q", rule.ID, rule.ShortDescription.Text)
+		}
+		if i > 0 && rule.ID <= run.Tool.Driver.Rules[i-1].ID {
+			t.Errorf("rule %s listed after %s", rule.ID, run.Tool.Driver.Rules[i-1].ID)
+		}
+	}
+
+	if len(run.Results) != len(findings) {
+		t.Fatalf("%d results, want %d", len(run.Results), len(findings))
+	}
+	tests := []struct {
+		rule, level, message, uri string
+		line                      int
+		field                     string
+	}{
+		{"relative-command", levelWarning, "not absolute", "jobs/deploy.yaml", 4, "command"},
+		{"parse", levelError, "unexpected end of JSON input", "broken.json", 1, ""},
+	}
+	for i, tt := range tests {
+		r := run.Results[i]
+		if r.RuleID != tt.rule || r.Level != tt.level || r.Message.Text != tt.message || len(r.Locations) != 1 {
+			t.Errorf("result %d = %+v", i, r)
+			continue
+		}
+		loc := r.Locations[0]
+		if loc.PhysicalLocation.ArtifactLocation.URI != tt.uri || loc.PhysicalLocation.Region.StartLine != tt.line {
+			t.Errorf("result %d located at %s:%d, want %s:%d", i,
+				loc.PhysicalLocation.ArtifactLocation.URI, loc.PhysicalLocation.Region.StartLine, tt.uri, tt.line)
+		}
+		var field string
+		if len(loc.LogicalLocations) > 0 {
+			field = loc.LogicalLocations[0].FullyQualifiedName
+		}
+		if field != tt.field {
+			t.Errorf("result %d logical location %q, want %q", i, field, tt.field)
+		}
+	}
+}
@@ -3,9 +3,13 @@ module github.com/Jeremiahtaylor2017/orchestration_engine
 go 1.24
 
 require (
-	golang.org/x/crypto v0.23.0
-	golang.org/x/term v0.20.0
+	github.com/pkg/sftp v1.13.9
+	golang.org/x/crypto v0.31.0
+	golang.org/x/term v0.27.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.20.0 // indirect
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+)
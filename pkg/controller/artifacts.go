@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// ErrArtifactTooLarge is returned when an upload exceeds the store's size limit
+var ErrArtifactTooLarge = errors.New("artifact exceeds size limit")
+
+// ErrArtifactExists is returned when an upload names an artifact the job already has
+var ErrArtifactExists = errors.New("artifact already exists")
+
+// artifactIndexFile holds a job directory's metadata; artifact names cannot start with a dot
+const artifactIndexFile = ".index.json"
+
+// Artifacts keeps job files on disk, one directory per job, so large output and file payloads
+// stay out of controller memory. Only metadata is held in memory, and each job directory keeps
+// its own copy so the store survives a restart
+type Artifacts struct {
+	mu        sync.Mutex
+	dir       string
+	maxSize   int64
+	retention time.Duration             // 0 keeps artifacts forever
+	index     map[string]*artifactIndex // job ID -> metadata
+	uploading map[string]bool           // job ID/name of uploads in progress
+	now       func() time.Time
+}
+
+// artifactIndex is the metadata of one job directory, as persisted in artifactIndexFile
+type artifactIndex struct {
+	Updated   time.Time                `json:"updated"` // last upload, which retention counts from
+	Artifacts map[string]jobs.Artifact `json:"artifacts"`
+}
+
+// NewArtifacts stores artifacts under dir, rejecting any larger than maxSize bytes (0 means unlimited).
+// Artifacts already in dir are loaded from their job directories' indexes
+func NewArtifacts(dir string, maxSize int64) (*Artifacts, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("artifact dir: %w", err)
+	}
+
+	a := &Artifacts{
+		dir:       dir,
+		maxSize:   maxSize,
+		index:     make(map[string]*artifactIndex),
+		uploading: make(map[string]bool),
+		now:       time.Now,
+	}
+	if err := a.load(); err != nil {
+		return nil, fmt.Errorf("artifact dir: %w", err)
+	}
+
+	return a, nil
+}
+
+// SetRetention removes a job's artifacts once nothing was uploaded to it for d; 0 keeps them forever
+func (a *Artifacts) SetRetention(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.retention = d
+}
+
+// load reads every job directory's index, skipping directories without one
+func (a *Artifacts) load() error {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || jobs.ValidateArtifactName(entry.Name()) != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(a.dir, entry.Name(), artifactIndexFile))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		var idx artifactIndex
+		if err := json.Unmarshal(data, &idx); err != nil {
+			return fmt.Errorf("job %s index: %w", entry.Name(), err)
+		}
+		if idx.Artifacts == nil {
+			idx.Artifacts = make(map[string]jobs.Artifact)
+		}
+		a.index[entry.Name()] = &idx
+	}
+
+	return nil
+}
+
+// Put streams r to disk and records its size and SHA-256. Artifacts are written once: an upload
+// naming one the job already has fails with ErrArtifactExists
+func (a *Artifacts) Put(jobID, name, contentType string, r io.Reader) (jobs.Artifact, error) {
+	if err := jobs.ValidateArtifactName(name); err != nil {
+		return jobs.Artifact{}, err
+	}
+	if err := jobs.ValidateArtifactName(jobID); err != nil {
+		return jobs.Artifact{}, fmt.Errorf("job id: %w", err)
+	}
+	if err := a.reserve(jobID, name); err != nil {
+		return jobs.Artifact{}, err
+	}
+	defer a.unreserve(jobID, name)
+
+	jobDir := filepath.Join(a.dir, jobID)
+	if err := os.MkdirAll(jobDir, 0o700); err != nil {
+		return jobs.Artifact{}, err
+	}
+
+	tmp, err := os.CreateTemp(jobDir, ".upload-*")
+	if err != nil {
+		return jobs.Artifact{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if a.maxSize > 0 {
+		r = io.LimitReader(r, a.maxSize+1)
+	}
+	sum := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, sum), r)
+	if err != nil {
+		return jobs.Artifact{}, fmt.Errorf("store artifact %s: %w", name, err)
+	}
+	if a.maxSize > 0 && size > a.maxSize {
+		return jobs.Artifact{}, fmt.Errorf("%w of %d bytes", ErrArtifactTooLarge, a.maxSize)
+	}
+	if err := tmp.Close(); err != nil {
+		return jobs.Artifact{}, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(jobDir, name)); err != nil {
+		return jobs.Artifact{}, err
+	}
+
+	artifact := jobs.Artifact{Name: name, Size: size, SHA256: hex.EncodeToString(sum.Sum(nil)), ContentType: contentType}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	idx := a.index[jobID]
+	if idx == nil {
+		idx = &artifactIndex{Artifacts: make(map[string]jobs.Artifact)}
+		a.index[jobID] = idx
+	}
+	idx.Artifacts[name] = artifact
+	idx.Updated = a.now()
+	if err := a.saveLocked(jobID); err != nil {
+		delete(idx.Artifacts, name)
+		os.Remove(filepath.Join(jobDir, name))
+		return jobs.Artifact{}, fmt.Errorf("store artifact %s: %w", name, err)
+	}
+
+	return artifact, nil
+}
+
+// reserve claims jobID/name for one upload, failing if it is stored or being uploaded
+func (a *Artifacts) reserve(jobID, name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := jobID + "/" + name
+	if _, ok := a.getLocked(jobID, name); ok || a.uploading[key] {
+		return fmt.Errorf("%w: %s", ErrArtifactExists, key)
+	}
+	a.uploading[key] = true
+
+	return nil
+}
+
+func (a *Artifacts) unreserve(jobID, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.uploading, jobID+"/"+name)
+}
+
+// saveLocked writes a job's index next to its artifacts; caller must hold a.mu
+func (a *Artifacts) saveLocked(jobID string) error {
+	data, err := json.Marshal(a.index[jobID])
+	if err != nil {
+		return err
+	}
+
+	jobDir := filepath.Join(a.dir, jobID)
+	tmp, err := os.CreateTemp(jobDir, ".index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(jobDir, artifactIndexFile))
+}
+
+// Open returns the artifact's metadata and a reader the caller must close
+func (a *Artifacts) Open(jobID, name string) (jobs.Artifact, io.ReadCloser, bool) {
+	artifact, ok := a.Get(jobID, name)
+	if !ok {
+		return jobs.Artifact{}, nil, false
+	}
+
+	f, err := os.Open(filepath.Join(a.dir, jobID, name))
+	if err != nil {
+		return jobs.Artifact{}, nil, false
+	}
+
+	return artifact, f, true
+}
+
+// Get returns an artifact's metadata
+func (a *Artifacts) Get(jobID, name string) (jobs.Artifact, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.getLocked(jobID, name)
+}
+
+func (a *Artifacts) getLocked(jobID, name string) (jobs.Artifact, bool) {
+	idx := a.index[jobID]
+	if idx == nil {
+		return jobs.Artifact{}, false
+	}
+
+	artifact, ok := idx.Artifacts[name]
+	return artifact, ok
+}
+
+// List returns a job's artifacts sorted by name
+func (a *Artifacts) List(jobID string) []jobs.Artifact {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := []jobs.Artifact{}
+	if idx := a.index[jobID]; idx != nil {
+		for _, artifact := range idx.Artifacts {
+			out = append(out, artifact)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+}
+
+// Prune removes the artifacts of jobs nothing was uploaded to within the retention period
+func (a *Artifacts) Prune() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.retention <= 0 {
+		return
+	}
+	cutoff := a.now().Add(-a.retention)
+	for jobID, idx := range a.index {
+		if idx.Updated.After(cutoff) || a.uploadingLocked(jobID) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(a.dir, jobID)); err != nil {
+			continue
+		}
+		delete(a.index, jobID)
+	}
+}
+
+func (a *Artifacts) uploadingLocked(jobID string) bool {
+	for key := range a.uploading {
+		if filepath.Dir(key) == jobID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run prunes expired artifacts once a minute until stop is closed
+func (a *Artifacts) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			a.Prune()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArtifactsWriteOnce(t *testing.T) {
+	a, err := NewArtifacts(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewArtifacts: %v", err)
+	}
+
+	if _, err := a.Put("job1", "payload", "text/plain", strings.NewReader("first")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := a.Put("job1", "payload", "text/plain", strings.NewReader("second")); !errors.Is(err, ErrArtifactExists) {
+		t.Errorf("second Put error %v, want ErrArtifactExists", err)
+	}
+
+	_, body, ok := a.Open("job1", "payload")
+	if !ok {
+		t.Fatal("Open: not found")
+	}
+	defer body.Close()
+	if data, _ := io.ReadAll(body); string(data) != "first" {
+		t.Errorf("payload = %q after a rejected overwrite", data)
+	}
+}
+
+func TestArtifactsTooLarge(t *testing.T) {
+	a, err := NewArtifacts(t.TempDir(), 4)
+	if err != nil {
+		t.Fatalf("NewArtifacts: %v", err)
+	}
+
+	if _, err := a.Put("job1", "big", "", strings.NewReader("12345")); !errors.Is(err, ErrArtifactTooLarge) {
+		t.Errorf("Put error %v, want ErrArtifactTooLarge", err)
+	}
+	// A failed upload leaves the name free
+	if _, err := a.Put("job1", "big", "", strings.NewReader("1234")); err != nil {
+		t.Errorf("Put within the limit: %v", err)
+	}
+}
+
+func TestArtifactsReload(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewArtifacts(dir, 0)
+	if err != nil {
+		t.Fatalf("NewArtifacts: %v", err)
+	}
+	stored, err := a.Put("job1", "out.gz", "application/gzip", strings.NewReader("data"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	reloaded, err := NewArtifacts(dir, 0)
+	if err != nil {
+		t.Fatalf("NewArtifacts after restart: %v", err)
+	}
+	if got, ok := reloaded.Get("job1", "out.gz"); !ok || got != stored {
+		t.Errorf("Get after restart = %+v, %v, want %+v", got, ok, stored)
+	}
+	if _, err := reloaded.Put("job1", "out.gz", "", strings.NewReader("other")); !errors.Is(err, ErrArtifactExists) {
+		t.Errorf("Put after restart error %v, want ErrArtifactExists", err)
+	}
+}
+
+func TestArtifactsPrune(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewArtifacts(dir, 0)
+	if err != nil {
+		t.Fatalf("NewArtifacts: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	a.SetRetention(time.Hour)
+
+	a.Put("old", "a", "", strings.NewReader("a"))
+	now = now.Add(45 * time.Minute)
+	a.Put("new", "b", "", strings.NewReader("b"))
+	now = now.Add(30 * time.Minute)
+	a.Prune()
+
+	if _, ok := a.Get("old", "a"); ok {
+		t.Error("artifact past retention was kept")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); !os.IsNotExist(err) {
+		t.Errorf("job directory past retention still on disk: %v", err)
+	}
+	if _, ok := a.Get("new", "b"); !ok {
+		t.Error("artifact within retention was pruned")
+	}
+
+	a.SetRetention(0)
+	now = now.Add(24 * time.Hour)
+	a.Prune()
+	if _, ok := a.Get("new", "b"); !ok {
+		t.Error("retention 0 pruned an artifact")
+	}
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"1,,2 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := ParseCron(expr, ""); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+
+	if _, err := ParseCron("* * * * *", "Not/AZone"); err == nil {
+		t.Error("ParseCron with an unknown timezone succeeded, want an error")
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2026-03-04 is a Wednesday
+	from := time.Date(2026, 3, 4, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 4, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2026, 3, 4, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2026, 3, 4, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * fri", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jun *", time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either may match
+		{"0 0 20 * mon", time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		// One day field unrestricted, however spelled: both must match
+		{"0 0 20 * *", time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 20 * */1", time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 20 * 0-6", time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon", time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 */1 * mon", time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1-31 * mon", time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		// Restricted, even though it starts with *
+		{"0 0 */2 * mon", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr, "")
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextTimezone(t *testing.T) {
+	c, err := ParseCron("0 9 * * *", "America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// 9:00 EST is 14:00 UTC before the March switch to daylight saving and 13:00 UTC after it
+	if got, want := c.Next(time.Date(2026, 3, 6, 15, 0, 0, 0, time.UTC)), time.Date(2026, 3, 7, 14, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+	if got, want := c.Next(time.Date(2026, 3, 7, 15, 0, 0, 0, time.UTC)), time.Date(2026, 3, 8, 13, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestCronNextDaylightSavingGap(t *testing.T) {
+	c, err := ParseCron("30 2 * * *", "America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// 2:30 does not exist on 2026-03-08; the next activation is the following day
+	if got, want := c.Next(time.Date(2026, 3, 8, 5, 0, 0, 0, time.UTC)), time.Date(2026, 3, 9, 6, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestCronNextDaylightSavingFallBack(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// On 2026-11-01 the clocks go from 1:59 EDT (05:59 UTC) back to 1:00 EST (06:00 UTC)
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"30 1 * * *", time.Date(2026, 11, 1, 5, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC)},
+		// 1:30 EST repeats a wall time that already fired, so the next run is the following night
+		{"30 1 * * *", time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), time.Date(2026, 11, 2, 6, 30, 0, 0, time.UTC)},
+		{"*/30 * * * *", time.Date(2026, 11, 1, 5, 45, 0, 0, time.UTC), time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2026, 11, 1, 5, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC)},
+		// Wall times after the repeated hour are unaffected
+		{"0 2 * * *", time.Date(2026, 11, 1, 5, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC)},
+		{"30 1 * * *", time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 2, 6, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr, loc.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q from %v: Next = %v, want %v", tt.expr, tt.from, got.UTC(), tt.want)
+		}
+	}
+}
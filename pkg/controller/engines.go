@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// engineStaleAfter is how long an engine may go without polling or heartbeating before it is reported unhealthy.
+// Idle engines poll every few seconds and busy ones heartbeat through the log endpoint every second
+const engineStaleAfter = 30 * time.Second
+
+// EngineInfo reports an engine's liveness and load for operators
+type EngineInfo struct {
+	ID       string    `yaml:"id" json:"id"`
+	LastSeen time.Time `yaml:"last_seen" json:"last_seen"`
+	Running  int       `yaml:"running" json:"running"`
+	Healthy  bool      `yaml:"healthy" json:"healthy"`
+}
+
+// Heartbeat records that an engine talked to the controller. Requests without an engine ID are ignored
+func (s *Store) Heartbeat(engineID string) {
+	if engineID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.engines[engineID] = s.now()
+}
+
+// Engines lists every engine seen since the controller started with the jobs it is running
+func (s *Store) Engines() []EngineInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	running := make(map[string]int)
+	for _, rec := range s.records {
+		if rec.status == jobs.StatusRunning && rec.engine != "" {
+			running[rec.engine]++
+		}
+	}
+
+	now := s.now()
+	out := make([]EngineInfo, 0, len(s.engines))
+	for id, seen := range s.engines {
+		out = append(out, EngineInfo{
+			ID:       id,
+			LastSeen: seen,
+			Running:  running[id],
+			Healthy:  now.Sub(seen) < engineStaleAfter,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// FanOuts runs one job template across many hosts in rolling batches
+type FanOuts struct {
+	mu    sync.Mutex
+	store *Store
+	runs  map[string]*fanOutRun
+}
+
+type fanOutRun struct {
+	def     jobs.FanOutDefinition
+	state   jobs.WorkflowState
+	batches [][]string
+	batch   int // index of the batch currently running
+	hosts   map[string]*fanOutHost
+}
+
+type fanOutHost struct {
+	host     string
+	jobID    string
+	batch    int
+	status   jobs.Status
+	exitCode int
+	err      string
+}
+
+// NewFanOuts returns a fan-out manager that submits per-host jobs into store
+func NewFanOuts(store *Store) *FanOuts {
+	return &FanOuts{
+		store: store,
+		runs:  make(map[string]*fanOutRun),
+	}
+}
+
+// Submit validates a fan-out, expands it per host and launches the first batch
+func (f *FanOuts) Submit(def jobs.FanOutDefinition) (jobs.FanOutStatus, error) {
+	if def.Group != "" {
+		members, err := f.store.groupHosts(def.Group)
+		if err != nil {
+			return jobs.FanOutStatus{}, fmt.Errorf("fan-out %s: %w", def.ID, err)
+		}
+		def.Hosts = appendUnique(def.Hosts, members)
+	}
+
+	// Validate against the first host's inventory defaults; each child is resolved on enqueue
+	check := def
+	if len(def.Hosts) > 0 {
+		probe := def.Template
+		probe.TargetHost = def.Hosts[0]
+		resolved, err := f.store.resolve(probe)
+		if err != nil {
+			return jobs.FanOutStatus{}, fmt.Errorf("fan-out %s: %w", def.ID, err)
+		}
+		check.Template = resolved
+	}
+	if err := check.Validate(); err != nil {
+		return jobs.FanOutStatus{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.runs[def.ID]; exists {
+		return jobs.FanOutStatus{}, fmt.Errorf("fan-out %s already exists", def.ID)
+	}
+
+	run := &fanOutRun{
+		def:     def,
+		state:   jobs.WorkflowRunning,
+		batches: def.Batches(),
+		hosts:   make(map[string]*fanOutHost, len(def.Hosts)),
+	}
+	for i, batch := range run.batches {
+		for _, host := range batch {
+			run.hosts[host] = &fanOutHost{
+				host:   host,
+				jobID:  fmt.Sprintf("%s-%s", def.ID, host),
+				batch:  i + 1,
+				status: jobs.StatusWaiting,
+			}
+		}
+	}
+	f.runs[def.ID] = run
+
+	f.launch(run)
+
+	return run.status(), nil
+}
+
+// Status returns the aggregate result of a fan-out
+func (f *FanOuts) Status(id string) (jobs.FanOutStatus, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	run, ok := f.runs[id]
+	if !ok {
+		return jobs.FanOutStatus{}, false
+	}
+
+	return run.status(), true
+}
+
+// List returns every fan-out sorted by ID
+func (f *FanOuts) List() []jobs.FanOutStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]jobs.FanOutStatus, 0, len(f.runs))
+	for _, run := range f.runs {
+		out = append(out, run.status())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
+
+// Run advances fan-outs once per second until stop is closed
+func (f *FanOuts) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			f.Tick()
+		}
+	}
+}
+
+// Tick refreshes the running batch and starts the next one once it has finished
+func (f *FanOuts) Tick() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, run := range f.runs {
+		if run.state == jobs.WorkflowRunning {
+			f.advance(run)
+		}
+	}
+}
+
+// advance settles the current batch, enforcing the failure threshold; caller must hold f.mu
+func (f *FanOuts) advance(run *fanOutRun) {
+	for _, host := range run.batches[run.batch] {
+		h := run.hosts[host]
+		if terminal(h.status) {
+			continue
+		}
+		f.refresh(h)
+		if !terminal(h.status) {
+			return
+		}
+	}
+
+	if failed := run.failed(); failed > run.def.MaxFailures {
+		run.state = jobs.FanOutHalted
+		for _, h := range run.hosts {
+			if h.status == jobs.StatusWaiting {
+				h.status = jobs.StatusSkipped
+				h.err = fmt.Sprintf("halted after %d failures (max %d)", failed, run.def.MaxFailures)
+			}
+		}
+		return
+	}
+
+	if run.batch+1 >= len(run.batches) {
+		run.state = jobs.WorkflowSucceeded
+		if run.failed() > 0 {
+			run.state = jobs.WorkflowFailed
+		}
+		return
+	}
+
+	run.batch++
+	f.launch(run)
+}
+
+// launch enqueues every host in the current batch; caller must hold f.mu
+func (f *FanOuts) launch(run *fanOutRun) {
+	for _, host := range run.batches[run.batch] {
+		h := run.hosts[host]
+
+		job := run.def.Template
+		job.ID = h.jobID
+		job.TargetHost = host
+		job.TargetGroup = ""
+		job.Metadata = copyMetadata(job.Metadata)
+		job.Metadata["fanout_id"] = run.def.ID
+		job.Metadata["fanout_batch"] = fmt.Sprint(h.batch)
+
+		if err := f.store.Enqueue(job); err != nil {
+			h.status = jobs.StatusFailed
+			h.exitCode = -1
+			h.err = fmt.Sprintf("enqueue: %v", err)
+			continue
+		}
+		h.status = jobs.StatusPending
+	}
+}
+
+func (f *FanOuts) refresh(h *fanOutHost) {
+	status, result, ok := f.store.Lookup(h.jobID)
+	if !ok {
+		return
+	}
+
+	h.status = status
+	if result != nil {
+		h.exitCode = result.ExitCode
+		h.err = result.Error
+	}
+}
+
+func (r *fanOutRun) failed() int {
+	n := 0
+	for _, h := range r.hosts {
+		if terminal(h.status) && h.status != jobs.StatusSucceeded && h.status != jobs.StatusSkipped {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (r *fanOutRun) status() jobs.FanOutStatus {
+	out := jobs.FanOutStatus{
+		ID:           r.def.ID,
+		State:        r.state,
+		Batch:        r.batch + 1,
+		TotalBatches: len(r.batches),
+		Hosts:        make([]jobs.FanOutHostStatus, 0, len(r.def.Hosts)),
+	}
+
+	for _, host := range r.def.Hosts {
+		h := r.hosts[host]
+		switch {
+		case h.status == jobs.StatusSucceeded:
+			out.Succeeded++
+		case terminal(h.status) && h.status != jobs.StatusSkipped:
+			out.Failed++
+		}
+		jobID := h.jobID
+		if h.status == jobs.StatusWaiting || h.status == jobs.StatusSkipped {
+			jobID = "" // never enqueued
+		}
+		out.Hosts = append(out.Hosts, jobs.FanOutHostStatus{
+			Host:     h.host,
+			JobID:    jobID,
+			Batch:    h.batch,
+			Status:   h.status,
+			ExitCode: h.exitCode,
+			Error:    h.err,
+		})
+	}
+
+	return out
+}
+
+// appendUnique appends names not already present in list
+func appendUnique(list, names []string) []string {
+	seen := make(map[string]struct{}, len(list))
+	for _, name := range list {
+		seen[name] = struct{}{}
+	}
+	for _, name := range names {
+		if _, dup := seen[name]; dup {
+			continue
+		}
+		seen[name] = struct{}{}
+		list = append(list, name)
+	}
+
+	return list
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+func TestFanOutBatches(t *testing.T) {
+	const (
+		waiting   = jobs.StatusWaiting
+		pending   = jobs.StatusPending
+		succeeded = jobs.StatusSucceeded
+		failed    = jobs.StatusFailed
+		skipped   = jobs.StatusSkipped
+	)
+	type step struct {
+		finish map[string]jobs.Status // host -> result its engine reports
+		want   map[string]jobs.Status
+		batch  int
+		state  jobs.WorkflowState
+	}
+	tests := []struct {
+		name        string
+		maxFailures int
+		steps       []step
+	}{
+		{
+			name: "batches advance once the running one settles",
+			steps: []step{
+				{nil, map[string]jobs.Status{"h1": pending, "h2": pending, "h3": waiting, "h4": waiting, "h5": waiting}, 1, jobs.WorkflowRunning},
+				// Half a batch done is not enough
+				{map[string]jobs.Status{"h1": succeeded}, map[string]jobs.Status{"h2": pending, "h3": waiting}, 1, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"h2": succeeded}, map[string]jobs.Status{"h3": pending, "h4": pending, "h5": waiting}, 2, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"h3": succeeded, "h4": succeeded}, map[string]jobs.Status{"h5": pending}, 3, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"h5": succeeded}, map[string]jobs.Status{"h5": succeeded}, 3, jobs.WorkflowSucceeded},
+			},
+		},
+		{
+			name: "first failure halts with max_failures 0",
+			steps: []step{
+				{map[string]jobs.Status{"h1": succeeded, "h2": failed}, map[string]jobs.Status{"h3": skipped, "h4": skipped, "h5": skipped}, 1, jobs.FanOutHalted},
+			},
+		},
+		{
+			name:        "failures up to max_failures are tolerated",
+			maxFailures: 1,
+			steps: []step{
+				{map[string]jobs.Status{"h1": failed, "h2": succeeded}, map[string]jobs.Status{"h3": pending, "h4": pending}, 2, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"h3": succeeded, "h4": succeeded}, map[string]jobs.Status{"h5": pending}, 3, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"h5": succeeded}, nil, 3, jobs.WorkflowFailed},
+			},
+		},
+		{
+			name:        "exceeding max_failures in a later batch halts",
+			maxFailures: 1,
+			steps: []step{
+				{map[string]jobs.Status{"h1": failed, "h2": succeeded}, map[string]jobs.Status{"h3": pending, "h4": pending}, 2, jobs.WorkflowRunning},
+				// The running batch always finishes before the threshold is checked
+				{map[string]jobs.Status{"h3": failed}, map[string]jobs.Status{"h4": pending, "h5": waiting}, 2, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"h4": succeeded}, map[string]jobs.Status{"h5": skipped}, 2, jobs.FanOutHalted},
+			},
+		},
+	}
+	for _, tt := range tests {
+		store := NewStore()
+		fanouts := NewFanOuts(store)
+		_, err := fanouts.Submit(jobs.FanOutDefinition{
+			ID:          "patch",
+			Hosts:       []string{"h1", "h2", "h3", "h4", "h5"},
+			BatchSize:   2,
+			MaxFailures: tt.maxFailures,
+			Template:    testJob("", "", 0),
+		})
+		if err != nil {
+			t.Fatalf("%s: Submit: %v", tt.name, err)
+		}
+
+		for i, st := range tt.steps {
+			for host, status := range st.finish {
+				if err := store.Complete(jobs.Result{JobID: "patch-" + host, Status: status}); err != nil {
+					t.Fatalf("%s step %d: Complete(%s): %v", tt.name, i, host, err)
+				}
+			}
+			fanouts.Tick()
+
+			status, _ := fanouts.Status("patch")
+			got := map[string]jobs.Status{}
+			for _, h := range status.Hosts {
+				got[h.Host] = h.Status
+			}
+			for host, want := range st.want {
+				if got[host] != want {
+					t.Errorf("%s step %d: host %s is %s, want %s", tt.name, i, host, got[host], want)
+				}
+			}
+			if status.Batch != st.batch || status.State != st.state {
+				t.Errorf("%s step %d: batch %d %s, want batch %d %s", tt.name, i, status.Batch, status.State, st.batch, st.state)
+			}
+		}
+	}
+}
+
+func TestFanOutSkippedHostsAreNeverEnqueued(t *testing.T) {
+	store := NewStore()
+	fanouts := NewFanOuts(store)
+	if _, err := fanouts.Submit(jobs.FanOutDefinition{ID: "patch", Hosts: []string{"h1", "h2"}, BatchSize: 1, Template: testJob("", "", 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete(jobs.Result{JobID: "patch-h1", Status: jobs.StatusFailed}); err != nil {
+		t.Fatal(err)
+	}
+	fanouts.Tick()
+
+	if _, _, ok := store.Lookup("patch-h2"); ok {
+		t.Error("host of a halted batch was enqueued")
+	}
+	status, _ := fanouts.Status("patch")
+	if status.Failed != 1 || status.Succeeded != 0 || status.Hosts[1].JobID != "" {
+		t.Errorf("status %+v", status)
+	}
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// DefaultQueue receives jobs that do not name a queue
+const DefaultQueue = "default"
+
+// queueItem is a single job waiting inside a named queue
+type queueItem struct {
+	jobID    string
+	priority int
+	seq      uint64 // insertion order keeps equal priorities FIFO
+	index    int    // position inside the heap, maintained for targeted removal
+}
+
+// priorityHeap orders items by priority (highest first), then by arrival
+type priorityHeap []*queueItem
+
+func (h priorityHeap) Len() int { return len(h) }
+
+func (h priorityHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h priorityHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *priorityHeap) Push(x any) {
+	item := x.(*queueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *priorityHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil // drop reference so popped items can be collected
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+// namedQueue couples a priority heap with its scheduling weight
+type namedQueue struct {
+	name    string
+	weight  int
+	current int // running credit for smooth weighted round-robin
+	items   priorityHeap
+}
+
+func newNamedQueue(name string, weight int) *namedQueue {
+	if weight <= 0 {
+		weight = 1
+	}
+	return &namedQueue{name: name, weight: weight}
+}
+
+func (q *namedQueue) push(item *queueItem) {
+	heap.Push(&q.items, item)
+}
+
+func (q *namedQueue) pop() *queueItem {
+	return heap.Pop(&q.items).(*queueItem)
+}
+
+// remove drops an item that is still waiting, e.g. when it expires before pickup
+func (q *namedQueue) remove(item *queueItem) {
+	if item.index < 0 || item.index >= q.items.Len() || q.items[item.index] != item {
+		return
+	}
+	heap.Remove(&q.items, item.index)
+}
+
+// QueueInfo summarises a named queue for operators
+type QueueInfo struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	Depth  int    `json:"depth"`
+}
+
+// pickQueue selects the next non-empty queue with smooth weighted round-robin.
+// Every eligible queue earns its weight in credit; the richest one wins and pays
+// back the total, so low-weight queues are still served in proportion and never starve.
+func pickQueue(queues map[string]*namedQueue) *namedQueue {
+	var (
+		best  *namedQueue
+		total int
+	)
+
+	// Iterate in a stable order so ties resolve deterministically
+	names := make([]string, 0, len(queues))
+	for name := range queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		q := queues[name]
+		if q.items.Len() == 0 {
+			continue
+		}
+		q.current += q.weight
+		total += q.weight
+		if best == nil || q.current > best.current {
+			best = q
+		}
+	}
+
+	if best != nil {
+		best.current -= total
+	}
+
+	return best
+}
@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// scheduleHistoryLimit caps how many spawned job IDs each schedule remembers
+const scheduleHistoryLimit = 100
+
+// Scheduler spawns jobs from recurring cron schedules into a Store
+type Scheduler struct {
+	mu        sync.Mutex
+	store     *Store
+	schedules map[string]*scheduleRecord
+	now       func() time.Time
+}
+
+type scheduleRecord struct {
+	def     jobs.ScheduleDefinition
+	cron    *CronExpr
+	nextRun time.Time
+	lastRun time.Time
+	history []string
+	held    *jobs.JobDefinition // run queued behind a still-active previous run, enqueued once it is final
+}
+
+// NewScheduler returns a scheduler that enqueues spawned jobs into store
+func NewScheduler(store *Store) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		schedules: make(map[string]*scheduleRecord),
+		now:       time.Now,
+	}
+}
+
+// Add validates and registers a new schedule
+func (s *Scheduler) Add(def jobs.ScheduleDefinition) (jobs.ScheduleStatus, error) {
+	// Validate against inventory defaults but keep the raw template so later inventory edits apply
+	check := def
+	resolved, err := s.store.resolve(def.Template)
+	if err != nil {
+		return jobs.ScheduleStatus{}, err
+	}
+	check.Template = resolved
+	if err := check.Validate(); err != nil {
+		return jobs.ScheduleStatus{}, err
+	}
+	if def.Overlap == "" {
+		def.Overlap = jobs.OverlapSkip
+	}
+
+	expr, err := ParseCron(def.Cron, def.Timezone)
+	if err != nil {
+		return jobs.ScheduleStatus{}, fmt.Errorf("schedule %s: %w", def.ID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.schedules[def.ID]; exists {
+		return jobs.ScheduleStatus{}, fmt.Errorf("schedule %s already exists", def.ID)
+	}
+
+	rec := &scheduleRecord{def: def, cron: expr}
+	rec.nextRun = expr.Next(s.now())
+	if rec.nextRun.IsZero() {
+		return jobs.ScheduleStatus{}, fmt.Errorf("schedule %s never fires", def.ID)
+	}
+	s.schedules[def.ID] = rec
+
+	return rec.status(), nil
+}
+
+// Get returns a single schedule with its history
+func (s *Scheduler) Get(id string) (jobs.ScheduleStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.schedules[id]
+	if !ok {
+		return jobs.ScheduleStatus{}, false
+	}
+
+	return rec.status(), true
+}
+
+// List returns every schedule sorted by ID
+func (s *Scheduler) List() []jobs.ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]jobs.ScheduleStatus, 0, len(s.schedules))
+	for _, rec := range s.schedules {
+		out = append(out, rec.status())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
+
+// Delete removes a schedule; jobs it already spawned are left alone
+func (s *Scheduler) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return false
+	}
+	delete(s.schedules, id)
+
+	return true
+}
+
+// SetPaused pauses or resumes a schedule. Resuming recomputes the next run from now
+// so ticks missed while paused are not replayed
+func (s *Scheduler) SetPaused(id string, paused bool) (jobs.ScheduleStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.schedules[id]
+	if !ok {
+		return jobs.ScheduleStatus{}, fmt.Errorf("schedule %s not found", id)
+	}
+
+	if rec.def.Paused && !paused {
+		rec.nextRun = rec.cron.Next(s.now())
+	}
+	rec.def.Paused = paused
+
+	return rec.status(), nil
+}
+
+// Run fires due schedules once per second until stop is closed
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Tick()
+		}
+	}
+}
+
+// Tick releases queued runs whose previous run finished and spawns a job for every schedule whose
+// next run has passed. Activations missed while the controller was down collapse into a single run
+func (s *Scheduler) Tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, rec := range s.schedules {
+		if rec.held != nil && !s.active(rec.lastJobID()) {
+			job := *rec.held
+			rec.held = nil
+			if err := s.enqueue(rec, job); err != nil {
+				log.Printf("schedule %s: %v", rec.def.ID, err)
+			}
+		}
+		if rec.def.Paused || rec.nextRun.IsZero() || rec.nextRun.After(now) {
+			continue
+		}
+
+		fireAt := rec.nextRun
+		rec.nextRun = rec.cron.Next(now)
+		rec.lastRun = now
+
+		if err := s.spawn(rec, fireAt); err != nil {
+			log.Printf("schedule %s: %v", rec.def.ID, err)
+		}
+	}
+}
+
+// spawn applies the overlap policy and enqueues a fresh job; caller must hold s.mu
+func (s *Scheduler) spawn(rec *scheduleRecord, fireAt time.Time) error {
+	job := rec.def.Template
+	job.ID = fmt.Sprintf("%s-%s", rec.def.ID, fireAt.UTC().Format("20060102T1504Z"))
+	// Absolute timing fields make no sense on a recurring template
+	job.NotBefore, job.RunAt, job.Deadline = time.Time{}, time.Time{}, time.Time{}
+	job.Metadata = copyMetadata(job.Metadata)
+	job.Metadata["schedule_id"] = rec.def.ID
+
+	if prev := rec.lastJobID(); prev != "" && s.active(prev) {
+		switch rec.def.Overlap {
+		case jobs.OverlapSkip:
+			return fmt.Errorf("previous run %s still active; skipping tick", prev)
+		case jobs.OverlapQueue:
+			// Held here rather than in the store, so no engine can start it next to the previous run
+			if rec.held != nil {
+				return fmt.Errorf("run %s already waits for %s; skipping tick", rec.held.ID, prev)
+			}
+			rec.held = &job
+			return nil
+		case jobs.OverlapReplace:
+			// Running jobs are aborted by their engine on its next heartbeat
+			if err := s.store.Cancel(prev, fmt.Sprintf("replaced by newer run of schedule %s", rec.def.ID)); err != nil {
+				log.Printf("schedule %s: replace %s: %v", rec.def.ID, prev, err)
+			}
+		}
+	}
+
+	return s.enqueue(rec, job)
+}
+
+// enqueue adds a spawned job to the store and the schedule's history; caller must hold s.mu
+func (s *Scheduler) enqueue(rec *scheduleRecord, job jobs.JobDefinition) error {
+	if err := s.store.Enqueue(job); err != nil {
+		return fmt.Errorf("enqueue %s: %w", job.ID, err)
+	}
+
+	rec.history = append(rec.history, job.ID)
+	if len(rec.history) > scheduleHistoryLimit {
+		rec.history = rec.history[len(rec.history)-scheduleHistoryLimit:]
+	}
+
+	return nil
+}
+
+// active reports whether a spawned job has not reached a final state
+func (s *Scheduler) active(jobID string) bool {
+	status, _, ok := s.store.Lookup(jobID)
+	if !ok {
+		return false
+	}
+
+	switch status {
+	case jobs.StatusPending, jobs.StatusRunning, jobs.StatusScheduled:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *scheduleRecord) lastJobID() string {
+	if len(r.history) == 0 {
+		return ""
+	}
+
+	return r.history[len(r.history)-1]
+}
+
+func (r *scheduleRecord) status() jobs.ScheduleStatus {
+	def := r.def
+	// Never echo stored secrets back to API callers
+	def.Template = def.Template.Redacted()
+	def.Template.Credentials.Password = ""
+
+	status := jobs.ScheduleStatus{
+		ScheduleDefinition: def,
+		NextRun:            r.nextRun,
+		LastRun:            r.lastRun,
+		History:            append([]string(nil), r.history...),
+	}
+	if r.held != nil {
+		status.Queued = r.held.ID
+	}
+
+	return status
+}
+
+// copyMetadata clones a metadata map so spawned jobs do not share the template's map
+func copyMetadata(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in)+1)
+	for k, v := range in {
+		out[k] = v
+	}
+
+	return out
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+func TestSchedulerOverlap(t *testing.T) {
+	start := time.Date(2026, 3, 4, 10, 0, 30, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	const first, second, third = "nightly-20260304T1001Z", "nightly-20260304T1002Z", "nightly-20260304T1003Z"
+
+	tests := []struct {
+		policy jobs.OverlapPolicy
+		// statuses after the second tick, while the first run is still running
+		overlapping map[string]jobs.Status
+		queued      string
+		// statuses once the first run finished and the scheduler ticked again
+		after map[string]jobs.Status
+	}{
+		{
+			policy:      jobs.OverlapSkip,
+			overlapping: map[string]jobs.Status{first: jobs.StatusRunning},
+			after:       map[string]jobs.Status{first: jobs.StatusFailed},
+		},
+		{
+			policy:      jobs.OverlapQueue,
+			overlapping: map[string]jobs.Status{first: jobs.StatusRunning},
+			queued:      second,
+			after:       map[string]jobs.Status{first: jobs.StatusFailed, second: jobs.StatusPending},
+		},
+		{
+			policy:      jobs.OverlapReplace,
+			overlapping: map[string]jobs.Status{first: jobs.StatusRunning, second: jobs.StatusPending},
+			after:       map[string]jobs.Status{first: jobs.StatusCancelled, second: jobs.StatusPending},
+		},
+	}
+	for _, tt := range tests {
+		now := start
+		store := NewStore()
+		sched := NewScheduler(store)
+		sched.now = func() time.Time { return now }
+
+		template := testJob("", "", 0)
+		if _, err := sched.Add(jobs.ScheduleDefinition{ID: "nightly", Cron: "* * * * *", Overlap: tt.policy, Template: template}); err != nil {
+			t.Fatalf("%s: Add: %v", tt.policy, err)
+		}
+
+		now = at(30 * time.Second)
+		sched.Tick()
+		if job, ok := store.Next("engine-1"); !ok || job.ID != first {
+			t.Fatalf("%s: first run not dispatched: %v", tt.policy, job)
+		}
+
+		// The second tick fires while the first run is still running
+		now = at(90 * time.Second)
+		sched.Tick()
+		checkStatuses(t, string(tt.policy)+" overlapping", store, tt.overlapping)
+		if tt.policy != jobs.OverlapReplace {
+			if job, ok := store.Next("engine-2"); ok {
+				t.Errorf("%s: %s was dispatched while the first run was running", tt.policy, job.ID)
+			}
+		}
+		status, _ := sched.Get("nightly")
+		if status.Queued != tt.queued {
+			t.Errorf("%s: queued run %q, want %q", tt.policy, status.Queued, tt.queued)
+		}
+
+		// Only one run waits; a third tick meanwhile is dropped
+		if tt.policy == jobs.OverlapQueue {
+			now = at(150 * time.Second)
+			sched.Tick()
+			if status, _ := sched.Get("nightly"); status.Queued != second {
+				t.Errorf("queue: third tick replaced the waiting run with %q", status.Queued)
+			}
+			if _, _, ok := store.Lookup(third); ok {
+				t.Errorf("queue: third run %s was enqueued", third)
+			}
+		}
+
+		// A replaced run is aborted by its engine, which reports a failure
+		if err := store.Complete(jobs.Result{JobID: first, Status: jobs.StatusFailed}); err != nil {
+			t.Fatalf("%s: Complete: %v", tt.policy, err)
+		}
+		now = now.Add(time.Second)
+		sched.Tick()
+		checkStatuses(t, string(tt.policy)+" after", store, tt.after)
+		if status, _ := sched.Get("nightly"); status.Queued != "" {
+			t.Errorf("%s: run %q still queued after the previous one finished", tt.policy, status.Queued)
+		}
+	}
+}
+
+// checkStatuses compares the status of every job in the store with want
+func checkStatuses(t *testing.T, name string, store *Store, want map[string]jobs.Status) {
+	t.Helper()
+	got := map[string]jobs.Status{}
+	for _, sum := range store.List(JobFilter{}) {
+		got[sum.ID] = sum.Status
+	}
+	if len(got) != len(want) {
+		t.Errorf("%s: jobs %v, want %v", name, got, want)
+		return
+	}
+	for id, status := range want {
+		if got[id] != status {
+			t.Errorf("%s: %s is %s, want %s", name, id, got[id], status)
+		}
+	}
+}
+
+func TestSchedulerPauseResume(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 30, 0, time.UTC)
+	store := NewStore()
+	sched := NewScheduler(store)
+	sched.now = func() time.Time { return now }
+
+	if _, err := sched.Add(jobs.ScheduleDefinition{ID: "s", Cron: "* * * * *", Paused: true, Template: testJob("", "", 0)}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(5 * time.Minute)
+	sched.Tick()
+	if n := len(store.List(JobFilter{})); n != 0 {
+		t.Errorf("paused schedule spawned %d jobs", n)
+	}
+
+	// Resuming does not replay the ticks missed while paused
+	if _, err := sched.SetPaused("s", false); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	sched.Tick()
+	if jobsList := store.List(JobFilter{}); len(jobsList) != 1 || !strings.HasPrefix(jobsList[0].ID, "s-") {
+		t.Errorf("resumed schedule spawned %v, want one run", jobsList)
+	}
+}
@@ -0,0 +1,368 @@
+package controller
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// Ends of a shell relay
+const (
+	ShellOperator = "operator"
+	ShellEngine   = "engine"
+)
+
+// DefaultShellAttachTimeout is how long one end of a shell relay waits for the other
+const DefaultShellAttachTimeout = 2 * time.Minute
+
+// shellRecordingWait bounds how long a result waits for its session recording to be stored
+const shellRecordingWait = 10 * time.Second
+
+// Relay message kinds, matching the WebSocket opcodes of the connections
+const (
+	shellControl = 1 // text: a jobs.ShellMessage
+	shellData    = 2 // binary: terminal bytes
+)
+
+// ShellConn is one end of a shell relay, such as a transport.WSConn
+type ShellConn interface {
+	ReadMessage() (int, []byte, error)
+	WriteMessage(op int, data []byte) error
+	Close() error
+}
+
+// Shells pairs the operator and engine ends of shell jobs, relays messages between them and
+// records what the shell printed as an asciicast artifact for the audit trail
+type Shells struct {
+	mu            sync.Mutex
+	relays        map[string]*shellRelay // job ID -> relay until both ends are gone
+	tokens        map[string]string      // job ID -> operator token issued on submit
+	artifacts     *Artifacts
+	attachTimeout time.Duration
+}
+
+type shellRelay struct {
+	job    jobs.JobDefinition
+	ends   map[string]ShellConn
+	paired chan struct{}          // closed once both ends attached
+	ended  chan jobs.ShellMessage // the job finished while one end waited alone
+	done   chan struct{}          // closed once the session ended and its recording was stored
+}
+
+// NewShells stores session recordings in artifacts
+func NewShells(artifacts *Artifacts) *Shells {
+	return &Shells{relays: make(map[string]*shellRelay), tokens: make(map[string]string), artifacts: artifacts, attachTimeout: DefaultShellAttachTimeout}
+}
+
+// SetAttachTimeout changes how long an end waits for its peer
+func (s *Shells) SetAttachTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d > 0 {
+		s.attachTimeout = d
+	}
+}
+
+// Issue returns a new operator token for a shell job. Only its submitter learns it, so only
+// they can attach as the operator
+func (s *Shells) Issue(jobID string) string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	token := hex.EncodeToString(secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[jobID] = token
+
+	return token
+}
+
+// Authorized reports whether token is the operator token issued for the job
+func (s *Shells) Authorized(jobID, token string) bool {
+	s.mu.Lock()
+	want, ok := s.tokens[jobID]
+	s.mu.Unlock()
+
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// Attach joins conn to the job's relay as role and blocks until the session ended, or until the
+// other end failed to arrive in time. conn is closed before Attach returns
+func (s *Shells) Attach(job jobs.JobDefinition, role string, conn ShellConn) error {
+	s.mu.Lock()
+	r := s.relays[job.ID]
+	if r == nil {
+		r = &shellRelay{job: job, ends: make(map[string]ShellConn, 2), paired: make(chan struct{}), ended: make(chan jobs.ShellMessage, 1), done: make(chan struct{})}
+		s.relays[job.ID] = r
+	}
+	if _, taken := r.ends[role]; taken {
+		s.mu.Unlock()
+		return refuse(conn, fmt.Errorf("job %s already has an attached %s", job.ID, role))
+	}
+	r.ends[role] = conn
+	timeout := s.attachTimeout
+
+	if len(r.ends) == 2 {
+		close(r.paired)
+		s.mu.Unlock()
+		s.relay(r)
+		return nil
+	}
+	s.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var refusal error
+	select {
+	case <-r.paired:
+		<-r.done
+		return nil
+	case msg := <-r.ended:
+		// Pass on how the job ended instead of leaving the end waiting for a peer that will not come
+		refusal = fmt.Errorf("job %s ended before its shell opened: %s", job.ID, msg.Error)
+	case <-timer.C:
+		refusal = fmt.Errorf("no %s attached to job %s within %s", otherEnd(role), job.ID, timeout)
+	}
+
+	s.mu.Lock()
+	select {
+	case <-r.paired:
+		// The peer arrived just as the wait ran out
+		s.mu.Unlock()
+		<-r.done
+		return nil
+	default:
+	}
+	delete(s.relays, job.ID)
+	close(r.done)
+	s.mu.Unlock()
+
+	return refuse(conn, refusal)
+}
+
+// Finish is called with the result of a shell job. An end still waiting alone is told the job
+// ended; otherwise Finish waits briefly for the relay to store its recording and returns it
+func (s *Shells) Finish(result jobs.Result) (jobs.Artifact, bool) {
+	s.mu.Lock()
+	r := s.relays[result.JobID]
+	delete(s.tokens, result.JobID)
+	s.mu.Unlock()
+	if r != nil {
+		select {
+		case <-r.paired:
+			select {
+			case <-r.done:
+			case <-time.After(shellRecordingWait):
+			}
+		default:
+			select {
+			case r.ended <- jobs.ShellMessage{Type: jobs.ShellError, Error: result.Error}:
+			default:
+			}
+		}
+	}
+
+	return s.artifacts.Get(result.JobID, jobs.ShellRecording)
+}
+
+// relay copies messages both ways until either end goes away, then closes both and stores the recording
+func (s *Shells) relay(r *shellRelay) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.relays, r.job.ID)
+		s.mu.Unlock()
+		close(r.done)
+	}()
+
+	operator, engine := r.ends[ShellOperator], r.ends[ShellEngine]
+	rec, err := newShellRecorder(r.job)
+	if err != nil {
+		log.Printf("shell %s: recording disabled: %v", r.job.ID, err)
+	}
+
+	ended := make(chan struct{}, 2)
+	go func() {
+		defer func() { ended <- struct{}{} }()
+		for {
+			op, data, err := engine.ReadMessage()
+			if err != nil {
+				return
+			}
+			if op == shellData {
+				rec.output(data)
+			}
+			if operator.WriteMessage(op, data) != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer func() { ended <- struct{}{} }()
+		for {
+			op, data, err := operator.ReadMessage()
+			if err != nil {
+				return
+			}
+			if op == shellControl {
+				rec.control(data)
+			}
+			if engine.WriteMessage(op, data) != nil {
+				return
+			}
+		}
+	}()
+
+	// Either end leaving ends the session; closing both stops the other copy
+	<-ended
+	var wg sync.WaitGroup
+	for _, conn := range []ShellConn{operator, engine} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn.Close()
+		}()
+	}
+	wg.Wait()
+	<-ended
+
+	if rec != nil {
+		if err := rec.store(s.artifacts); err != nil {
+			log.Printf("shell %s: store recording: %v", r.job.ID, err)
+		}
+	}
+}
+
+// refuse tells an end why it cannot join and closes it
+func refuse(conn ShellConn, err error) error {
+	msg, _ := json.Marshal(jobs.ShellMessage{Type: jobs.ShellError, Error: err.Error()})
+	conn.WriteMessage(shellControl, msg)
+	conn.Close()
+
+	return err
+}
+
+func otherEnd(role string) string {
+	if role == ShellEngine {
+		return ShellOperator
+	}
+
+	return ShellEngine
+}
+
+// shellRecorder writes an asciicast v2 file: a JSON header line, then one [seconds, code, data]
+// event per line, "o" for output and "r" for resizes. Keystrokes are not recorded, since they
+// include passwords typed at prompts that do not echo
+type shellRecorder struct {
+	mu      sync.Mutex
+	jobID   string
+	file    *os.File
+	w       *bufio.Writer
+	start   time.Time
+	partial []byte // incomplete UTF-8 sequence held until the rest arrives
+}
+
+func newShellRecorder(job jobs.JobDefinition) (*shellRecorder, error) {
+	f, err := os.CreateTemp("", "shell-*.cast")
+	if err != nil {
+		return nil, err
+	}
+
+	term, cols, rows := job.Terminal.Settings()
+	header, _ := json.Marshal(struct {
+		Version   int               `json:"version"`
+		Width     int               `json:"width"`
+		Height    int               `json:"height"`
+		Timestamp int64             `json:"timestamp"`
+		Title     string            `json:"title"`
+		Env       map[string]string `json:"env"`
+	}{
+		Version:   2,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: time.Now().Unix(),
+		Title:     fmt.Sprintf("%s@%s (job %s)", job.TargetUser, job.TargetHost, job.ID),
+		Env:       map[string]string{"TERM": term},
+	})
+
+	rec := &shellRecorder{jobID: job.ID, file: f, w: bufio.NewWriter(f), start: time.Now()}
+	rec.w.Write(append(header, '\n'))
+
+	return rec, nil
+}
+
+// output records terminal output, keeping multi-byte characters split across messages whole
+func (r *shellRecorder) output(p []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := append(r.partial, p...)
+	cut := len(data)
+	// Hold back a trailing sequence that is only incomplete, not invalid
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	r.partial = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		r.event("o", string(data[:cut]))
+	}
+}
+
+// control records the resizes among the operator's control messages
+func (r *shellRecorder) control(p []byte) {
+	if r == nil {
+		return
+	}
+	var msg jobs.ShellMessage
+	if json.Unmarshal(p, &msg) != nil || msg.Type != jobs.ShellResize {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event("r", fmt.Sprintf("%dx%d", msg.Cols, msg.Rows))
+}
+
+func (r *shellRecorder) event(code, data string) {
+	line, _ := json.Marshal([]any{time.Since(r.start).Seconds(), code, data})
+	r.w.Write(append(line, '\n'))
+}
+
+// store saves the recording as the job's ShellRecording artifact and removes the temp file
+func (r *shellRecorder) store(artifacts *Artifacts) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer os.Remove(r.file.Name())
+	defer r.file.Close()
+
+	if len(r.partial) > 0 {
+		r.event("o", string(r.partial))
+	}
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := r.file.Seek(0, 0); err != nil {
+		return err
+	}
+
+	_, err := artifacts.Put(r.jobID, jobs.ShellRecording, "application/x-asciicast", r.file)
+	return err
+}
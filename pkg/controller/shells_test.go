@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+func TestShellTokens(t *testing.T) {
+	artifacts, err := NewArtifacts(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shells := NewShells(artifacts)
+
+	token := shells.Issue("j1")
+	other := shells.Issue("j2")
+	tests := []struct {
+		job, token string
+		want       bool
+	}{
+		{"j1", token, true},
+		{"j2", other, true},
+		{"j1", other, false},
+		{"j1", "", false},
+		{"j3", token, false},
+		{"j3", "", false},
+	}
+	for _, tt := range tests {
+		if got := shells.Authorized(tt.job, tt.token); got != tt.want {
+			t.Errorf("Authorized(%s, %.8s) = %v, want %v", tt.job, tt.token, got, tt.want)
+		}
+	}
+
+	// The token dies with the job
+	shells.Finish(jobs.Result{JobID: "j1", Status: jobs.StatusSucceeded})
+	if shells.Authorized("j1", token) {
+		t.Error("token still accepted after the job finished")
+	}
+}
@@ -2,7 +2,10 @@ package controller
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
 )
@@ -11,26 +14,175 @@ import (
 // Controller call this HTTP handler to enqueue work, engines poll it for next job, users poll status/results
 type Store struct {
 	mu      sync.Mutex
-	queue   []string              // FIFO of job IDs waiting pickup
-	records map[string]*jobRecord //full job definitions + status/results
+	queues  map[string]*namedQueue // named priority queues of job IDs waiting pickup
+	seq     uint64                 // monotonically increasing enqueue counter
+	records map[string]*jobRecord  //full job definitions + status/results
+	timers  *timerWheel            // promotes scheduled jobs and expires stale ones
+	now     func() time.Time
+
+	resolver    Resolver             // optional inventory lookups applied before validation
+	engines     map[string]time.Time // last contact per engine ID
+	maxLogBytes int                  // live output kept per job; older chunks are dropped first
+}
+
+// Resolver rewrites inventory names into concrete connection details before a job is queued
+type Resolver interface {
+	ResolveJob(job jobs.JobDefinition) (jobs.JobDefinition, error)
+	GroupHosts(name string) ([]string, error)
 }
 
 type jobRecord struct {
 	job    jobs.JobDefinition
 	status jobs.Status
 	result *jobs.Result
+	item   *queueItem // non-nil while the job sits in a queue
+
+	submittedAt     time.Time
+	startedAt       time.Time
+	engine          string          // engine that claimed the job, when it identified itself
+	cancelRequested bool            // running job the engine should abort on its next heartbeat
+	logs            []jobs.LogChunk // live output streamed while running
+	logBase         int             // chunks dropped from the front of logs to stay under maxLogBytes
+	logBytes        int             // bytes currently held in logs
+}
+
+// JobFilter narrows List results; zero fields match everything
+type JobFilter struct {
+	Status jobs.Status
+	Queue  string
+	Host   string
+	Limit  int
 }
 
+// DefaultMaxLogBytes bounds the live output the controller keeps per job
+const DefaultMaxLogBytes = 4 << 20
+
+// timerTick is the resolution of the scheduling wheel
+const timerTick = time.Second
+
 // NewStore returns a ready-to-use in-memory queue
 func NewStore() *Store {
-	return &Store{
-		queue:   make([]string, 0, 32),
+	s := &Store{
+		queues: map[string]*namedQueue{
+			DefaultQueue: newNamedQueue(DefaultQueue, 1),
+		},
 		records: make(map[string]*jobRecord),
+		engines: make(map[string]time.Time),
+	}
+	s.setClock(time.Now)
+
+	return s
+}
+
+// setClock replaces the store's time source and restarts the timer wheel from it, so the wheel
+// and the due times it compares against use the same clock. Only call it before any job is queued
+func (s *Store) setClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.now = now
+	s.timers = newTimerWheel(s.now(), timerTick, 3600)
+}
+
+// SetLogLimit bounds the live output retained per job; n <= 0 restores the default
+func (s *Store) SetLogLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxLogBytes = n
+}
+
+func (s *Store) logLimit() int {
+	if s.maxLogBytes <= 0 {
+		return DefaultMaxLogBytes
+	}
+
+	return s.maxLogBytes
+}
+
+// ConfigureQueue creates a named queue or updates its weight.
+// Weights are relative: a queue with weight 4 is served four times as often as weight 1
+func (s *Store) ConfigureQueue(name string, weight int) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("queue name cannot be empty")
+	}
+	if weight <= 0 {
+		return fmt.Errorf("queue %s weight must be positive", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q, ok := s.queues[name]; ok {
+		q.weight = weight
+		return nil
+	}
+	s.queues[name] = newNamedQueue(name, weight)
+
+	return nil
+}
+
+// SetResolver installs the inventory used to resolve host and group names
+func (s *Store) SetResolver(r Resolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resolver = r
+}
+
+// resolve maps an inventory host name in job.TargetHost onto its address, port, user and host key
+func (s *Store) resolve(job jobs.JobDefinition) (jobs.JobDefinition, error) {
+	s.mu.Lock()
+	r := s.resolver
+	s.mu.Unlock()
+
+	if r == nil {
+		return job, nil
+	}
+
+	resolved, err := r.ResolveJob(job)
+	if err != nil {
+		return job, fmt.Errorf("job %s: %w", job.ID, err)
 	}
+
+	return resolved, nil
+}
+
+// groupHosts expands an inventory group into host names
+func (s *Store) groupHosts(name string) ([]string, error) {
+	s.mu.Lock()
+	r := s.resolver
+	s.mu.Unlock()
+
+	if r == nil {
+		return nil, fmt.Errorf("inventory group %s cannot be resolved: no inventory configured", name)
+	}
+
+	return r.GroupHosts(name)
+}
+
+// Queues reports every named queue with its weight and current depth
+func (s *Store) Queues() []QueueInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]QueueInfo, 0, len(s.queues))
+	for _, q := range s.queues {
+		infos = append(infos, QueueInfo{Name: q.name, Weight: q.weight, Depth: q.items.Len()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos
 }
 
 // Enqueue validates and queues a job for execution
+// Jobs with a future start time are held as scheduled until the timer wheel promotes them
 func (s *Store) Enqueue(job jobs.JobDefinition) error {
+	job, err := s.resolve(job)
+	if err != nil {
+		return err
+	}
 	if err := job.Validate(); err != nil {
 		return err
 	}
@@ -42,34 +194,204 @@ func (s *Store) Enqueue(job jobs.JobDefinition) error {
 		return fmt.Errorf("job %s already exists", job.ID)
 	}
 
-	s.records[job.ID] = &jobRecord{
-		job:    job,
-		status: jobs.StatusPending,
+	if _, err := s.queueFor(job.Queue); err != nil {
+		return fmt.Errorf("job %s: %w", job.ID, err)
+	}
+
+	now := s.now()
+	if !job.Deadline.IsZero() && !job.Deadline.After(now) {
+		return fmt.Errorf("job %s deadline already passed", job.ID)
+	}
+
+	rec := &jobRecord{job: job, submittedAt: now}
+	s.records[job.ID] = rec
+
+	if due := job.DueAt(); due.After(now) {
+		rec.status = jobs.StatusScheduled
+		s.timers.add(wheelEntry{jobID: job.ID, kind: timerPromote, due: due})
+		return nil
+	}
+
+	s.push(rec)
+
+	return nil
+}
+
+// push places a record into its queue and arms its deadline; caller must hold s.mu
+func (s *Store) push(rec *jobRecord) {
+	q, err := s.queueFor(rec.job.Queue)
+	if err != nil {
+		// Queue was validated on enqueue; fall back rather than lose the job
+		q = s.queues[DefaultQueue]
+	}
+
+	s.seq++
+	rec.status = jobs.StatusPending
+	rec.item = &queueItem{jobID: rec.job.ID, priority: rec.job.Priority, seq: s.seq}
+	q.push(rec.item)
+
+	if !rec.job.Deadline.IsZero() {
+		s.timers.add(wheelEntry{jobID: rec.job.ID, kind: timerExpire, due: rec.job.Deadline})
+	}
+}
+
+// Tick advances the timer wheel: due scheduled jobs join their queue and
+// queued jobs past their deadline are expired
+func (s *Store) Tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, entry := range s.timers.advance(now) {
+		rec, ok := s.records[entry.jobID]
+		if !ok {
+			continue
+		}
+
+		switch entry.kind {
+		case timerPromote:
+			if rec.status != jobs.StatusScheduled {
+				continue
+			}
+			if !rec.job.Deadline.IsZero() && !rec.job.Deadline.After(now) {
+				s.expire(rec, now)
+				continue
+			}
+			s.push(rec)
+		case timerExpire:
+			if rec.status == jobs.StatusPending {
+				s.expire(rec, now)
+			}
+		}
+	}
+}
+
+// RunTimers calls Tick on every wheel interval until stop is closed
+func (s *Store) RunTimers(stop <-chan struct{}) {
+	ticker := time.NewTicker(timerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Tick()
+		}
+	}
+}
+
+// Cancel withdraws a job. Queued and scheduled jobs are cancelled immediately; running jobs are
+// flagged so the engine aborts them on its next heartbeat and reports them as cancelled
+func (s *Store) Cancel(jobID, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[jobID]
+	if !ok {
+		return fmt.Errorf("job %s not found", jobID)
+	}
+
+	switch rec.status {
+	case jobs.StatusPending, jobs.StatusScheduled:
+	case jobs.StatusRunning:
+		rec.cancelRequested = true
+		return nil
+	default:
+		return fmt.Errorf("job %s is %s and can no longer be cancelled", jobID, rec.status)
+	}
+
+	s.dequeue(rec)
+	rec.status = jobs.StatusCancelled
+	rec.result = &jobs.Result{
+		JobID:      rec.job.ID,
+		Status:     jobs.StatusCancelled,
+		FinishedAt: s.now().UTC(),
+		ExitCode:   -1,
+		Error:      reason,
+		ErrorClass: jobs.ErrorCancelled,
+		Metadata:   rec.job.Metadata,
 	}
-	s.queue = append(s.queue, job.ID)
 
 	return nil
 }
 
-// Next pops the next pending job for engine
+// dequeue pulls a record out of its queue if it is still waiting; caller must hold s.mu
+func (s *Store) dequeue(rec *jobRecord) {
+	if rec.item == nil {
+		return
+	}
+	if q, err := s.queueFor(rec.job.Queue); err == nil {
+		q.remove(rec.item)
+	}
+	rec.item = nil
+}
+
+// expire retires a job that was never claimed; caller must hold s.mu
+func (s *Store) expire(rec *jobRecord, now time.Time) {
+	s.dequeue(rec)
+
+	rec.status = jobs.StatusExpired
+	rec.result = &jobs.Result{
+		JobID:      rec.job.ID,
+		Status:     jobs.StatusExpired,
+		FinishedAt: now.UTC(),
+		ExitCode:   -1,
+		Error:      fmt.Sprintf("deadline %s passed before an engine claimed the job", rec.job.Deadline.UTC().Format(time.RFC3339)),
+		Metadata:   rec.job.Metadata,
+	}
+}
+
+// queueFor resolves the queue a job should wait in; caller must hold s.mu
+func (s *Store) queueFor(name string) (*namedQueue, error) {
+	if strings.TrimSpace(name) == "" {
+		name = DefaultQueue
+	}
+
+	q, ok := s.queues[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown queue %s", name)
+	}
+
+	return q, nil
+}
+
+// Next pops the next pending job for the engine identified by engineID (which may be empty)
+// Queues are chosen by weighted-fair selection, then the highest priority job within it wins
 // Return (nil, false) when nothing is queued
-func (s *Store) Next() (*jobs.JobDefinition, bool) {
+func (s *Store) Next(engineID string) (*jobs.JobDefinition, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if len(s.queue) == 0 {
-		return nil, false
+	now := s.now()
+	if engineID != "" {
+		s.engines[engineID] = now
 	}
+	for {
+		q := pickQueue(s.queues)
+		if q == nil {
+			return nil, false
+		}
+
+		rec := s.records[q.pop().jobID]
+		rec.item = nil
 
-	jobID := s.queue[0]
-	s.queue = s.queue[1:]
+		// The wheel only fires once per tick, so re-check deadlines at claim time
+		if !rec.job.Deadline.IsZero() && !rec.job.Deadline.After(now) {
+			s.expire(rec, now)
+			continue
+		}
 
-	rec := s.records[jobID]
-	rec.status = jobs.StatusRunning
+		rec.status = jobs.StatusRunning
+		rec.startedAt = now
+		rec.engine = engineID
 
-	jobCopy := rec.job //return by value so callers cannot mutate store internals
+		jobCopy := rec.job //return by value so callers cannot mutate store internals
+		// The engine now holds the only copy of secret values the controller needs to hand out
+		rec.job = rec.job.Redacted()
 
-	return &jobCopy, true
+		return &jobCopy, true
+	}
 }
 
 // Complete records the final result returned by an engine
@@ -82,6 +404,12 @@ func (s *Store) Complete(result jobs.Result) error {
 		return fmt.Errorf("job %s not found", result.JobID)
 	}
 
+	// An engine aborting on request reports a plain failure; surface it as the cancellation it was
+	if rec.cancelRequested && result.Status != jobs.StatusSucceeded {
+		result.Status = jobs.StatusCancelled
+		result.ErrorClass = jobs.ErrorCancelled
+	}
+
 	rec.status = result.Status
 	// Store copy to not be able to mutate original pointer
 	resCopy := result
@@ -90,6 +418,132 @@ func (s *Store) Complete(result jobs.Result) error {
 	return nil
 }
 
+// AppendLogs records live output from the engine and doubles as its heartbeat:
+// the returned flag tells the engine to abort because a user cancelled the job
+func (s *Store) AppendLogs(jobID string, chunks []jobs.LogChunk) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[jobID]
+	if !ok {
+		return false, fmt.Errorf("job %s not found", jobID)
+	}
+	if rec.status != jobs.StatusRunning {
+		return false, fmt.Errorf("job %s is %s, not running", jobID, rec.status)
+	}
+
+	rec.logs = append(rec.logs, chunks...)
+	for _, c := range chunks {
+		rec.logBytes += len(c.Data)
+	}
+	// Drop the oldest chunks once over the limit; readers see the gap through LogPage.Dropped
+	drop := 0
+	for rec.logBytes > s.logLimit() && drop < len(rec.logs)-1 {
+		rec.logBytes -= len(rec.logs[drop].Data)
+		drop++
+	}
+	if drop > 0 {
+		rec.logs = append([]jobs.LogChunk(nil), rec.logs[drop:]...)
+		rec.logBase += drop
+	}
+
+	return rec.cancelRequested, nil
+}
+
+// Logs returns streamed output starting at offset
+func (s *Store) Logs(jobID string, offset int) (jobs.LogPage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[jobID]
+	if !ok {
+		return jobs.LogPage{}, false
+	}
+
+	// Offsets count every chunk ever received, including ones since dropped
+	idx := max(0, min(offset-rec.logBase, len(rec.logs)))
+	page := jobs.LogPage{
+		Chunks:  append([]jobs.LogChunk(nil), rec.logs[idx:]...),
+		Next:    rec.logBase + len(rec.logs),
+		Done:    rec.status.Final(),
+		Dropped: max(0, rec.logBase-max(offset, 0)),
+	}
+
+	return page, true
+}
+
+// Summary returns the compact view of one job
+func (s *Store) Summary(jobID string) (jobs.JobSummary, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[jobID]
+	if !ok {
+		return jobs.JobSummary{}, false
+	}
+
+	return rec.summary(), true
+}
+
+// List returns job summaries matching filter, newest submission first
+func (s *Store) List(filter JobFilter) []jobs.JobSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]jobs.JobSummary, 0, len(s.records))
+	for _, rec := range s.records {
+		sum := rec.summary()
+		if filter.Status != "" && sum.Status != filter.Status {
+			continue
+		}
+		if filter.Queue != "" && sum.Queue != filter.Queue {
+			continue
+		}
+		if filter.Host != "" && sum.TargetHost != filter.Host && rec.job.Metadata["inventory_host"] != filter.Host {
+			continue
+		}
+		out = append(out, sum)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].SubmittedAt.Equal(out[j].SubmittedAt) {
+			return out[i].SubmittedAt.After(out[j].SubmittedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+
+	return out
+}
+
+func (r *jobRecord) summary() jobs.JobSummary {
+	queue := r.job.Queue
+	if queue == "" {
+		queue = DefaultQueue
+	}
+
+	sum := jobs.JobSummary{
+		ID:              r.job.ID,
+		Status:          r.status,
+		Queue:           queue,
+		Priority:        r.job.Priority,
+		TargetHost:      r.job.TargetHost,
+		Command:         r.job.Command,
+		SubmittedAt:     r.submittedAt,
+		StartedAt:       r.startedAt,
+		Engine:          r.engine,
+		CancelRequested: r.cancelRequested,
+	}
+	if r.result != nil {
+		sum.FinishedAt = r.result.FinishedAt
+		sum.ExitCode = r.result.ExitCode
+	}
+
+	return sum
+}
+
 // Lookup exposes status/result for a given job ID
 func (s *Store) Lookup(jobID string) (jobs.Status, *jobs.Result, bool) {
 	s.mu.Lock()
@@ -107,3 +561,42 @@ func (s *Store) Lookup(jobID string) (jobs.Status, *jobs.Result, bool) {
 
 	return rec.status, &resultCopy, true
 }
+
+// Definition returns the job as submitted, with its password and secret values removed
+func (s *Store) Definition(jobID string) (jobs.JobDefinition, jobs.Status, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[jobID]
+	if !ok {
+		return jobs.JobDefinition{}, "", false
+	}
+
+	job := rec.job.Redacted()
+	job.Credentials.Password = ""
+
+	return job, rec.status, true
+}
+
+// ClaimedBy returns the engine running a job; ok is false unless the job is running on an engine
+// that identified itself
+func (s *Store) ClaimedBy(jobID string) (engine string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, found := s.records[jobID]
+	if !found || rec.status != jobs.StatusRunning || rec.engine == "" {
+		return "", false
+	}
+
+	return rec.engine, true
+}
+
+// Exists reports whether a job with this ID was ever submitted
+func (s *Store) Exists(jobID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.records[jobID]
+	return ok
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// testJob returns a valid command job for id in queue
+func testJob(id, queue string, priority int) jobs.JobDefinition {
+	return jobs.JobDefinition{
+		ID:          id,
+		TargetHost:  "web01",
+		TargetUser:  "ops",
+		Command:     "uptime",
+		Checksum:    "x",
+		Queue:       queue,
+		Priority:    priority,
+		Credentials: jobs.CredentialBundle{Username: "ops", Password: "secret"},
+	}
+}
+
+// drain claims every pending job and returns their IDs in dispatch order
+func drain(s *Store) string {
+	var order []string
+	for {
+		job, ok := s.Next("")
+		if !ok {
+			return strings.Join(order, " ")
+		}
+		order = append(order, job.ID)
+	}
+}
+
+func TestStoreDispatchOrder(t *testing.T) {
+	type queued struct {
+		id       string
+		queue    string
+		priority int
+	}
+	tests := []struct {
+		name    string
+		weights map[string]int
+		jobs    []queued
+		want    string
+	}{
+		{
+			name: "fifo within a priority",
+			jobs: []queued{{"a", "", 0}, {"b", "", 0}, {"c", "", 0}},
+			want: "a b c",
+		},
+		{
+			name: "highest priority first",
+			jobs: []queued{{"low", "", -1}, {"mid1", "", 5}, {"high", "", 10}, {"mid2", "", 5}, {"zero", "", 0}},
+			want: "high mid1 mid2 zero low",
+		},
+		{
+			name:    "equal weights alternate",
+			weights: map[string]int{"batch": 1},
+			jobs:    []queued{{"b1", "batch", 0}, {"b2", "batch", 0}, {"d1", "", 0}, {"d2", "", 0}},
+			want:    "b1 d1 b2 d2",
+		},
+		{
+			name:    "smooth weighted round-robin",
+			weights: map[string]int{"fast": 2},
+			jobs: []queued{
+				{"f1", "fast", 0}, {"f2", "fast", 0}, {"f3", "fast", 0}, {"f4", "fast", 0},
+				{"d1", "", 0}, {"d2", "", 0},
+			},
+			want: "f1 d1 f2 f3 d2 f4",
+		},
+		{
+			name:    "priority only orders within its queue",
+			weights: map[string]int{"batch": 1},
+			jobs:    []queued{{"b-low", "batch", 0}, {"b-high", "batch", 9}, {"d-low", "", -5}},
+			want:    "b-high d-low b-low",
+		},
+		{
+			name:    "drained queue leaves the rotation",
+			weights: map[string]int{"fast": 3},
+			jobs:    []queued{{"f1", "fast", 0}, {"d1", "", 0}, {"d2", "", 0}, {"d3", "", 0}},
+			want:    "f1 d1 d2 d3",
+		},
+	}
+	for _, tt := range tests {
+		s := NewStore()
+		for name, weight := range tt.weights {
+			if err := s.ConfigureQueue(name, weight); err != nil {
+				t.Fatalf("%s: ConfigureQueue: %v", tt.name, err)
+			}
+		}
+		for _, q := range tt.jobs {
+			if err := s.Enqueue(testJob(q.id, q.queue, q.priority)); err != nil {
+				t.Fatalf("%s: Enqueue(%s): %v", tt.name, q.id, err)
+			}
+		}
+		if got := drain(s); got != tt.want {
+			t.Errorf("%s: dispatch order %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStoreIdleQueueBanksNoCredit(t *testing.T) {
+	s := NewStore()
+	if err := s.ConfigureQueue("batch", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default queue runs alone for a while; batch earns nothing while it is empty
+	for _, id := range []string{"d1", "d2", "d3", "d4"} {
+		if err := s.Enqueue(testJob(id, "", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := drain(s); got != "d1 d2 d3 d4" {
+		t.Fatalf("lone queue order %q", got)
+	}
+
+	// Once both have work they alternate instead of batch catching up in a burst
+	for _, id := range []string{"b1", "b2", "b3"} {
+		if err := s.Enqueue(testJob(id, "batch", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"d5", "d6", "d7"} {
+		if err := s.Enqueue(testJob(id, "", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := drain(s), "b1 d5 b2 d6 b3 d7"; got != want {
+		t.Errorf("order after the idle period %q, want %q", got, want)
+	}
+}
+
+func TestStoreQueueValidation(t *testing.T) {
+	s := NewStore()
+	tests := []struct {
+		name   string
+		weight int
+		want   string
+	}{
+		{"", 1, "cannot be empty"},
+		{"  ", 1, "cannot be empty"},
+		{"batch", 0, "must be positive"},
+		{"batch", -2, "must be positive"},
+	}
+	for _, tt := range tests {
+		if err := s.ConfigureQueue(tt.name, tt.weight); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ConfigureQueue(%q, %d) = %v, want %q", tt.name, tt.weight, err, tt.want)
+		}
+	}
+
+	if err := s.Enqueue(testJob("j", "missing", 0)); err == nil || !strings.Contains(err.Error(), "unknown queue") {
+		t.Errorf("Enqueue into an unknown queue = %v", err)
+	}
+	if err := s.ConfigureQueue("batch", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ConfigureQueue("batch", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Enqueue(testJob("j", "batch", 0)); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range s.Queues() {
+		if q.Name == "batch" && (q.Weight != 5 || q.Depth != 1) {
+			t.Errorf("batch queue %+v, want weight 5 and depth 1", q)
+		}
+	}
+}
+
+func TestStoreTimers(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	type step struct {
+		at   time.Duration // clock when Tick runs
+		want jobs.Status
+	}
+	tests := []struct {
+		name  string
+		setup func(*jobs.JobDefinition)
+		steps []step
+	}{
+		{
+			name:  "run_at promotes when due",
+			setup: func(j *jobs.JobDefinition) { j.RunAt = at(5 * time.Second) },
+			steps: []step{{0, jobs.StatusScheduled}, {4 * time.Second, jobs.StatusScheduled}, {5 * time.Second, jobs.StatusPending}},
+		},
+		{
+			name: "the later of not_before and run_at wins",
+			setup: func(j *jobs.JobDefinition) {
+				j.RunAt = at(2 * time.Second)
+				j.NotBefore = at(10 * time.Second)
+			},
+			steps: []step{{3 * time.Second, jobs.StatusScheduled}, {9 * time.Second, jobs.StatusScheduled}, {10 * time.Second, jobs.StatusPending}},
+		},
+		{
+			name:  "due beyond one wheel revolution",
+			setup: func(j *jobs.JobDefinition) { j.NotBefore = at(2*time.Hour + 30*time.Second) },
+			steps: []step{{time.Hour + time.Minute, jobs.StatusScheduled}, {2 * time.Hour, jobs.StatusScheduled}, {2*time.Hour + 30*time.Second, jobs.StatusPending}},
+		},
+		{
+			name:  "deadline expires a queued job",
+			setup: func(j *jobs.JobDefinition) { j.Deadline = at(3 * time.Second) },
+			steps: []step{{0, jobs.StatusPending}, {2 * time.Second, jobs.StatusPending}, {3 * time.Second, jobs.StatusExpired}},
+		},
+		{
+			name: "deadline passing before promotion expires a scheduled job",
+			setup: func(j *jobs.JobDefinition) {
+				j.RunAt = at(5 * time.Second)
+				j.Deadline = at(6 * time.Second)
+			},
+			steps: []step{{4 * time.Second, jobs.StatusScheduled}, {10 * time.Second, jobs.StatusExpired}},
+		},
+	}
+	for _, tt := range tests {
+		now := start
+		s := NewStore()
+		s.setClock(func() time.Time { return now })
+
+		job := testJob("j", "", 0)
+		tt.setup(&job)
+		if err := s.Enqueue(job); err != nil {
+			t.Fatalf("%s: Enqueue: %v", tt.name, err)
+		}
+		for _, st := range tt.steps {
+			now = at(st.at)
+			s.Tick()
+			if sum, _ := s.Summary("j"); sum.Status != st.want {
+				t.Errorf("%s: status at +%s = %s, want %s", tt.name, st.at, sum.Status, st.want)
+			}
+		}
+
+		last := tt.steps[len(tt.steps)-1].want
+		if claimed, ok := s.Next(""); ok != (last == jobs.StatusPending) {
+			t.Errorf("%s: Next = %v, %v after the job became %s", tt.name, claimed, ok, last)
+		}
+		if _, result, _ := s.Lookup("j"); last == jobs.StatusExpired && (result == nil || result.Status != jobs.StatusExpired) {
+			t.Errorf("%s: expired job result %+v", tt.name, result)
+		}
+	}
+}
+
+func TestStoreDeadlineChecks(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	s := NewStore()
+	s.setClock(func() time.Time { return now })
+
+	past := testJob("past", "", 0)
+	past.Deadline = start
+	if err := s.Enqueue(past); err == nil || !strings.Contains(err.Error(), "deadline already passed") {
+		t.Errorf("Enqueue with a past deadline = %v", err)
+	}
+
+	// Next expires a job whose deadline passed between ticks instead of handing it out
+	late := testJob("late", "", 0)
+	late.Deadline = start.Add(1500 * time.Millisecond)
+	if err := s.Enqueue(late); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Enqueue(testJob("next", "", 0)); err != nil {
+		t.Fatal(err)
+	}
+	now = start.Add(1600 * time.Millisecond)
+	if job, ok := s.Next(""); !ok || job.ID != "next" {
+		t.Errorf("Next = %v, %v; want the job that has no deadline", job, ok)
+	}
+	if sum, _ := s.Summary("late"); sum.Status != jobs.StatusExpired {
+		t.Errorf("late job is %s, want expired", sum.Status)
+	}
+}
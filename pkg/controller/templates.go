@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// Templates keeps named job templates and renders them with validated parameters
+type Templates struct {
+	mu         sync.Mutex
+	templates  map[string]jobs.JobTemplate
+	hostExists func(string) bool
+	hostVars   func(string) map[string]string
+}
+
+// NewTemplates returns an empty template registry; hostExists validates host-typed parameters and
+// hostVars supplies the inventory variables of the rendered target host. Either may be nil
+func NewTemplates(hostExists func(string) bool, hostVars func(string) map[string]string) *Templates {
+	return &Templates{
+		templates:  make(map[string]jobs.JobTemplate),
+		hostExists: hostExists,
+		hostVars:   hostVars,
+	}
+}
+
+// Put validates and stores a template, replacing any existing one with the same name
+func (t *Templates) Put(tmpl jobs.JobTemplate) error {
+	if err := tmpl.Validate(); err != nil {
+		return err
+	}
+	// Templates are shared; secrets are supplied per run
+	tmpl.Job.Credentials = jobs.CredentialBundle{}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.templates[tmpl.Name] = tmpl
+
+	return nil
+}
+
+// Get returns a template by name
+func (t *Templates) Get(name string) (jobs.JobTemplate, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tmpl, ok := t.templates[name]
+	return tmpl, ok
+}
+
+// List returns every template sorted by name
+func (t *Templates) List() []jobs.JobTemplate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]jobs.JobTemplate, 0, len(t.templates))
+	for _, tmpl := range t.templates {
+		out = append(out, tmpl)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+}
+
+// Delete removes a template
+func (t *Templates) Delete(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.templates[name]; !ok {
+		return false
+	}
+	delete(t.templates, name)
+
+	return true
+}
+
+// Render instantiates a template; callers still assign the ID, checksum and credentials.
+// Parameters left out take the target host's inventory variable of the same name before the template default
+func (t *Templates) Render(name string, params map[string]string) (jobs.JobDefinition, error) {
+	tmpl, ok := t.Get(name)
+	if !ok {
+		return jobs.JobDefinition{}, fmt.Errorf("template %s not found", name)
+	}
+
+	return tmpl.Render(t.withHostVars(tmpl, params), t.hostExists)
+}
+
+func (t *Templates) withHostVars(tmpl jobs.JobTemplate, params map[string]string) map[string]string {
+	if t.hostVars == nil {
+		return params
+	}
+	vars := t.hostVars(tmpl.Target(params))
+	if len(vars) == 0 {
+		return params
+	}
+
+	merged := make(map[string]string, len(params)+len(tmpl.Parameters))
+	for k, v := range params {
+		merged[k] = v
+	}
+	for _, p := range tmpl.Parameters {
+		if _, supplied := params[p.Name]; supplied {
+			continue
+		}
+		if v, ok := vars[p.Name]; ok {
+			merged[p.Name] = v
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+func TestTemplatesRenderHostVars(t *testing.T) {
+	vars := map[string]map[string]string{"web01": {"port": "8080", "other": "x"}}
+	templates := NewTemplates(nil, func(host string) map[string]string { return vars[host] })
+
+	err := templates.Put(jobs.JobTemplate{
+		Name: "check",
+		Parameters: []jobs.TemplateParam{
+			{Name: "host", Type: jobs.ParamHost, Default: "web01"},
+			{Name: "port", Type: jobs.ParamInt, Default: "80"},
+		},
+		Job: jobs.JobDefinition{TargetHost: "${host}", Command: "nc -z localhost ${port}"},
+	})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	tests := []struct {
+		params map[string]string
+		want   string
+	}{
+		{nil, "nc -z localhost 8080"},
+		{map[string]string{"port": "9090"}, "nc -z localhost 9090"},
+		{map[string]string{"host": "web02"}, "nc -z localhost 80"},
+	}
+	for _, tt := range tests {
+		job, err := templates.Render("check", tt.params)
+		if err != nil {
+			t.Errorf("Render(%v): %v", tt.params, err)
+			continue
+		}
+		if job.Command != tt.want {
+			t.Errorf("Render(%v) command %q, want %q", tt.params, job.Command, tt.want)
+		}
+	}
+}
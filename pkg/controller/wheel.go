@@ -0,0 +1,81 @@
+package controller
+
+import "time"
+
+type timerKind int
+
+const (
+	// timerPromote moves a scheduled job into its queue once it is due
+	timerPromote timerKind = iota
+	// timerExpire retires a queued job nobody claimed before its deadline
+	timerExpire
+)
+
+type wheelEntry struct {
+	jobID  string
+	kind   timerKind
+	due    time.Time
+	rounds int // full wheel revolutions left before the entry fires
+}
+
+// timerWheel is a hashed timing wheel: each slot covers one tick and entries
+// further out than one revolution carry a round counter. Adding and advancing
+// are O(1) per entry regardless of how many jobs are scheduled
+type timerWheel struct {
+	tick    time.Duration
+	slots   [][]wheelEntry
+	cursor  int
+	current time.Time // instant up to which the wheel has been advanced
+}
+
+func newTimerWheel(start time.Time, tick time.Duration, size int) *timerWheel {
+	if tick <= 0 {
+		tick = time.Second
+	}
+	if size <= 0 {
+		size = 512
+	}
+
+	return &timerWheel{
+		tick:    tick,
+		slots:   make([][]wheelEntry, size),
+		current: start.Truncate(tick),
+	}
+}
+
+// add places an entry in the slot matching its due time; overdue entries fire on the next tick
+func (w *timerWheel) add(entry wheelEntry) {
+	ticks := 1
+	if delay := entry.due.Sub(w.current); delay > 0 {
+		ticks = int((delay + w.tick - 1) / w.tick)
+	}
+
+	size := len(w.slots)
+	entry.rounds = (ticks - 1) / size
+	slot := (w.cursor + ticks) % size
+	w.slots[slot] = append(w.slots[slot], entry)
+}
+
+// advance moves the wheel forward to now and returns every entry that came due
+func (w *timerWheel) advance(now time.Time) []wheelEntry {
+	var fired []wheelEntry
+
+	for !w.current.Add(w.tick).After(now) {
+		w.cursor = (w.cursor + 1) % len(w.slots)
+		w.current = w.current.Add(w.tick)
+
+		slot := w.slots[w.cursor]
+		kept := slot[:0]
+		for _, entry := range slot {
+			if entry.rounds > 0 {
+				entry.rounds--
+				kept = append(kept, entry)
+				continue
+			}
+			fired = append(fired, entry)
+		}
+		w.slots[w.cursor] = kept
+	}
+
+	return fired
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTimerWheel(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newTimerWheel(start, time.Second, 8)
+
+	// One entry per interesting distance: overdue, within one revolution, exactly one
+	// revolution and several revolutions out
+	w.add(wheelEntry{jobID: "overdue", due: start.Add(-time.Minute)})
+	w.add(wheelEntry{jobID: "now", due: start})
+	w.add(wheelEntry{jobID: "3s", due: start.Add(3 * time.Second)})
+	w.add(wheelEntry{jobID: "2.5s", due: start.Add(2500 * time.Millisecond)})
+	w.add(wheelEntry{jobID: "8s", due: start.Add(8 * time.Second)})
+	w.add(wheelEntry{jobID: "9s", due: start.Add(9 * time.Second)})
+	w.add(wheelEntry{jobID: "20s", due: start.Add(20 * time.Second)})
+
+	tests := []struct {
+		now  time.Duration
+		want []string
+	}{
+		{500 * time.Millisecond, nil},
+		{time.Second, []string{"now", "overdue"}},
+		{2 * time.Second, nil},
+		// Entries never fire early: 2.5s rounds up to the 3s tick
+		{3 * time.Second, []string{"2.5s", "3s"}},
+		{7 * time.Second, nil},
+		{8 * time.Second, []string{"8s"}},
+		{9 * time.Second, []string{"9s"}},
+		{19 * time.Second, nil},
+		{20 * time.Second, []string{"20s"}},
+		{time.Minute, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, entry := range w.advance(start.Add(tt.now)) {
+			got = append(got, entry.jobID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("advance(+%s) fired %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestTimerWheelCatchUp(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newTimerWheel(start, time.Second, 4)
+
+	for i := 1; i <= 10; i++ {
+		w.add(wheelEntry{jobID: strconv.Itoa(i), due: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	// A late advance fires everything due in the skipped ticks at once
+	if fired := w.advance(start.Add(10 * time.Second)); len(fired) != 10 {
+		t.Errorf("advance fired %d entries, want 10", len(fired))
+	}
+	if fired := w.advance(start.Add(time.Hour)); len(fired) != 0 {
+		t.Errorf("advance fired %d entries twice", len(fired))
+	}
+}
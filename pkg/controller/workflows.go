@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// Workflows drives DAG workflows, enqueueing each job into the Store once its parents allow it
+type Workflows struct {
+	mu    sync.Mutex
+	store *Store
+	runs  map[string]*workflowRun
+}
+
+type workflowRun struct {
+	def   jobs.WorkflowDefinition
+	state jobs.WorkflowState
+	order []string // topological order so parents settle before children are examined
+	nodes map[string]*workflowNode
+}
+
+type workflowNode struct {
+	spec     jobs.WorkflowJob
+	jobID    string // controller job ID once enqueued
+	status   jobs.Status
+	exitCode int
+	err      string
+}
+
+// NewWorkflows returns a workflow manager that submits jobs into store
+func NewWorkflows(store *Store) *Workflows {
+	return &Workflows{
+		store: store,
+		runs:  make(map[string]*workflowRun),
+	}
+}
+
+// Submit validates a workflow (including cycle detection) and enqueues its root jobs
+func (w *Workflows) Submit(def jobs.WorkflowDefinition) (jobs.WorkflowStatus, error) {
+	// Validate against inventory defaults; nodes are resolved again when they are enqueued
+	check := def
+	check.Jobs = make([]jobs.WorkflowJob, len(def.Jobs))
+	for i, node := range def.Jobs {
+		resolved, err := w.store.resolve(node.JobDefinition)
+		if err != nil {
+			return jobs.WorkflowStatus{}, fmt.Errorf("workflow %s: %w", def.ID, err)
+		}
+		check.Jobs[i] = jobs.WorkflowJob{JobDefinition: resolved, DependsOn: node.DependsOn}
+	}
+	if err := check.Validate(); err != nil {
+		return jobs.WorkflowStatus{}, err
+	}
+	if def.OnFailure == "" {
+		def.OnFailure = jobs.FailureSkipDownstream
+	}
+
+	order, err := def.TopologicalOrder()
+	if err != nil {
+		return jobs.WorkflowStatus{}, fmt.Errorf("workflow %s: %w", def.ID, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, exists := w.runs[def.ID]; exists {
+		return jobs.WorkflowStatus{}, fmt.Errorf("workflow %s already exists", def.ID)
+	}
+
+	run := &workflowRun{
+		def:   def,
+		state: jobs.WorkflowRunning,
+		order: order,
+		nodes: make(map[string]*workflowNode, len(def.Jobs)),
+	}
+	for _, spec := range def.Jobs {
+		run.nodes[spec.ID] = &workflowNode{spec: spec, status: jobs.StatusWaiting}
+	}
+	w.runs[def.ID] = run
+
+	w.advance(run)
+
+	return run.status(), nil
+}
+
+// Status returns the summary of a single workflow
+func (w *Workflows) Status(id string) (jobs.WorkflowStatus, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	run, ok := w.runs[id]
+	if !ok {
+		return jobs.WorkflowStatus{}, false
+	}
+
+	return run.status(), true
+}
+
+// List returns summaries of every workflow sorted by ID
+func (w *Workflows) List() []jobs.WorkflowStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	out := make([]jobs.WorkflowStatus, 0, len(w.runs))
+	for _, run := range w.runs {
+		out = append(out, run.status())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
+
+// Run re-evaluates active workflows once per second until stop is closed
+func (w *Workflows) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.Tick()
+		}
+	}
+}
+
+// Tick pulls the latest job states from the store and unblocks ready nodes
+func (w *Workflows) Tick() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, run := range w.runs {
+		if run.state == jobs.WorkflowRunning || run.hasActive() {
+			w.advance(run)
+		}
+	}
+}
+
+// advance refreshes submitted nodes, applies the failure policy and enqueues nodes whose parents are done;
+// caller must hold w.mu
+func (w *Workflows) advance(run *workflowRun) {
+	failed := false
+	for _, id := range run.order {
+		node := run.nodes[id]
+		if node.jobID != "" && !terminal(node.status) {
+			w.refresh(node)
+		}
+		if terminal(node.status) && node.status != jobs.StatusSucceeded && node.status != jobs.StatusSkipped {
+			failed = true
+		}
+	}
+
+	if failed && run.def.OnFailure == jobs.FailureAbort && run.state == jobs.WorkflowRunning {
+		w.abort(run)
+	}
+
+	if run.state == jobs.WorkflowRunning {
+		for _, id := range run.order {
+			node := run.nodes[id]
+			if node.status != jobs.StatusWaiting {
+				continue
+			}
+			w.unblock(run, node)
+		}
+	}
+
+	if run.hasActive() {
+		return
+	}
+	if run.state == jobs.WorkflowRunning {
+		run.state = jobs.WorkflowSucceeded
+		if failed {
+			run.state = jobs.WorkflowFailed
+		}
+	}
+}
+
+// unblock enqueues a waiting node once all parents finished, or skips it when a parent failed
+func (w *Workflows) unblock(run *workflowRun, node *workflowNode) {
+	for _, dep := range node.spec.DependsOn {
+		parent := run.nodes[dep]
+		if !terminal(parent.status) {
+			return
+		}
+		if parent.status != jobs.StatusSucceeded && run.def.OnFailure != jobs.FailureContinue {
+			node.status = jobs.StatusSkipped
+			node.err = fmt.Sprintf("upstream job %s %s", dep, parent.status)
+			return
+		}
+	}
+
+	job := node.spec.JobDefinition
+	job.ID = fmt.Sprintf("%s-%s", run.def.ID, node.spec.ID)
+	job.Metadata = copyMetadata(job.Metadata)
+	job.Metadata["workflow_id"] = run.def.ID
+	job.Metadata["workflow_node"] = node.spec.ID
+
+	if err := w.store.Enqueue(job); err != nil {
+		node.status = jobs.StatusFailed
+		node.exitCode = -1
+		node.err = fmt.Sprintf("enqueue: %v", err)
+		return
+	}
+
+	node.jobID = job.ID
+	node.status = jobs.StatusPending
+}
+
+// abort cancels queued nodes and skips everything that never started
+func (w *Workflows) abort(run *workflowRun) {
+	run.state = jobs.WorkflowAborted
+
+	for _, node := range run.nodes {
+		switch {
+		case node.status == jobs.StatusWaiting:
+			node.status = jobs.StatusSkipped
+			node.err = "workflow aborted"
+		case node.jobID != "" && !terminal(node.status):
+			// Running jobs are aborted by their engine and report back as cancelled
+			if err := w.store.Cancel(node.jobID, fmt.Sprintf("workflow %s aborted", run.def.ID)); err == nil {
+				w.refresh(node)
+			}
+		}
+	}
+}
+
+// refresh copies the store's view of a node's job into the node
+func (w *Workflows) refresh(node *workflowNode) {
+	status, result, ok := w.store.Lookup(node.jobID)
+	if !ok {
+		return
+	}
+
+	node.status = status
+	if result != nil {
+		node.exitCode = result.ExitCode
+		node.err = result.Error
+	}
+}
+
+func (r *workflowRun) hasActive() bool {
+	for _, node := range r.nodes {
+		if !terminal(node.status) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *workflowRun) status() jobs.WorkflowStatus {
+	out := jobs.WorkflowStatus{
+		ID:        r.def.ID,
+		State:     r.state,
+		OnFailure: r.def.OnFailure,
+		Nodes:     make([]jobs.WorkflowNodeStatus, 0, len(r.def.Jobs)),
+	}
+
+	// Keep the order the user submitted so summaries line up with the definition
+	for _, spec := range r.def.Jobs {
+		node := r.nodes[spec.ID]
+		out.Nodes = append(out.Nodes, jobs.WorkflowNodeStatus{
+			ID:        spec.ID,
+			JobID:     node.jobID,
+			Status:    node.status,
+			DependsOn: spec.DependsOn,
+			ExitCode:  node.exitCode,
+			Error:     node.err,
+		})
+	}
+
+	return out
+}
+
+// terminal reports whether a job status is final
+func terminal(status jobs.Status) bool {
+	return status.Final()
+}
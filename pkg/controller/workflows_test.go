@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// testWorkflow is a -> b -> c with d also depending on a, and e independent of all of them
+func testWorkflow(policy jobs.FailurePolicy) jobs.WorkflowDefinition {
+	node := func(id string, deps ...string) jobs.WorkflowJob {
+		return jobs.WorkflowJob{JobDefinition: testJob(id, "", 0), DependsOn: deps}
+	}
+
+	return jobs.WorkflowDefinition{ID: "wf", OnFailure: policy, Jobs: []jobs.WorkflowJob{
+		node("a"), node("b", "a"), node("c", "b"), node("d", "a"), node("e"),
+	}}
+}
+
+func TestWorkflowPolicies(t *testing.T) {
+	const (
+		waiting   = jobs.StatusWaiting
+		pending   = jobs.StatusPending
+		succeeded = jobs.StatusSucceeded
+		failed    = jobs.StatusFailed
+		skipped   = jobs.StatusSkipped
+		cancelled = jobs.StatusCancelled
+	)
+	type step struct {
+		finish map[string]jobs.Status // node -> result the engine reports
+		want   map[string]jobs.Status
+		state  jobs.WorkflowState
+	}
+	tests := []struct {
+		name   string
+		policy jobs.FailurePolicy
+		steps  []step
+	}{
+		{
+			name:   "dependencies release in order",
+			policy: jobs.FailureSkipDownstream,
+			steps: []step{
+				{nil, map[string]jobs.Status{"a": pending, "b": waiting, "c": waiting, "d": waiting, "e": pending}, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"a": succeeded}, map[string]jobs.Status{"a": succeeded, "b": pending, "c": waiting, "d": pending, "e": pending}, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"b": succeeded, "e": succeeded}, map[string]jobs.Status{"b": succeeded, "c": pending, "d": pending, "e": succeeded}, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"c": succeeded, "d": succeeded}, map[string]jobs.Status{"c": succeeded, "d": succeeded}, jobs.WorkflowSucceeded},
+			},
+		},
+		{
+			name:   "skip_downstream skips descendants only",
+			policy: jobs.FailureSkipDownstream,
+			steps: []step{
+				{map[string]jobs.Status{"a": failed}, map[string]jobs.Status{"a": failed, "b": skipped, "c": skipped, "d": skipped, "e": pending}, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"e": succeeded}, map[string]jobs.Status{"e": succeeded}, jobs.WorkflowFailed},
+			},
+		},
+		{
+			name:   "skip_downstream below the failure",
+			policy: jobs.FailureSkipDownstream,
+			steps: []step{
+				{map[string]jobs.Status{"a": succeeded, "e": succeeded}, map[string]jobs.Status{"b": pending, "d": pending}, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"b": failed}, map[string]jobs.Status{"c": skipped, "d": pending}, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"d": succeeded}, map[string]jobs.Status{"d": succeeded}, jobs.WorkflowFailed},
+			},
+		},
+		{
+			name:   "continue runs children of failed parents",
+			policy: jobs.FailureContinue,
+			steps: []step{
+				{map[string]jobs.Status{"a": failed}, map[string]jobs.Status{"a": failed, "b": pending, "c": waiting, "d": pending, "e": pending}, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"b": failed, "d": succeeded, "e": succeeded}, map[string]jobs.Status{"c": pending}, jobs.WorkflowRunning},
+				{map[string]jobs.Status{"c": succeeded}, map[string]jobs.Status{"c": succeeded}, jobs.WorkflowFailed},
+			},
+		},
+		{
+			name:   "abort cancels queued jobs and skips the rest",
+			policy: jobs.FailureAbort,
+			steps: []step{
+				{map[string]jobs.Status{"a": failed}, map[string]jobs.Status{"a": failed, "b": skipped, "c": skipped, "d": skipped, "e": cancelled}, jobs.WorkflowAborted},
+			},
+		},
+	}
+	for _, tt := range tests {
+		store := NewStore()
+		workflows := NewWorkflows(store)
+		if _, err := workflows.Submit(testWorkflow(tt.policy)); err != nil {
+			t.Fatalf("%s: Submit: %v", tt.name, err)
+		}
+
+		for i, st := range tt.steps {
+			for node, status := range st.finish {
+				if err := store.Complete(jobs.Result{JobID: "wf-" + node, Status: status}); err != nil {
+					t.Fatalf("%s step %d: Complete(%s): %v", tt.name, i, node, err)
+				}
+			}
+			workflows.Tick()
+
+			status, _ := workflows.Status("wf")
+			got := map[string]jobs.Status{}
+			for _, node := range status.Nodes {
+				got[node.ID] = node.Status
+			}
+			for node, want := range st.want {
+				if got[node] != want {
+					t.Errorf("%s step %d: node %s is %s, want %s", tt.name, i, node, got[node], want)
+				}
+			}
+			if status.State != st.state {
+				t.Errorf("%s step %d: workflow %s, want %s", tt.name, i, status.State, st.state)
+			}
+		}
+	}
+}
+
+func TestWorkflowAbortCancelsRunningJobs(t *testing.T) {
+	store := NewStore()
+	workflows := NewWorkflows(store)
+	if _, err := workflows.Submit(testWorkflow(jobs.FailureAbort)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both roots are claimed; a fails while e is still running
+	for range 2 {
+		if _, ok := store.Next("engine"); !ok {
+			t.Fatal("root job not dispatched")
+		}
+	}
+	if err := store.Complete(jobs.Result{JobID: "wf-a", Status: jobs.StatusFailed}); err != nil {
+		t.Fatal(err)
+	}
+	workflows.Tick()
+
+	// The running job is asked to stop; the workflow settles once its engine reports back
+	if cancel, err := store.AppendLogs("wf-e", nil); err != nil || !cancel {
+		t.Errorf("running job not flagged for cancellation: %v, %v", cancel, err)
+	}
+	if err := store.Complete(jobs.Result{JobID: "wf-e", Status: jobs.StatusFailed}); err != nil {
+		t.Fatal(err)
+	}
+	workflows.Tick()
+
+	status, _ := workflows.Status("wf")
+	if status.State != jobs.WorkflowAborted {
+		t.Errorf("workflow %s, want aborted", status.State)
+	}
+	for _, node := range status.Nodes {
+		if node.ID == "e" && node.Status != jobs.StatusCancelled {
+			t.Errorf("running node e ended %s, want cancelled", node.Status)
+		}
+	}
+}
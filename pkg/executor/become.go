@@ -0,0 +1,177 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// maxBecomeChatter bounds what is held back while sudo or su is still authenticating
+const maxBecomeChatter = 64 << 10
+
+// authFailures are what sudo and su print when they reject a password
+var authFailures = []string{"Sorry, try again", "incorrect password", "Authentication failure"}
+
+// becomeWatcher sits in front of the stream sudo or su prompts on. It answers the first password
+// prompt, swallows the authentication chatter and passes output through once the wrapped command
+// prints its ready marker. A second prompt or a rejection message means the password was wrong
+type becomeWatcher struct {
+	next     io.Writer
+	answer   io.Writer
+	method   string
+	password string
+	prompt   []byte
+	ready    []byte
+
+	mu      sync.Mutex
+	pending []byte
+	prompts int
+	passed  bool
+	failed  bool
+
+	// readyCh closes once the command itself started; failCh reports a rejected password
+	readyCh chan struct{}
+	failCh  chan error
+}
+
+// wrapBecome returns the command run through sudo or su, with the watcher for its output.
+// inner must already be a complete shell command line; preserve names the variables sudo must
+// pass through from the login session (su keeps the environment already)
+func wrapBecome(b *jobs.BecomeSpec, inner, password string, preserve []string) (string, *becomeWatcher) {
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+	tag := hex.EncodeToString(nonce)
+
+	w := &becomeWatcher{
+		method:   b.Method,
+		password: password,
+		ready:    []byte("[orch-become-ready:" + tag + "]"),
+		readyCh:  make(chan struct{}),
+		failCh:   make(chan error, 1),
+	}
+	// The marker goes to stderr, which is where sudo -S prompts and what the PTY merges into stdout
+	shell := "printf '%s\\n' '" + string(w.ready) + "' >&2; " + inner
+
+	if b.Method == jobs.BecomeSu {
+		// su cannot change its prompt, so match the end of the usual "Password:"
+		w.prompt = []byte("assword:")
+		return "su " + b.BecomeUser() + " -s /bin/sh -c " + jobs.ShellQuote(shell), w
+	}
+	w.prompt = []byte("[orch-become-password:" + tag + "]")
+
+	sudo := "sudo -S -p " + jobs.ShellQuote(string(w.prompt))
+	if len(preserve) > 0 {
+		sudo += " --preserve-env=" + jobs.ShellQuote(strings.Join(preserve, ","))
+	}
+
+	return sudo + " -u " + b.BecomeUser() + " -- /bin/sh -c " + jobs.ShellQuote(shell), w
+}
+
+func (w *becomeWatcher) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.passed {
+		return w.next.Write(p)
+	}
+	if w.failed {
+		return len(p), nil
+	}
+
+	w.pending = append(w.pending, p...)
+	if i := bytes.Index(w.pending, w.ready); i >= 0 {
+		rest := bytes.TrimLeft(w.pending[i+len(w.ready):], "\r\n")
+		w.passed = true
+		w.pending = nil
+		close(w.readyCh)
+		if len(rest) > 0 {
+			w.next.Write(rest)
+		}
+		return len(p), nil
+	}
+
+	for n := bytes.Count(w.pending, w.prompt); w.prompts < n; w.prompts++ {
+		if w.prompts > 0 {
+			w.fail()
+			return len(p), nil
+		}
+		if _, err := io.WriteString(w.answer, w.password+"\n"); err != nil {
+			w.failed = true
+			w.failCh <- classify(ErrBecomeFailed, fmt.Errorf("%s: send password: %w", w.method, err))
+			return len(p), nil
+		}
+	}
+	if w.prompts > 0 && w.rejected() {
+		w.fail()
+	} else if len(w.pending) > maxBecomeChatter {
+		w.pending = w.pending[len(w.pending)-maxBecomeChatter:]
+	}
+
+	return len(p), nil
+}
+
+func (w *becomeWatcher) rejected() bool {
+	for _, msg := range authFailures {
+		if bytes.Contains(w.pending, []byte(msg)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *becomeWatcher) fail() {
+	w.failed = true
+	w.failCh <- fmt.Errorf("%w: %s rejected the password: %s", ErrBecomeAuthFailed, w.method, w.chatter())
+}
+
+// chatter returns what sudo or su printed before the command started, without prompts
+func (w *becomeWatcher) chatter() string {
+	text := strings.ReplaceAll(string(w.pending), string(w.prompt), "")
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return "(no output)"
+	}
+
+	return fmt.Sprintf("%q", text)
+}
+
+// result explains a command that ended without the become step succeeding, or returns runErr
+func (w *becomeWatcher) result(runErr error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed {
+		select {
+		case err := <-w.failCh:
+			return err
+		default:
+			return runErr
+		}
+	}
+	if w.passed {
+		return runErr
+	}
+	if w.prompts > 0 && w.rejected() {
+		return fmt.Errorf("%w: %s rejected the password: %s", ErrBecomeAuthFailed, w.method, w.chatter())
+	}
+
+	return fmt.Errorf("%w: %s did not start the command: %s", ErrBecomeFailed, w.method, w.chatter())
+}
+
+// becomeAllowed reports whether jobs may become user. Without BecomeUsers, become is only
+// permitted on engines that do not restrict commands either
+func (e *SSHExecutor) becomeAllowed(user string) bool {
+	if len(e.BecomeUsers) == 0 {
+		return len(e.AllowedCommands) == 0
+	}
+
+	return slices.Contains(e.BecomeUsers, user)
+}
@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+func TestWrapBecome(t *testing.T) {
+	sudo, _ := wrapBecome(&jobs.BecomeSpec{Method: jobs.BecomeSudo, User: "app"}, "id", "hunter2", []string{"API_KEY", "TOKEN"})
+	if !strings.HasPrefix(sudo, "sudo -S -p ") || !strings.Contains(sudo, " --preserve-env=API_KEY,TOKEN -u app -- /bin/sh -c ") {
+		t.Errorf("sudo command = %q", sudo)
+	}
+
+	plain, _ := wrapBecome(&jobs.BecomeSpec{Method: jobs.BecomeSudo}, "id", "hunter2", nil)
+	if strings.Contains(plain, "--preserve-env") || !strings.Contains(plain, " -u root -- ") {
+		t.Errorf("sudo command without secrets = %q", plain)
+	}
+
+	su, _ := wrapBecome(&jobs.BecomeSpec{Method: jobs.BecomeSu, User: "app"}, "id", "hunter2", []string{"API_KEY"})
+	if !strings.HasPrefix(su, "su app -s /bin/sh -c ") {
+		t.Errorf("su command = %q", su)
+	}
+
+	for _, cmd := range []string{sudo, plain, su} {
+		if strings.Contains(cmd, "hunter2") {
+			t.Errorf("password on the command line: %q", cmd)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// Sentinel errors for each failure class. Errors returned by Execute wrap exactly one of them,
+// so callers can branch with errors.Is; Classify maps them onto jobs.ErrorClass
+var (
+	ErrValidation        = errors.New("job validation failed")
+	ErrPolicyDenied      = errors.New("command denied by policy")
+	ErrChecksumMismatch  = errors.New("checksum mismatch")
+	ErrDialFailed        = errors.New("dial failed")
+	ErrHandshakeFailed   = errors.New("ssh handshake failed")
+	ErrAuthFailed        = errors.New("authentication failed")
+	ErrHostKeyMismatch   = errors.New("host key mismatch")
+	ErrSessionFailed     = errors.New("ssh session failed")
+	ErrTimeout           = errors.New("job timed out")
+	ErrCancelled         = errors.New("job cancelled")
+	ErrRemoteExitNonZero = errors.New("remote command exited non-zero")
+	ErrRemoteSignal      = errors.New("remote command killed by signal")
+	ErrTransferFailed    = errors.New("file transfer failed")
+	ErrBecomeFailed      = errors.New("privilege escalation failed")
+	ErrBecomeAuthFailed  = errors.New("privilege escalation password incorrect")
+	ErrExpectFailed      = errors.New("expect step failed")
+)
+
+// errorClasses pairs each sentinel with its wire name
+var errorClasses = []struct {
+	sentinel error
+	class    jobs.ErrorClass
+}{
+	{ErrValidation, jobs.ErrorValidation},
+	{ErrPolicyDenied, jobs.ErrorPolicyDenied},
+	{ErrChecksumMismatch, jobs.ErrorChecksumMismatch},
+	{ErrDialFailed, jobs.ErrorDialFailed},
+	{ErrHandshakeFailed, jobs.ErrorHandshakeFailed},
+	{ErrAuthFailed, jobs.ErrorAuthFailed},
+	{ErrHostKeyMismatch, jobs.ErrorHostKeyMismatch},
+	{ErrSessionFailed, jobs.ErrorSessionFailed},
+	{ErrTimeout, jobs.ErrorTimeout},
+	{ErrCancelled, jobs.ErrorCancelled},
+	{ErrRemoteExitNonZero, jobs.ErrorRemoteExitNonZero},
+	{ErrRemoteSignal, jobs.ErrorRemoteSignal},
+	{ErrTransferFailed, jobs.ErrorTransferFailed},
+	{ErrBecomeFailed, jobs.ErrorBecomeFailed},
+	{ErrBecomeAuthFailed, jobs.ErrorBecomeAuthFailed},
+	{ErrExpectFailed, jobs.ErrorExpectFailed},
+}
+
+// Classify returns the error class of an Execute error, or "" for nil and unclassified errors
+func Classify(err error) jobs.ErrorClass {
+	if err == nil {
+		return ""
+	}
+	for _, c := range errorClasses {
+		if errors.Is(err, c.sentinel) {
+			return c.class
+		}
+	}
+
+	return ""
+}
+
+// classifiedError tags err with a sentinel without changing its message
+type classifiedError struct {
+	sentinel error
+	err      error
+}
+
+func (e *classifiedError) Error() string   { return e.err.Error() }
+func (e *classifiedError) Unwrap() []error { return []error{e.sentinel, e.err} }
+
+func classify(sentinel, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &classifiedError{sentinel: sentinel, err: err}
+}
+
+// handshakeError picks the most specific class for a failed ssh.NewClientConn
+func handshakeError(err error) error {
+	switch {
+	case errors.Is(err, ErrHostKeyMismatch):
+		return err
+	case strings.Contains(err.Error(), "unable to authenticate"):
+		// x/crypto/ssh has no typed error for rejected credentials
+		return classify(ErrAuthFailed, err)
+	default:
+		return classify(ErrHandshakeFailed, err)
+	}
+}
+
+// runError classifies the outcome of session.Run
+func runError(err error) error {
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &exitErr) && exitErr.Signal() != "":
+		return classify(ErrRemoteSignal, err)
+	case errors.As(err, &exitErr):
+		return classify(ErrRemoteExitNonZero, err)
+	default:
+		return classify(ErrSessionFailed, err)
+	}
+}
+
+// contextError classifies why the run context ended, keeping how the remote command exited when it did
+func contextError(ctxErr, runErr error) error {
+	sentinel := ErrCancelled
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		sentinel = ErrTimeout
+	}
+	if runErr == nil {
+		return fmt.Errorf("%w: %w", sentinel, ctxErr)
+	}
+
+	return fmt.Errorf("%w: %w (remote: %w)", sentinel, ctxErr, runErr)
+}
@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// coreDumpedRequest asks a session's channel whether its command dumped core. signalChannel
+// answers it locally; it is never sent to the server
+const coreDumpedRequest = "core-dumped@orchestration-engine"
+
+// signalConn wraps a client connection so its session channels note the core-dump flag of their
+// exit-signal request, which ssh.Session drops before building its ExitError
+type signalConn struct {
+	ssh.Conn
+}
+
+// exitSignalMsg is the payload of an exit-signal request (RFC 4254 section 6.10)
+type exitSignalMsg struct {
+	Signal     string
+	CoreDumped bool
+	Error      string
+	Lang       string
+}
+
+func (c signalConn) OpenChannel(name string, data []byte) (ssh.Channel, <-chan *ssh.Request, error) {
+	ch, in, err := c.Conn.OpenChannel(name, data)
+	if err != nil || name != "session" {
+		return ch, in, err
+	}
+
+	sc := &signalChannel{Channel: ch}
+	out := make(chan *ssh.Request)
+	go func() {
+		// Requests are passed on unchanged; the session still builds its ExitError from them
+		defer close(out)
+		for req := range in {
+			var msg exitSignalMsg
+			if req.Type == "exit-signal" && ssh.Unmarshal(req.Payload, &msg) == nil && msg.CoreDumped {
+				sc.coreDumped.Store(true)
+			}
+			out <- req
+		}
+	}()
+
+	return sc, out, nil
+}
+
+type signalChannel struct {
+	ssh.Channel
+	coreDumped atomic.Bool
+}
+
+func (c *signalChannel) SendRequest(name string, wantReply bool, payload []byte) (bool, error) {
+	if name == coreDumpedRequest {
+		return c.coreDumped.Load(), nil
+	}
+
+	return c.Channel.SendRequest(name, wantReply, payload)
+}
+
+// coreDumped reports whether the session's command was killed by a signal and dumped core. Only
+// sessions of clients dialed by newClient can tell
+func coreDumped(client *ssh.Client, session *ssh.Session) bool {
+	if _, ok := client.Conn.(signalConn); !ok {
+		return false
+	}
+	dumped, _ := session.SendRequest(coreDumpedRequest, false, nil)
+
+	return dumped
+}
@@ -0,0 +1,259 @@
+package executor
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// maxExpectBuffer bounds the unmatched output a step searches; older output is dropped
+const maxExpectBuffer = 64 << 10
+
+// maxTranscriptOutput is how much of the output leading up to each step the transcript keeps
+const maxTranscriptOutput = 4 << 10
+
+// expecter watches terminal output and answers the job's expect steps in order
+type expecter struct {
+	job      jobs.JobDefinition
+	patterns []*regexp.Regexp
+	secrets  []string
+
+	mu         sync.Mutex
+	buf        []byte
+	transcript []jobs.TranscriptEntry
+
+	notify chan struct{}
+	// failCh reports a step that timed out, saw the command exit or could not send its reply;
+	// finished closes when run returns
+	failCh   chan error
+	finished chan struct{}
+}
+
+// newExpecter prepares the job's steps, which Validate already checked compile
+func newExpecter(job jobs.JobDefinition) *expecter {
+	x := &expecter{job: job, notify: make(chan struct{}, 1), failCh: make(chan error, 1), finished: make(chan struct{})}
+	for _, step := range job.Expect {
+		x.patterns = append(x.patterns, regexp.MustCompile(step.Expect))
+		if step.SendSecret == jobs.SecretPassword {
+			if value, _ := job.SecretValue(jobs.SecretPassword); value != "" {
+				x.secrets = append(x.secrets, value)
+			}
+		}
+	}
+	for _, value := range job.Secrets {
+		if value != "" {
+			x.secrets = append(x.secrets, value)
+		}
+	}
+	// Longer secrets first, so one that contains another is masked whole
+	slices.SortFunc(x.secrets, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+
+	return x
+}
+
+func (x *expecter) Write(p []byte) (int, error) {
+	x.mu.Lock()
+	x.buf = append(x.buf, p...)
+	if over := len(x.buf) - maxExpectBuffer; over > 0 {
+		x.buf = x.buf[:copy(x.buf, x.buf[over:])]
+	}
+	x.mu.Unlock()
+
+	select {
+	case x.notify <- struct{}{}:
+	default:
+	}
+
+	return len(p), nil
+}
+
+// run waits for each step's pattern and types its reply into in. It returns when every step
+// answered, ctx ended or a step failed, in which case the failure is sent on failCh
+func (x *expecter) run(ctx context.Context, in io.Writer, exited <-chan struct{}) {
+	defer close(x.finished)
+	for i, step := range x.job.Expect {
+		started := time.Now()
+		output, err := x.await(ctx, i, exited)
+		entry := jobs.TranscriptEntry{
+			Step:      i + 1,
+			Expect:    step.Expect,
+			Output:    x.mask(tail(output, maxTranscriptOutput)),
+			Matched:   err == nil && ctx.Err() == nil,
+			ElapsedMs: time.Since(started).Milliseconds(),
+		}
+		if !entry.Matched {
+			x.record(entry)
+			if err != nil {
+				x.failCh <- err
+			}
+			return
+		}
+
+		reply, sent := step.Send, x.mask(step.Send)
+		if step.SendSecret != "" {
+			reply, _ = x.job.SecretValue(step.SendSecret)
+			sent = jobs.RedactedValue
+		}
+		entry.Sent = sent
+		x.record(entry)
+		if !step.NoEnter {
+			reply += "\r"
+		}
+		if _, err := io.WriteString(in, reply); err != nil {
+			x.failCh <- classify(ErrExpectFailed, fmt.Errorf("step %d: send reply: %w", i+1, err))
+			return
+		}
+	}
+}
+
+// await returns the output up to and including the first match of step i, consuming it.
+// On failure it returns everything seen so far
+func (x *expecter) await(ctx context.Context, i int, exited <-chan struct{}) ([]byte, error) {
+	step := x.job.Expect[i]
+	timer := time.NewTimer(time.Duration(step.Timeout()) * time.Second)
+	defer timer.Stop()
+
+	ended := false
+	for {
+		x.mu.Lock()
+		if loc := x.patterns[i].FindIndex(x.buf); loc != nil {
+			output := bytes.Clone(x.buf[:loc[1]])
+			x.buf = x.buf[:copy(x.buf, x.buf[loc[1]:])]
+			x.mu.Unlock()
+			return output, nil
+		}
+		pending := bytes.Clone(x.buf)
+		x.mu.Unlock()
+
+		// The session copies all output before it reports the exit, so one last look is enough
+		if ended {
+			return pending, classify(ErrExpectFailed, fmt.Errorf("step %d: command exited before %q matched", i+1, step.Expect))
+		}
+		select {
+		case <-x.notify:
+		case <-exited:
+			ended = true
+		case <-timer.C:
+			return pending, classify(ErrExpectFailed, fmt.Errorf("step %d: no match for %q within %ds", i+1, step.Expect, step.Timeout()))
+		case <-ctx.Done():
+			return pending, nil
+		}
+	}
+}
+
+func (x *expecter) record(entry jobs.TranscriptEntry) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.transcript = append(x.transcript, entry)
+}
+
+// result reports a step left unanswered by a command that exited cleanly, or returns runErr.
+// It waits for run, which returns soon after the command exited
+func (x *expecter) result(runErr error) error {
+	<-x.finished
+	select {
+	case err := <-x.failCh:
+		if runErr == nil {
+			return err
+		}
+	default:
+	}
+
+	return runErr
+}
+
+// entries returns the transcript recorded so far
+func (x *expecter) entries() []jobs.TranscriptEntry {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	return slices.Clone(x.transcript)
+}
+
+func (x *expecter) mask(s string) string {
+	for _, secret := range x.secrets {
+		s = strings.ReplaceAll(s, secret, jobs.RedactedValue)
+	}
+
+	return s
+}
+
+func tail(p []byte, n int) string {
+	if len(p) > n {
+		p = p[len(p)-n:]
+	}
+
+	return string(p)
+}
+
+// maskWriter replaces secrets in a stream before it is captured or streamed live. It holds back
+// a trailing partial match until the next write shows whether it is a secret; flush releases it
+type maskWriter struct {
+	mu      sync.Mutex
+	next    io.Writer
+	secrets []string
+	pending []byte
+}
+
+func (w *maskWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	out, rest := w.scan(append(w.pending, p...), false)
+	w.pending = bytes.Clone(rest)
+	if len(out) > 0 {
+		if _, err := w.next.Write(out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// flush writes whatever is still held back
+func (w *maskWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if out, _ := w.scan(w.pending, true); len(out) > 0 {
+		w.next.Write(out)
+	}
+	w.pending = nil
+}
+
+// scan masks every secret in data. Unless final, it stops at a suffix that could still become a
+// secret and returns that suffix as rest
+func (w *maskWriter) scan(data []byte, final bool) (out, rest []byte) {
+	out = make([]byte, 0, len(data))
+outer:
+	for i := 0; i < len(data); {
+		for _, secret := range w.secrets {
+			if bytes.HasPrefix(data[i:], []byte(secret)) {
+				out = append(out, jobs.RedactedValue...)
+				i += len(secret)
+				continue outer
+			}
+		}
+		if !final {
+			for _, secret := range w.secrets {
+				if len(data)-i < len(secret) && strings.HasPrefix(secret, string(data[i:])) {
+					return out, data[i:]
+				}
+			}
+		}
+		out = append(out, data[i])
+		i++
+	}
+
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMaskWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		writes  []string
+		want    string
+	}{
+		{"no secrets", nil, []string{"plain ", "output"}, "plain output"},
+		{"whole secret", []string{"hunter2"}, []string{"pw hunter2 ok"}, "pw [REDACTED] ok"},
+		{"secret split across writes", []string{"hunter2"}, []string{"pw hun", "te", "r2 ok"}, "pw [REDACTED] ok"},
+		{"partial match released", []string{"hunter2"}, []string{"hunt", "ing"}, "hunting"},
+		{"partial match at the end is flushed", []string{"hunter2"}, []string{"a hunte"}, "a hunte"},
+		{"repeated secret", []string{"pin"}, []string{"pinpin", " pin"}, "[REDACTED][REDACTED] [REDACTED]"},
+		{"several secrets", []string{"alpha", "beta"}, []string{"al", "pha+be", "ta"}, "[REDACTED]+[REDACTED]"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		w := &maskWriter{next: &out, secrets: tt.secrets}
+		for _, s := range tt.writes {
+			if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+				t.Errorf("%s: Write(%q) = %d, %v", tt.name, s, n, err)
+			}
+		}
+		w.flush()
+		if got := out.String(); got != tt.want {
+			t.Errorf("%s: output %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMaskWriterHoldsBackPartialSecret(t *testing.T) {
+	var out bytes.Buffer
+	w := &maskWriter{next: &out, secrets: []string{"hunter2"}}
+
+	w.Write([]byte("password: hunt"))
+	if got := out.String(); got != "password: " {
+		t.Errorf("before the secret completes, output %q", got)
+	}
+	w.Write([]byte("er2\n"))
+	if got := out.String(); got != "password: [REDACTED]\n" {
+		t.Errorf("after the secret completes, output %q", got)
+	}
+}
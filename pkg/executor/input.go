@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// openStdin returns the job's standard input, or nil when it has none. The caller closes it
+func (e *SSHExecutor) openStdin(ctx context.Context, job jobs.JobDefinition) (io.ReadCloser, error) {
+	switch {
+	case job.Stdin == nil:
+		return nil, nil
+	case job.Stdin.Artifact == "":
+		return io.NopCloser(strings.NewReader(job.Stdin.Data)), nil
+	case e.ArtifactSource == nil:
+		return nil, classify(ErrTransferFailed, errors.New("engine has no artifact source for stdin"))
+	}
+
+	src, _, err := e.ArtifactSource(ctx, job.StdinJob(), job.Stdin.Artifact)
+	if err != nil {
+		return nil, classify(ErrTransferFailed, fmt.Errorf("fetch stdin %s: %w", job.Stdin.Artifact, err))
+	}
+
+	return src, nil
+}
+
+// applyEnv sends the job's environment as SSH env requests. Servers only accept names listed in
+// their AcceptEnv, so it returns envPrefix of the refused variables to carry them instead. Secret
+// values never go on the command line: a refused secret fails the job
+func applyEnv(session *ssh.Session, job jobs.JobDefinition) (string, error) {
+	refused := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(job.Env)) {
+		if err := session.Setenv(name, job.Env[name]); err == nil {
+			continue
+		}
+		if slices.Contains(job.SecretEnv, name) {
+			return "", refusedSecret(name)
+		}
+		refused[name] = job.Env[name]
+	}
+
+	return envPrefix(refused), nil
+}
+
+// applyBecomeEnv prepares the environment of a become job. sudo and su drop most of the login
+// session's environment, so plain variables go on the command line as envPrefix; secrets never
+// do: they are sent as SSH env requests, which su keeps and sudo is told to preserve
+func applyBecomeEnv(session *ssh.Session, job jobs.JobDefinition) (string, error) {
+	plain := make(map[string]string)
+	for _, name := range slices.Sorted(maps.Keys(job.Env)) {
+		if !slices.Contains(job.SecretEnv, name) {
+			plain[name] = job.Env[name]
+			continue
+		}
+		if err := session.Setenv(name, job.Env[name]); err != nil {
+			return "", refusedSecret(name)
+		}
+	}
+
+	return envPrefix(plain), nil
+}
+
+func refusedSecret(name string) error {
+	return classify(ErrValidation, fmt.Errorf("server refused secret env %s; secrets are only sent as SSH environment requests, so it must be listed in the server's AcceptEnv", name))
+}
+
+// deniedEnv lists variables that change how the remote shell, dynamic linker or interpreters
+// start, so a job could run something other than its checksummed command
+var deniedEnv = map[string]bool{
+	"BASH_ENV": true, "ENV": true, "PATH": true, "IFS": true, "SHELLOPTS": true, "BASHOPTS": true,
+	"PS4": true, "PROMPT_COMMAND": true, "CDPATH": true, "GLOBIGNORE": true, "GLIBC_TUNABLES": true,
+	"HOSTALIASES": true, "PYTHONPATH": true, "PYTHONSTARTUP": true, "PERL5LIB": true, "PERL5OPT": true,
+	"RUBYLIB": true, "RUBYOPT": true, "NODE_OPTIONS": true, "JAVA_TOOL_OPTIONS": true,
+}
+
+// deniedEnvPrefixes covers the dynamic linker's variables on Linux and macOS
+var deniedEnvPrefixes = []string{"LD_", "DYLD_"}
+
+// envDenied reports whether a job may not set the variable name
+func envDenied(name string) bool {
+	if deniedEnv[name] {
+		return true
+	}
+	for _, prefix := range deniedEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// envPrefix returns an `env NAME=value ... ` command prefix, or "" for an empty environment.
+// It puts values on the remote command line, where other local users can see them
+func envPrefix(env map[string]string) string {
+	if len(env) == 0 {
+		return ""
+	}
+
+	words := []string{"env"}
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		words = append(words, jobs.ShellQuote(name+"="+env[name]))
+	}
+
+	return strings.Join(words, " ") + " "
+}
@@ -0,0 +1,33 @@
+package executor
+
+import "testing"
+
+func TestEnvDenied(t *testing.T) {
+	tests := map[string]bool{
+		"LANG":              false,
+		"APP_TOKEN":         false,
+		"PATHS":             false,
+		"PATH":              true,
+		"LD_PRELOAD":        true,
+		"LD_AUDIT":          true,
+		"DYLD_LIBRARY_PATH": true,
+		"BASH_ENV":          true,
+		"ENV":               true,
+		"IFS":               true,
+	}
+	for name, want := range tests {
+		if got := envDenied(name); got != want {
+			t.Errorf("envDenied(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestEnvPrefix(t *testing.T) {
+	if got := envPrefix(nil); got != "" {
+		t.Errorf("envPrefix(nil) = %q", got)
+	}
+	got := envPrefix(map[string]string{"B": "it's", "A": "1"})
+	if want := `env A=1 'B=it'\''s' `; got != want {
+		t.Errorf("envPrefix = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+package executor
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// DefaultMaxOutputBytes caps each captured stream when the executor sets no limit
+const DefaultMaxOutputBytes = 1 << 20
+
+// boundedOutput keeps the first and last halves of limit bytes of a stream and counts the rest,
+// so a runaway command cannot grow engine or controller memory
+type boundedOutput struct {
+	headCap, tailCap int
+	head, tail       []byte
+	total            int64
+}
+
+func newBoundedOutput(limit int64) *boundedOutput {
+	return &boundedOutput{headCap: int(limit / 2), tailCap: int(limit - limit/2)}
+}
+
+func (b *boundedOutput) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+
+	rest := p
+	if room := b.headCap - len(b.head); room > 0 {
+		n := min(room, len(rest))
+		b.head = append(b.head, rest[:n]...)
+		rest = rest[n:]
+	}
+
+	b.tail = append(b.tail, rest...)
+	if over := len(b.tail) - b.tailCap; over > 0 {
+		// Shift in place so the backing array stays near tailCap
+		b.tail = b.tail[:copy(b.tail, b.tail[over:])]
+	}
+
+	return len(p), nil
+}
+
+func (b *boundedOutput) truncated() bool {
+	return b.total > int64(len(b.head)+len(b.tail))
+}
+
+// String joins head and tail with a marker saying how much was dropped in between
+func (b *boundedOutput) String() string {
+	if !b.truncated() {
+		return string(b.head) + string(b.tail)
+	}
+
+	omitted := b.total - int64(len(b.head)+len(b.tail))
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", b.head, omitted, b.tail)
+}
+
+// spool streams a full copy of one output stream, gzip-compressed, into a temp file
+type spool struct {
+	name string
+	file *os.File
+	gz   *gzip.Writer
+}
+
+// jobOutput is everything captured from one run: bounded inline copies and, when the job
+// asked for it, full compressed spools that are uploaded as artifacts afterwards.
+// mu guards against a session that never unwound still writing while the result is built
+type jobOutput struct {
+	mu             sync.Mutex
+	stdout, stderr *boundedOutput
+	spools         map[string]*spool // keyed by stream name
+}
+
+func (e *SSHExecutor) newJobOutput(job jobs.JobDefinition) (*jobOutput, error) {
+	limit := e.MaxOutputBytes
+	if limit <= 0 {
+		limit = DefaultMaxOutputBytes
+	}
+	if job.MaxOutputBytes > 0 {
+		limit = min(limit, job.MaxOutputBytes)
+	}
+
+	out := &jobOutput{stdout: newBoundedOutput(limit), stderr: newBoundedOutput(limit)}
+	if !job.UploadOutput || e.ArtifactSink == nil {
+		return out, nil
+	}
+
+	out.spools = make(map[string]*spool, 2)
+	for _, stream := range []string{"stdout", "stderr"} {
+		f, err := os.CreateTemp(e.SpoolDir, "job-"+stream+"-*.gz")
+		if err != nil {
+			out.discard()
+			return nil, fmt.Errorf("create output spool: %w", err)
+		}
+		out.spools[stream] = &spool{name: stream + ".gz", file: f, gz: gzip.NewWriter(f)}
+	}
+
+	return out, nil
+}
+
+// writer returns the inline buffer for stream, teed into its spool when one exists
+func (o *jobOutput) writer(stream string) io.Writer {
+	buf := o.stdout
+	if stream == "stderr" {
+		buf = o.stderr
+	}
+	var w io.Writer = buf
+	if sp, ok := o.spools[stream]; ok {
+		w = io.MultiWriter(buf, sp.gz)
+	}
+
+	return lockedWriter{mu: &o.mu, w: w}
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
+
+// fill copies the captured output and its accounting into result
+func (o *jobOutput) fill(result *jobs.Result) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	result.Stdout, result.StdoutBytes, result.StdoutTruncated = o.stdout.String(), o.stdout.total, o.stdout.truncated()
+	result.Stderr, result.StderrBytes, result.StderrTruncated = o.stderr.String(), o.stderr.total, o.stderr.truncated()
+}
+
+// upload hands each spool to sink and records the stored artifacts on result.
+// Upload failures are reported in result.Error but do not change the job status
+func (o *jobOutput) upload(ctx context.Context, jobID string, sink func(ctx context.Context, jobID, name string, r io.Reader) (jobs.Artifact, error), result *jobs.Result) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	defer o.discard()
+
+	var errs []error
+	for _, stream := range []string{"stdout", "stderr"} {
+		sp, ok := o.spools[stream]
+		if !ok {
+			continue
+		}
+		if err := sp.gz.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sp.name, err))
+			continue
+		}
+		if _, err := sp.file.Seek(0, io.SeekStart); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sp.name, err))
+			continue
+		}
+
+		artifact, err := sink(ctx, jobID, sp.name, sp.file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sp.name, err))
+			continue
+		}
+		result.Artifacts = append(result.Artifacts, artifact)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		msg := "output artifact upload failed: " + err.Error()
+		if result.Error != "" {
+			msg = result.Error + "; " + msg
+		}
+		result.Error = msg
+	}
+}
+
+// discard removes spool files
+func (o *jobOutput) discard() {
+	for _, sp := range o.spools {
+		sp.file.Close()
+		os.Remove(sp.file.Name())
+	}
+}
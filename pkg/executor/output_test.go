@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBoundedOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int64
+		writes    []string
+		want      string
+		truncated bool
+	}{
+		{"under limit", 10, []string{"abc", "def"}, "abcdef", false},
+		{"exactly limit", 6, []string{"abc", "def"}, "abcdef", false},
+		{"one write over", 6, []string{"abcdefghij"}, "abc\n... [4 bytes truncated] ...\nhij", true},
+		{"many small writes", 4, []string{"a", "b", "c", "d", "e", "f"}, "ab\n... [2 bytes truncated] ...\nef", true},
+		{"odd limit keeps the larger tail", 5, []string{"0123456789"}, "01\n... [5 bytes truncated] ...\n789", true},
+		{"zero limit", 0, []string{"abc"}, "\n... [3 bytes truncated] ...\n", true},
+	}
+	for _, tt := range tests {
+		b := newBoundedOutput(tt.limit)
+		var total int64
+		for _, w := range tt.writes {
+			n, err := b.Write([]byte(w))
+			if n != len(w) || err != nil {
+				t.Errorf("%s: Write(%q) = %d, %v", tt.name, w, n, err)
+			}
+			total += int64(len(w))
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("%s: String() = %q, want %q", tt.name, got, tt.want)
+		}
+		if b.truncated() != tt.truncated || b.total != total {
+			t.Errorf("%s: truncated %v total %d, want %v and %d", tt.name, b.truncated(), b.total, tt.truncated, total)
+		}
+	}
+}
+
+func TestBoundedOutputTailStaysBounded(t *testing.T) {
+	b := newBoundedOutput(64)
+	chunk := []byte(strings.Repeat("x", 1000))
+	for range 1000 {
+		b.Write(chunk)
+	}
+
+	if len(b.head) != 32 || len(b.tail) != 32 || cap(b.tail) > 2*len(chunk) {
+		t.Errorf("head %d tail %d (cap %d) after 1MB of output", len(b.head), len(b.tail), cap(b.tail))
+	}
+}
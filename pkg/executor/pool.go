@@ -0,0 +1,401 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Pool defaults, used when the matching field is unset
+const (
+	DefaultPoolMaxSessions = 5
+	DefaultPoolIdleTimeout = 5 * time.Minute
+	DefaultPoolKeepalive   = 30 * time.Second
+)
+
+// keepaliveTimeout bounds how long a health check waits for the server's reply
+const keepaliveTimeout = 5 * time.Second
+
+// Pool reuses SSH connections across jobs that log in to the same address as the same user with
+// the same password and expected host key, running each job on its own session. Its zero value is
+// ready to use; Run evicts idle connections and checks the rest in the background
+type Pool struct {
+	// MaxSessions caps the jobs sharing one connection at a time. Scripts and transfers may open a
+	// second channel, so keep it at half the server's MaxSessions (OpenSSH allows 10) or less
+	MaxSessions int
+	// IdleTimeout closes connections no job has used for that long
+	IdleTimeout time.Duration
+	// Keepalive is how long a connection may go unused before it is checked with a keepalive
+	// request ahead of reuse; Run also checks idle connections this often
+	Keepalive time.Duration
+
+	mu     sync.Mutex
+	conns  map[poolKey][]*pooledConn
+	closed bool
+}
+
+// poolKey identifies the connections a job may share. The password only enters as part of a digest
+type poolKey struct {
+	address  string
+	user     string
+	identity string // digest of the password and the expected host key fingerprint
+}
+
+type pooledConn struct {
+	client   *ssh.Client   // nil until ready closes
+	ready    chan struct{} // closed once the dial finished, with err set if it failed
+	err      error
+	key      poolKey
+	sessions int       // jobs currently using the connection
+	lastUsed time.Time // last release, or creation
+	checking bool      // a background health check is running; not handed out meanwhile
+	retired  bool      // credentials or host key changed; closed once the last job releases it
+}
+
+func poolKeyFor(creds SSHCredentials) poolKey {
+	sum := sha256.Sum256([]byte(creds.Password + "\x00" + creds.Fingerprint))
+	return poolKey{address: creds.Address, user: creds.Username, identity: hex.EncodeToString(sum[:])}
+}
+
+// connect returns a client for creds and the function that gives it back once the job is done
+func (e *SSHExecutor) connect(ctx context.Context, creds SSHCredentials) (*ssh.Client, func(), error) {
+	if e.Pool == nil {
+		client, err := e.newClient(ctx, creds)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func() { client.Close() }, nil
+	}
+
+	return e.Pool.acquire(ctx, creds, e.newClient)
+}
+
+// acquire hands out a pooled connection for creds, dialing a new one when none has room
+func (p *Pool) acquire(ctx context.Context, creds SSHCredentials, dial func(context.Context, SSHCredentials) (*ssh.Client, error)) (*ssh.Client, func(), error) {
+	key := poolKeyFor(creds)
+	for {
+		pc, dialer, stale := p.claim(key)
+		switch {
+		case pc == nil:
+			// The pool is closed
+			client, err := dial(ctx, creds)
+			if err != nil {
+				return nil, nil, err
+			}
+			return client, func() { client.Close() }, nil
+		case dialer:
+			return p.dial(ctx, creds, pc, dial)
+		}
+
+		// Jobs arriving while a connection is being dialed share it instead of dialing their own
+		select {
+		case <-pc.ready:
+		case <-ctx.Done():
+			p.releaser(pc)()
+			return nil, nil, contextError(ctx.Err(), nil)
+		}
+		if pc.err != nil {
+			// Waiters share the dialer's credentials and expected host key, so a rejection is
+			// theirs too; redialing would only repeat it against the server. Other failures may
+			// be transient, so dial for ourselves and report this job's own error
+			if permanentDialError(pc.err) {
+				return nil, nil, pc.err
+			}
+			continue
+		}
+
+		// A connection unused for a while may have been dropped by the server or a firewall
+		if stale {
+			if !healthy(pc.client) {
+				p.discard(pc)
+				continue
+			}
+			p.mu.Lock()
+			pc.checking = false
+			p.mu.Unlock()
+		}
+		return pc.client, p.releaser(pc), nil
+	}
+}
+
+// dial connects the placeholder pc that claim added, then lets the jobs waiting on it proceed
+func (p *Pool) dial(ctx context.Context, creds SSHCredentials, pc *pooledConn, dial func(context.Context, SSHCredentials) (*ssh.Client, error)) (*ssh.Client, func(), error) {
+	client, err := dial(ctx, creds)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer close(pc.ready)
+
+	if err != nil {
+		pc.err = err
+		p.removeLocked(pc)
+		p.invalidateLocked(pc.key, err)
+		return nil, nil, err
+	}
+	pc.client = client
+	pc.lastUsed = time.Now()
+	if p.closed {
+		pc.retired = true
+	}
+	// The server accepted these credentials, so connections logged in with others are out of date.
+	// Retiring them any earlier would drop working connections for credentials that may not work
+	p.retireLocked(func(c *pooledConn) bool {
+		return c.key.address == pc.key.address && c.key.user == pc.key.user && c.key.identity != pc.key.identity
+	})
+	go func() {
+		// Forget connections the server or network closed
+		client.Wait()
+		p.remove(pc)
+	}()
+
+	return client, p.releaser(pc), nil
+}
+
+// claim reserves a session for key on an open connection, or on one being dialed, reporting
+// whether it needs a health check first; it is not handed out again until the check is done.
+// When none has room it adds a placeholder the caller must dial. It returns nil once the pool is closed
+func (p *Pool) claim(key poolKey) (pc *pooledConn, dialer, stale bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, false, false
+	}
+
+	now := time.Now()
+	for _, pc := range p.conns[key] {
+		if pc.retired || pc.checking || pc.sessions >= p.maxSessions() {
+			continue
+		}
+		pc.sessions++
+		stale := pc.client != nil && pc.sessions == 1 && now.Sub(pc.lastUsed) >= p.keepalive()
+		pc.checking = stale
+		return pc, false, stale
+	}
+
+	pc = &pooledConn{key: key, sessions: 1, lastUsed: now, ready: make(chan struct{})}
+	if p.conns == nil {
+		p.conns = make(map[poolKey][]*pooledConn)
+	}
+	p.conns[key] = append(p.conns[key], pc)
+
+	return pc, true, false
+}
+
+// releaser returns the function that gives a claimed session back, once
+func (p *Pool) releaser(pc *pooledConn) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			pc.sessions--
+			pc.lastUsed = time.Now()
+			p.closeIfRetiredLocked(pc)
+		})
+	}
+}
+
+// closeIfRetiredLocked closes a retired connection once nothing uses it any more
+func (p *Pool) closeIfRetiredLocked(pc *pooledConn) {
+	if pc.retired && pc.sessions == 0 && !pc.checking {
+		p.removeLocked(pc)
+		go pc.client.Close()
+	}
+}
+
+// hangUp retires the connection of a session that would not end, so no job gets it again. Other
+// jobs may still be using it; it closes once the last of them releases it. It reports false when
+// client is not one of the pool's connections
+func (p *Pool) hangUp(client *ssh.Client) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, conns := range p.conns {
+		for _, pc := range conns {
+			if pc.client == client {
+				p.retireLocked(func(c *pooledConn) bool { return c == pc })
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// invalidateLocked retires connections a failed dial shows to be out of date: every connection to the
+// address after a host key mismatch, and those of the same user after an authentication failure
+func (p *Pool) invalidateLocked(key poolKey, err error) {
+	switch {
+	case errors.Is(err, ErrHostKeyMismatch):
+		p.retireLocked(func(c *pooledConn) bool { return c.key.address == key.address })
+	case errors.Is(err, ErrAuthFailed):
+		p.retireLocked(func(c *pooledConn) bool { return c.key.address == key.address && c.key.user == key.user })
+	}
+}
+
+// permanentDialError reports whether a dial failed in a way another attempt with the same
+// credentials and host key cannot fix
+func permanentDialError(err error) bool {
+	return errors.Is(err, ErrHostKeyMismatch) || errors.Is(err, ErrAuthFailed)
+}
+
+// retireLocked stops handing out connections that match, closing the idle ones now and the rest
+// when their last job finishes
+func (p *Pool) retireLocked(match func(*pooledConn) bool) {
+	var matched []*pooledConn
+	for _, conns := range p.conns {
+		for _, pc := range conns {
+			if !pc.retired && match(pc) {
+				matched = append(matched, pc)
+			}
+		}
+	}
+	for _, pc := range matched {
+		pc.retired = true
+		p.closeIfRetiredLocked(pc)
+	}
+}
+
+// discard drops a connection that failed its health check
+func (p *Pool) discard(pc *pooledConn) {
+	p.remove(pc)
+	pc.client.Close()
+}
+
+func (p *Pool) remove(pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.removeLocked(pc)
+}
+
+func (p *Pool) removeLocked(pc *pooledConn) {
+	conns := slices.DeleteFunc(p.conns[pc.key], func(c *pooledConn) bool { return c == pc })
+	if len(conns) == 0 {
+		delete(p.conns, pc.key)
+		return
+	}
+	p.conns[pc.key] = conns
+}
+
+// Run closes idle connections past IdleTimeout and checks the others with keepalives every
+// Keepalive until stop closes, then closes the pool
+func (p *Pool) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.keepalive())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			p.Close()
+			return
+		case <-ticker.C:
+			p.sweep()
+		}
+	}
+}
+
+// sweep evicts connections idle past IdleTimeout and health-checks the remaining idle ones.
+// Connections being checked are not handed out, so no job picks up one that is about to be dropped
+func (p *Pool) sweep() {
+	now := time.Now()
+	var expired, checks []*pooledConn
+
+	p.mu.Lock()
+	for _, conns := range p.conns {
+		for _, pc := range conns {
+			switch {
+			case pc.sessions > 0 || pc.checking:
+			case now.Sub(pc.lastUsed) >= p.idleTimeout():
+				expired = append(expired, pc)
+			default:
+				pc.checking = true
+				checks = append(checks, pc)
+			}
+		}
+	}
+	for _, pc := range expired {
+		p.removeLocked(pc)
+	}
+	p.mu.Unlock()
+
+	for _, pc := range expired {
+		pc.client.Close()
+	}
+	for _, pc := range checks {
+		if !healthy(pc.client) {
+			p.discard(pc)
+			continue
+		}
+		p.mu.Lock()
+		pc.checking = false
+		p.closeIfRetiredLocked(pc)
+		p.mu.Unlock()
+	}
+}
+
+// Close closes every pooled connection, including those in use. Later jobs dial their own
+func (p *Pool) Close() {
+	p.mu.Lock()
+	var clients []*ssh.Client
+	for _, conns := range p.conns {
+		for _, pc := range conns {
+			// Connections still being dialed are closed when their jobs finish
+			if pc.client != nil {
+				clients = append(clients, pc.client)
+			}
+		}
+	}
+	p.conns = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, client := range clients {
+		client.Close()
+	}
+}
+
+// healthy sends an OpenSSH keepalive request. Any reply, even a refusal, shows the connection works
+func healthy(client *ssh.Client) bool {
+	replied := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		replied <- err
+	}()
+
+	select {
+	case err := <-replied:
+		return err == nil
+	case <-time.After(keepaliveTimeout):
+		return false
+	}
+}
+
+func (p *Pool) maxSessions() int {
+	if p.MaxSessions <= 0 {
+		return DefaultPoolMaxSessions
+	}
+	return p.MaxSessions
+}
+
+func (p *Pool) idleTimeout() time.Duration {
+	if p.IdleTimeout <= 0 {
+		return DefaultPoolIdleTimeout
+	}
+	return p.IdleTimeout
+}
+
+func (p *Pool) keepalive() time.Duration {
+	if p.Keepalive <= 0 {
+		return DefaultPoolKeepalive
+	}
+	return p.Keepalive
+}
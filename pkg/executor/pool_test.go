@@ -0,0 +1,433 @@
+package executor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// pipeClient returns an SSH client connected to a loopback server that accepts anyone, and a
+// channel closed once the client's connection is gone
+func pipeClient(t *testing.T) (*ssh.Client, <-chan struct{}) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	// net.Pipe would deadlock: both ends send their version line before reading
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	clientSide, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverSide, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		defer serverSide.Close()
+		conn, chans, reqs, err := ssh.NewServerConn(serverSide, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			for ch := range chans {
+				ch.Reject(ssh.Prohibited, "no channels")
+			}
+		}()
+		conn.Wait()
+	}()
+
+	conn, chans, reqs, err := ssh.NewClientConn(clientSide, "pipe", &ssh.ClientConfig{User: "ops", HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := ssh.NewClient(conn, chans, reqs)
+	t.Cleanup(func() { client.Close() })
+
+	return client, gone
+}
+
+// fakeDial counts dials; the first blocks until release is closed and fails with firstErr when set
+type fakeDial struct {
+	t        *testing.T
+	release  chan struct{}
+	firstErr error
+
+	mu    sync.Mutex
+	calls int
+	gone  map[*ssh.Client]<-chan struct{}
+}
+
+func newFakeDial(t *testing.T, firstErr error) *fakeDial {
+	return &fakeDial{t: t, release: make(chan struct{}), firstErr: firstErr, gone: make(map[*ssh.Client]<-chan struct{})}
+}
+
+func (d *fakeDial) dial(ctx context.Context, creds SSHCredentials) (*ssh.Client, error) {
+	d.mu.Lock()
+	d.calls++
+	first := d.calls == 1
+	d.mu.Unlock()
+
+	if first {
+		<-d.release
+		if d.firstErr != nil {
+			return nil, d.firstErr
+		}
+	}
+	client, gone := pipeClient(d.t)
+	d.mu.Lock()
+	d.gone[client] = gone
+	d.mu.Unlock()
+
+	return client, nil
+}
+
+func (d *fakeDial) dials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.calls
+}
+
+// closed reports whether client's connection was closed within a second
+func (d *fakeDial) closed(client *ssh.Client) bool {
+	d.mu.Lock()
+	gone := d.gone[client]
+	d.mu.Unlock()
+
+	select {
+	case <-gone:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+// waitForSessions waits until the pool's first connection for creds has n sessions claimed
+func waitForSessions(t *testing.T, p *Pool, creds SSHCredentials, n int) {
+	t.Helper()
+	key := poolKeyFor(creds)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		p.mu.Lock()
+		conns := p.conns[key]
+		got := len(conns) > 0 && conns[0].sessions == n
+		p.mu.Unlock()
+		if got {
+			return
+		}
+	}
+	t.Fatalf("pool never had %d sessions claimed", n)
+}
+
+var testCreds = SSHCredentials{Address: "web01:22", Username: "ops", Password: "secret"}
+
+func TestPoolDialFailureWithWaiters(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantDials int
+		wantErr   error // nil: the waiters redial and share one connection
+	}{
+		{"auth failure", classify(ErrAuthFailed, errors.New("unable to authenticate")), 1, ErrAuthFailed},
+		{"host key mismatch", fmt.Errorf("%w for web01", ErrHostKeyMismatch), 1, ErrHostKeyMismatch},
+		{"transient", classify(ErrHandshakeFailed, io.ErrUnexpectedEOF), 2, nil},
+	}
+	const waiters = 3
+	for _, tt := range tests {
+		p := &Pool{MaxSessions: waiters + 1}
+		d := newFakeDial(t, tt.err)
+
+		errs := make(chan error, waiters+1)
+		for range waiters + 1 {
+			go func() {
+				_, release, err := p.acquire(context.Background(), testCreds, d.dial)
+				if err == nil {
+					release()
+				}
+				errs <- err
+			}()
+		}
+		waitForSessions(t, p, testCreds, waiters+1)
+		close(d.release)
+
+		for range waiters + 1 {
+			err := <-errs
+			if tt.wantErr == nil && err != nil {
+				// The dialer reports its own transient failure; only waiters redial
+				if !errors.Is(err, ErrHandshakeFailed) {
+					t.Errorf("%s: acquire = %v", tt.name, err)
+				}
+			} else if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: acquire = %v, want %v", tt.name, err, tt.wantErr)
+			}
+		}
+		if got := d.dials(); got != tt.wantDials {
+			t.Errorf("%s: dialed %d times, want %d", tt.name, got, tt.wantDials)
+		}
+		p.Close()
+	}
+}
+
+func TestPoolWaiterCancellation(t *testing.T) {
+	p := &Pool{}
+	d := newFakeDial(t, nil)
+
+	dialed := make(chan func())
+	go func() {
+		_, release, err := p.acquire(context.Background(), testCreds, d.dial)
+		if err != nil {
+			t.Errorf("dialer: %v", err)
+		}
+		dialed <- release
+	}()
+	waitForSessions(t, p, testCreds, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan error)
+	go func() {
+		_, _, err := p.acquire(ctx, testCreds, d.dial)
+		waited <- err
+	}()
+	waitForSessions(t, p, testCreds, 2)
+	cancel()
+	if err := <-waited; !errors.Is(err, ErrCancelled) {
+		t.Errorf("cancelled waiter got %v, want ErrCancelled", err)
+	}
+	waitForSessions(t, p, testCreds, 1)
+
+	close(d.release)
+	release := <-dialed
+	release()
+	waitForSessions(t, p, testCreds, 0)
+	if got := d.dials(); got != 1 {
+		t.Errorf("dialed %d times, want 1", got)
+	}
+	p.Close()
+}
+
+func TestPoolRetiresOnCredentialChange(t *testing.T) {
+	p := &Pool{}
+	d := newFakeDial(t, nil)
+	close(d.release)
+
+	old, releaseOld, err := p.acquire(context.Background(), testCreds, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// New credentials that have not logged in yet leave the old connection in service
+	changed := testCreds
+	changed.Password = "rotated"
+	refused := func(context.Context, SSHCredentials) (*ssh.Client, error) {
+		return nil, classify(ErrDialFailed, errors.New("connection refused"))
+	}
+	if _, _, err := p.acquire(context.Background(), changed, refused); !errors.Is(err, ErrDialFailed) {
+		t.Fatalf("acquire with a failing dial = %v", err)
+	}
+	if same, r, err := p.acquire(context.Background(), testCreds, d.dial); err != nil || same != old {
+		t.Fatalf("old connection retired by credentials that never logged in: %v", err)
+	} else {
+		r()
+	}
+
+	client, release, err := p.acquire(context.Background(), changed, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client == old {
+		t.Fatal("new credentials reused the connection logged in with the old ones")
+	}
+
+	// The old connection stays open for the job using it, but is not handed out again
+	again, releaseAgain, err := p.acquire(context.Background(), testCreds, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == old {
+		t.Error("retired connection handed out again")
+	}
+	select {
+	case <-d.gone[old]:
+		t.Fatal("retired connection closed while a job still used it")
+	default:
+	}
+	releaseAgain()
+
+	releaseOld()
+	if !d.closed(old) {
+		t.Error("retired connection left open after its last job released it")
+	}
+	release()
+	p.Close()
+}
+
+func TestPoolHangUpWaitsForOtherSessions(t *testing.T) {
+	p := &Pool{MaxSessions: 2}
+	d := newFakeDial(t, nil)
+	close(d.release)
+
+	stuck, releaseStuck, err := p.acquire(context.Background(), testCreds, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running, releaseRunning, err := p.acquire(context.Background(), testCreds, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running != stuck {
+		t.Fatal("second job did not share the connection")
+	}
+
+	if !p.hangUp(stuck) {
+		t.Fatal("hangUp did not find the pooled connection")
+	}
+	// The other job's session keeps running; later jobs get a connection of their own
+	next, releaseNext, err := p.acquire(context.Background(), testCreds, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == stuck {
+		t.Error("connection handed out again after a hang-up")
+	}
+	releaseNext()
+
+	releaseStuck()
+	select {
+	case <-d.gone[stuck]:
+		t.Fatal("hang-up closed the connection while another job still used it")
+	default:
+	}
+	if _, _, err := running.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+		t.Errorf("connection unusable for the job still running: %v", err)
+	}
+
+	releaseRunning()
+	if !d.closed(stuck) {
+		t.Error("hung-up connection left open after its last job released it")
+	}
+	if other, _ := pipeClient(t); p.hangUp(other) {
+		t.Error("hangUp claimed a connection the pool does not hold")
+	}
+	p.Close()
+}
+
+func TestPoolEvictsIdleConnections(t *testing.T) {
+	p := &Pool{IdleTimeout: 200 * time.Millisecond}
+	d := newFakeDial(t, nil)
+	close(d.release)
+
+	idle, release, err := p.acquire(context.Background(), testCreds, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	busyCreds := testCreds
+	busyCreds.Address = "web02:22"
+	busy, releaseBusy, err := p.acquire(context.Background(), busyCreds, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Not yet idle for IdleTimeout
+	p.sweep()
+	if client, r, err := p.acquire(context.Background(), testCreds, d.dial); err != nil || client != idle {
+		t.Fatalf("connection evicted before IdleTimeout: %v", err)
+	} else {
+		r()
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	p.sweep()
+	if !d.closed(idle) {
+		t.Error("connection idle past IdleTimeout left open")
+	}
+	select {
+	case <-d.gone[busy]:
+		t.Error("connection in use evicted")
+	default:
+	}
+	releaseBusy()
+
+	client, r, err := p.acquire(context.Background(), testCreds, d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client == idle {
+		t.Error("evicted connection handed out")
+	}
+	r()
+	p.Close()
+}
+
+func TestPoolConcurrentAcquire(t *testing.T) {
+	p := &Pool{MaxSessions: 2}
+	d := newFakeDial(t, nil)
+	close(d.release)
+
+	var mu sync.Mutex
+	inUse := make(map[*ssh.Client]int)
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				client, release, err := p.acquire(context.Background(), testCreds, d.dial)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				inUse[client]++
+				if inUse[client] > p.MaxSessions {
+					t.Errorf("connection shared by %d jobs, MaxSessions is %d", inUse[client], p.MaxSessions)
+				}
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				inUse[client]--
+				mu.Unlock()
+				release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	for _, conns := range p.conns {
+		for _, pc := range conns {
+			if pc.sessions != 0 {
+				t.Errorf("connection left with %d sessions claimed", pc.sessions)
+			}
+		}
+	}
+	p.mu.Unlock()
+	p.Close()
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+const (
+	defaultScriptDir = "/tmp"
+	// maxScriptBytes bounds script artifacts, which are held in memory so they can be verified before running
+	maxScriptBytes = 8 << 20
+)
+
+// shellInterpreters read a script from stdin with -s; other interpreters (python, perl, ruby) take "-"
+var shellInterpreters = map[string]bool{"sh": true, "bash": true, "dash": true, "ksh": true, "zsh": true, "ash": true}
+
+// prepareScript returns the command line for a script job and, for stdin delivery, the reader to
+// feed it. File delivery uploads the script first; cleanup removes it and must always be called
+func (e *SSHExecutor) prepareScript(ctx context.Context, job jobs.JobDefinition, client *ssh.Client) (string, io.Reader, func(), error) {
+	noop := func() {}
+	s := job.Script
+	body, err := e.scriptBody(ctx, job)
+	if err != nil {
+		return "", nil, noop, err
+	}
+
+	words := []string{jobs.ShellQuote(s.Interpreter)}
+	for _, arg := range s.InterpreterArgs {
+		words = append(words, jobs.ShellQuote(arg))
+	}
+	if s.DeliveryMode() == jobs.ScriptStdin {
+		if shellInterpreters[path.Base(s.Interpreter)] {
+			words = append(words, "-s", "--")
+		} else {
+			words = append(words, "-")
+		}
+		return joinArgs(words, job.Arguments), bytes.NewReader(body), noop, nil
+	}
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		return "", nil, noop, classify(ErrSessionFailed, fmt.Errorf("start sftp: %w", err))
+	}
+	dir := e.ScriptDir
+	if dir == "" {
+		dir = defaultScriptDir
+	}
+	name := path.Join(dir, ".orch-script-"+job.ID)
+	cleanup := func() {
+		sc.Remove(name)
+		sc.Close()
+	}
+
+	f, err := sc.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err == nil {
+		// Only the login user may read or run the script
+		if err = f.Chmod(0o700); err == nil {
+			_, err = f.Write(body)
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, noop, classify(ErrTransferFailed, fmt.Errorf("upload script to %s: %w", name, err))
+	}
+
+	return joinArgs(append(words, jobs.ShellQuote(name)), job.Arguments), nil, cleanup, nil
+}
+
+// scriptBody returns the inline script, or fetches the script artifact and verifies its digest
+func (e *SSHExecutor) scriptBody(ctx context.Context, job jobs.JobDefinition) ([]byte, error) {
+	s := job.Script
+	if s.Artifact == "" {
+		return []byte(s.Body), nil
+	}
+	if e.ArtifactSource == nil {
+		return nil, classify(ErrTransferFailed, errors.New("engine has no artifact source for scripts"))
+	}
+
+	src, _, err := e.ArtifactSource(ctx, job.PayloadJob(), s.Artifact)
+	if err != nil {
+		return nil, classify(ErrTransferFailed, fmt.Errorf("fetch script %s: %w", s.Artifact, err))
+	}
+	defer src.Close()
+
+	body, err := io.ReadAll(io.LimitReader(src, maxScriptBytes+1))
+	if err != nil {
+		return nil, classify(ErrTransferFailed, fmt.Errorf("fetch script %s: %w", s.Artifact, err))
+	}
+	if len(body) > maxScriptBytes {
+		return nil, classify(ErrValidation, fmt.Errorf("script %s exceeds %d bytes", s.Artifact, maxScriptBytes))
+	}
+	sum := sha256.Sum256(body)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, s.SHA256) {
+		return nil, fmt.Errorf("%w: script %s has sha256 %s, expected %s", ErrChecksumMismatch, s.Artifact, got, s.SHA256)
+	}
+
+	return body, nil
+}
+
+// joinArgs appends quoted arguments to already quoted words
+func joinArgs(words, args []string) string {
+	for _, arg := range args {
+		words = append(words, jobs.ShellQuote(arg))
+	}
+
+	return strings.Join(words, " ")
+}
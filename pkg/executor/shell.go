@@ -0,0 +1,183 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// DefaultShellIdleTimeout ends shells without operator input when the executor sets no limit
+const DefaultShellIdleTimeout = 15 * time.Minute
+
+// Shell relay message kinds, matching the WebSocket opcodes the relay uses
+const (
+	shellControl = 1 // text: a jobs.ShellMessage
+	shellData    = 2 // binary: terminal bytes
+)
+
+// ShellConn is the engine's end of a shell relay: binary messages carry terminal data and text
+// messages carry jobs.ShellMessage control messages
+type ShellConn interface {
+	ReadMessage() (int, []byte, error)
+	WriteMessage(op int, data []byte) error
+	Close() error
+}
+
+// shell runs the login shell of a shell job on a PTY and relays it to the operator until the
+// shell exits, the operator leaves, the shell sits idle or ctx ends
+func (e *SSHExecutor) shell(ctx context.Context, job jobs.JobDefinition, client *ssh.Client, started time.Time) (jobs.Result, error) {
+	if e.ShellAttach == nil {
+		err := classify(ErrSessionFailed, errors.New("engine has no shell relay"))
+		return e.buildResult(job, started, "", "", err), err
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		err = classify(ErrSessionFailed, fmt.Errorf("start session: %w", err))
+		return e.buildResult(job, started, "", "", err), err
+	}
+	defer session.Close()
+
+	ptySettings, err := requestPty(session, job.Terminal)
+	if err != nil {
+		err = classify(ErrSessionFailed, fmt.Errorf("request pty: %w", err))
+		return e.buildResult(job, started, "", "", err), err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		err = classify(ErrSessionFailed, fmt.Errorf("stdin pipe: %w", err))
+		return e.buildResult(job, started, "", "", err), err
+	}
+
+	conn, err := e.ShellAttach(ctx, job.ID)
+	if err != nil {
+		err = classify(ErrSessionFailed, fmt.Errorf("attach shell relay: %w", err))
+		return e.buildResult(job, started, "", "", err), err
+	}
+	defer conn.Close()
+
+	session.Stdout = shellWriter{conn}
+	session.Stderr = shellWriter{conn}
+	if err := session.Shell(); err != nil {
+		err = classify(ErrSessionFailed, fmt.Errorf("start shell: %w", err))
+		return e.shellResult(job, started, conn, ptySettings, err), err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+
+	var lastInput atomic.Int64
+	lastInput.Store(time.Now().UnixNano())
+	detached := make(chan error, 1)
+	go func() {
+		detached <- relayInput(conn, session, stdin, &lastInput)
+	}()
+
+	idle := e.shellIdleTimeout(job)
+	ticker := time.NewTicker(min(idle, time.Second))
+	defer ticker.Stop()
+
+	var runErr error
+wait:
+	for {
+		select {
+		case <-ctx.Done():
+			runErr = contextError(ctx.Err(), e.terminate(session, stdin, done, func() { e.hangUp(client) }))
+			break wait
+		case err := <-detached:
+			// Hang up on the shell the way a dropped SSH connection would
+			session.Close()
+			<-done
+			runErr = classify(ErrCancelled, err)
+			break wait
+		case <-ticker.C:
+			if time.Since(time.Unix(0, lastInput.Load())) >= idle {
+				session.Close()
+				<-done
+				runErr = classify(ErrTimeout, fmt.Errorf("shell idle for %s", idle))
+				break wait
+			}
+		case err := <-done:
+			runErr = runError(err)
+			break wait
+		}
+	}
+
+	result := e.shellResult(job, started, conn, ptySettings, runErr)
+	result.CoreDumped = coreDumped(client, session)
+
+	return result, runErr
+}
+
+// relayInput copies operator keystrokes to the shell and applies resizes until the relay ends,
+// returning why it ended
+func relayInput(conn ShellConn, session *ssh.Session, stdin io.Writer, lastInput *atomic.Int64) error {
+	for {
+		op, data, err := conn.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			return errors.New("operator disconnected")
+		} else if err != nil {
+			return fmt.Errorf("shell relay: %w", err)
+		}
+		lastInput.Store(time.Now().UnixNano())
+
+		switch op {
+		case shellData:
+			stdin.Write(data)
+		case shellControl:
+			var msg jobs.ShellMessage
+			if json.Unmarshal(data, &msg) != nil {
+				continue
+			}
+			if msg.Type == jobs.ShellError {
+				return errors.New(msg.Error)
+			}
+			if msg.Type == jobs.ShellResize && msg.Cols > 0 && msg.Cols <= jobs.MaxTerminalSize && msg.Rows > 0 && msg.Rows <= jobs.MaxTerminalSize {
+				session.WindowChange(msg.Rows, msg.Cols)
+			}
+		}
+	}
+}
+
+// shellResult builds the result and tells the operator how the shell ended
+func (e *SSHExecutor) shellResult(job jobs.JobDefinition, started time.Time, conn ShellConn, ptySettings map[string]string, runErr error) jobs.Result {
+	result := e.buildResult(job, started, "", "", runErr)
+	result.Metadata = withMetadata(result.Metadata, ptySettings)
+
+	msg, _ := json.Marshal(jobs.ShellMessage{Type: jobs.ShellExit, ExitCode: result.ExitCode, Error: result.Error})
+	conn.WriteMessage(shellControl, msg)
+
+	return result
+}
+
+// shellIdleTimeout applies the job's idle timeout, which may only shorten the executor's
+func (e *SSHExecutor) shellIdleTimeout(job jobs.JobDefinition) time.Duration {
+	idle := e.ShellIdleTimeout
+	if idle <= 0 {
+		idle = DefaultShellIdleTimeout
+	}
+	if job.IdleTimeoutSeconds > 0 {
+		idle = min(idle, time.Duration(job.IdleTimeoutSeconds)*time.Second)
+	}
+
+	return idle
+}
+
+// shellWriter sends terminal output to the operator. Write errors are dropped so a vanished
+// operator cannot stall the session; relayInput notices them leaving
+type shellWriter struct {
+	conn ShellConn
+}
+
+func (w shellWriter) Write(p []byte) (int, error) {
+	w.conn.WriteMessage(shellData, p)
+	return len(p), nil
+}
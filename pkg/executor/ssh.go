@@ -7,7 +7,11 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"net"
+	"slices"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -26,8 +30,38 @@ type SSHCredentials struct {
 type SSHExecutor struct {
 	AllowedCommands map[string]struct{}
 	DialTimeout     time.Duration
+	// OutputSink, when set, receives output as it arrives so it can be streamed live
+	OutputSink func(jobID, stream string, p []byte)
+	// MaxOutputBytes caps each captured stream held in memory and returned inline (default 1 MiB).
+	// Jobs may lower it; output past the cap keeps only its head and tail
+	MaxOutputBytes int64
+	// ArtifactSink stores full output for jobs with UploadOutput set; SpoolDir holds it meanwhile
+	ArtifactSink func(ctx context.Context, jobID, name string, r io.Reader) (jobs.Artifact, error)
+	SpoolDir     string
+	// KillGrace is how long a timed-out command gets after SIGTERM before SIGKILL, and after
+	// SIGKILL before the channel is torn down (default 5s)
+	KillGrace time.Duration
+	// ArtifactSource fetches upload payloads from the controller; AllowedPaths limits the remote
+	// paths transfer jobs may touch
+	ArtifactSource func(ctx context.Context, jobID, name string) (io.ReadCloser, jobs.Artifact, error)
+	AllowedPaths   []string
+	// BecomeUsers lists the accounts jobs may become through sudo or su
+	BecomeUsers []string
+	// ScriptDir is the remote directory file-delivered scripts are written to (default /tmp)
+	ScriptDir string
+	// ShellAttach connects a shell job to its operator through the controller. Shell jobs are
+	// refused unless AllowShell is set, and end after ShellIdleTimeout without operator input (default 15m)
+	ShellAttach      func(ctx context.Context, jobID string) (ShellConn, error)
+	AllowShell       bool
+	ShellIdleTimeout time.Duration
+	// AllowExpect permits jobs with expect steps, which type into the command's terminal
+	AllowExpect bool
+	// Pool, when set, shares SSH connections between jobs; without it every job dials its own
+	Pool *Pool
 }
 
+const defaultKillGrace = 5 * time.Second
+
 // Execute runs the job remotely and return stdout/sterr/exit code
 func (e *SSHExecutor) Execute(ctx context.Context, job jobs.JobDefinition, creds SSHCredentials) (jobs.Result, error) {
 	started := time.Now().UTC()
@@ -36,68 +70,342 @@ func (e *SSHExecutor) Execute(ctx context.Context, job jobs.JobDefinition, creds
 		return e.buildResult(job, started, "", "", err), err
 	}
 
-	client, err := e.newClient(ctx, creds)
+	client, release, err := e.connect(ctx, creds)
 	if err != nil {
 		// return jobs.Result{}, err
 		return e.buildResult(job, started, "", "", err), err
 	}
-	defer client.Close()
+	defer release()
+
+	if job.Kind() == jobs.JobUpload || job.Kind() == jobs.JobDownload {
+		return e.transfer(ctx, job, client, started)
+	}
+	if job.Kind() == jobs.JobShell {
+		return e.shell(ctx, job, client, started)
+	}
+
+	command := job.CommandLine()
+	var stdin io.Reader
+	if job.Kind() == jobs.JobScript {
+		var cleanup func()
+		command, stdin, cleanup, err = e.prepareScript(ctx, job, client)
+		defer cleanup()
+		if err != nil {
+			return e.buildResult(job, started, "", "", err), err
+		}
+	}
 
 	session, err := client.NewSession()
 	if err != nil {
 		// return jobs.Result{}, fmt.Errorf("start session: %w", err)
-		err = fmt.Errorf("start session: %w", err)
+		err = classify(ErrSessionFailed, fmt.Errorf("start session: %w", err))
 		return e.buildResult(job, started, "", "", err), err
 	}
 	defer session.Close()
 
+	input, err := e.openStdin(ctx, job)
+	if err != nil {
+		return e.buildResult(job, started, "", "", err), err
+	} else if input != nil {
+		defer input.Close()
+		stdin = input
+	}
+	envApplier := applyEnv
+	if job.Become != nil {
+		envApplier = applyBecomeEnv
+	}
+	prefix, err := envApplier(session, job)
+	if err != nil {
+		return e.buildResult(job, started, "", "", err), err
+	}
+	command = prefix + command
+	if job.WorkingDir != "" {
+		command = "cd " + jobs.ShellQuote(job.WorkingDir) + " && " + command
+	}
+	var become *becomeWatcher
+	if job.Become != nil {
+		command, become = wrapBecome(job.Become, command, creds.Password, slices.Sorted(slices.Values(job.SecretEnv)))
+	}
+
 	// Connect stdout/stderr to capture buffers for auditing
-	var stdoutBuf, stderrBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-	session.Stderr = &stderrBuf
+	out, err := e.newJobOutput(job)
+	if err != nil {
+		err = classify(ErrSessionFailed, err)
+		return e.buildResult(job, started, "", "", err), err
+	}
+	session.Stdout = e.capture(job.ID, "stdout", out.writer("stdout"))
+	session.Stderr = e.capture(job.ID, "stderr", out.writer("stderr"))
+	// Expect steps read the raw terminal output; what is kept has their secrets masked
+	var expect *expecter
+	var masked *maskWriter
+	if len(job.Expect) > 0 {
+		expect = newExpecter(job)
+		masked = &maskWriter{next: session.Stdout, secrets: expect.secrets}
+		session.Stdout = io.MultiWriter(expect, masked)
+	}
+	// sudo -S prompts on stderr; with a PTY everything arrives on stdout
+	if become != nil && job.AllowTTY {
+		become.next, session.Stdout = session.Stdout, become
+	} else if become != nil {
+		become.next, session.Stderr = session.Stderr, become
+	}
 
 	// Allocate PTY only when explicitly allowed
+	var ptyIn io.Writer
+	var ptySettings map[string]string
 	if job.AllowTTY {
-		if err := session.RequestPty("xterm", 80, 24, ssh.TerminalModes{}); err != nil {
-			return jobs.Result{}, fmt.Errorf("request pty: %w", err)
+		if ptySettings, err = requestPty(session, job.Terminal); err != nil {
+			err = classify(ErrSessionFailed, fmt.Errorf("request pty: %w", err))
+			return e.finish(ctx, job, started, out, err), err
+		}
+		// Keep the terminal's input side so a timeout can fall back to typing ^C into it
+		if ptyIn, err = session.StdinPipe(); err != nil {
+			err = classify(ErrSessionFailed, fmt.Errorf("stdin pipe: %w", err))
+			return e.finish(ctx, job, started, out, err), err
 		}
 	}
 
-	// start := time.Now().UTC()
-	runCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	// Feed stdin through a pipe rather than session.Stdin, which fails the whole run with EOF
+	// when the command exits without reading all of its input
+	var stdinPipe io.WriteCloser
+	if stdin != nil || (become != nil && ptyIn == nil) {
+		if stdinPipe, err = session.StdinPipe(); err != nil {
+			err = classify(ErrSessionFailed, fmt.Errorf("stdin pipe: %w", err))
+			return e.finish(ctx, job, started, out, err), err
+		}
+	}
+	var becomeFailed <-chan error
+	if become != nil {
+		// The password is typed wherever sudo or su reads it; job input waits until the command started
+		become.answer = stdinPipe
+		if ptyIn != nil {
+			become.answer = ptyIn
+		}
+		becomeFailed = become.failCh
+	}
+	var expectFailed <-chan error
+	if expect != nil {
+		expectFailed = expect.failCh
+	}
 
 	done := make(chan error, 1)
+	exited := make(chan struct{})
+	relayed := make(chan struct{}) // closed once nothing feeds stdin any more
+	if expect != nil {
+		go expect.run(ctx, ptyIn, exited)
+	}
 	go func() {
-		done <- session.Run(job.Command)
+		defer close(exited)
+		if err := session.Start(command); err != nil {
+			close(relayed)
+			done <- err
+			return
+		}
+		if stdinPipe == nil {
+			close(relayed)
+		} else {
+			go func() {
+				defer close(relayed)
+				defer stdinPipe.Close()
+				if become != nil {
+					select {
+					case <-become.readyCh:
+					case <-exited:
+						return
+					}
+				}
+				if stdin != nil {
+					io.Copy(stdinPipe, stdin)
+				}
+			}()
+		}
+		done <- session.Wait()
 	}()
 
+	var runErr error
+	var hungUp bool
 	select {
-	case <-runCtx.Done():
-		// return jobs.Result{}, runCtx.Err()
-		err := runCtx.Err()
-		return e.buildResult(job, started, stdoutBuf.String(), stderrBuf.String(), err), err
+	case <-ctx.Done():
+		// Stop the remote process and wait for the session to unwind before reading the buffers
+		runErr = contextError(ctx.Err(), e.terminate(session, ptyIn, done, func() {
+			hungUp = true
+			e.hangUp(client)
+		}))
+	case err := <-becomeFailed:
+		// The command never started, so there is nothing to stop gracefully
+		session.Close()
+		<-done
+		runErr = err
+	case err := <-expectFailed:
+		// The command is stuck at output nobody will answer, so hang up on it
+		session.Close()
+		<-done
+		runErr = err
 	case err := <-done:
-		// return e.buildResult(job, start, stdoutBuf.String(), stderrBuf.String(), err), nil
-		return e.buildResult(job, started, stdoutBuf.String(), stderrBuf.String(), err), err
+		runErr = runError(err)
+		if become != nil {
+			runErr = become.result(runErr)
+		}
+		if expect != nil {
+			runErr = expect.result(runErr)
+		}
+	}
+	// The channel is closed by now, so writes fail; closing the source ends a read still waiting on it.
+	// A session that was hung up on may still block a write until the pool closes its connection
+	if input != nil {
+		input.Close()
+	}
+	if !hungUp {
+		<-relayed
 	}
+
+	if masked != nil {
+		masked.flush()
+	}
+	result := e.finish(ctx, job, started, out, runErr)
+	result.CoreDumped = coreDumped(client, session)
+	if expect != nil {
+		result.Transcript = expect.entries()
+	}
+	if ptySettings != nil {
+		result.Metadata = withMetadata(result.Metadata, ptySettings)
+	}
+
+	return result, runErr
+}
+
+// finish builds the result from captured output and uploads full output artifacts when requested.
+// Output of a run cut short by ctx is still uploaded; the sink's idle deadline bounds the upload
+func (e *SSHExecutor) finish(ctx context.Context, job jobs.JobDefinition, started time.Time, out *jobOutput, runErr error) jobs.Result {
+	result := e.buildResult(job, started, "", "", runErr)
+	out.fill(&result)
+	if out.spools != nil {
+		out.upload(context.WithoutCancel(ctx), job.ID, e.ArtifactSink, &result)
+	}
+
+	return result
+}
+
+// terminate stops a command whose context ended: SIGTERM first (plus ^C through the PTY for servers
+// that ignore signal requests), SIGKILL after the grace period, and finally closing the channel.
+// If the session still has not returned, hangUp gives up on the connection and terminate returns
+// without waiting: a pooled connection may carry other jobs' sessions, so it only closes once they
+// are done. It returns session.Run's error, or nil if the session had to be hung up on
+func (e *SSHExecutor) terminate(session *ssh.Session, ptyIn io.Writer, done <-chan error, hangUp func()) error {
+	grace := e.KillGrace
+	if grace <= 0 {
+		grace = defaultKillGrace
+	}
+
+	// Keys typed into a stalled channel only return once it closes; wait for them before returning
+	var typing sync.WaitGroup
+	defer typing.Wait()
+
+	steps := []struct {
+		signal ssh.Signal
+		key    byte
+	}{
+		{ssh.SIGTERM, 0x03}, // ^C raises SIGINT in the remote terminal
+		{ssh.SIGKILL, 0x1c}, // ^\ raises SIGQUIT, the strongest signal a terminal can send
+	}
+	for _, step := range steps {
+		session.Signal(step.signal)
+		if ptyIn != nil {
+			// A stalled channel must not block the escalation
+			typing.Add(1)
+			go func() {
+				defer typing.Done()
+				ptyIn.Write([]byte{step.key})
+			}()
+		}
+
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(grace):
+		}
+	}
+
+	// Closing the channel makes the server hang up on the command
+	session.Close()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(grace):
+	}
+
+	// The server never acknowledged the close; the session unwinds once the connection closes
+	hangUp()
+	return nil
+}
+
+// hangUp gives up on the connection of a session that would not end. A pooled connection is
+// closed once the jobs still using it are done, any other one right away
+func (e *SSHExecutor) hangUp(client *ssh.Client) {
+	if e.Pool == nil || !e.Pool.hangUp(client) {
+		client.Close()
+	}
+}
+
+// capture tees a stream into its buffer and the live output sink when one is configured
+func (e *SSHExecutor) capture(jobID, stream string, buf io.Writer) io.Writer {
+	if e.OutputSink == nil {
+		return buf
+	}
+
+	return io.MultiWriter(buf, sinkWriter{jobID: jobID, stream: stream, sink: e.OutputSink})
+}
+
+type sinkWriter struct {
+	jobID  string
+	stream string
+	sink   func(jobID, stream string, p []byte)
+}
+
+func (w sinkWriter) Write(p []byte) (int, error) {
+	// Writers may reuse p after returning, so hand the sink its own copy
+	w.sink(w.jobID, w.stream, bytes.Clone(p))
+	return len(p), nil
 }
 
 func (e *SSHExecutor) validateJob(job jobs.JobDefinition) error {
 	if err := job.Validate(); err != nil {
-		return err
+		return classify(ErrValidation, err)
+	}
+
+	if job.Transfer != nil {
+		if !e.pathAllowed(job.Transfer.RemotePath) {
+			return classify(ErrPolicyDenied, fmt.Errorf("%s of %s not allowed", job.Kind(), job.Transfer.RemotePath))
+		}
+	} else if job.Kind() == jobs.JobShell {
+		if !e.AllowShell {
+			return classify(ErrPolicyDenied, errors.New("interactive shells not allowed"))
+		}
+	} else if len(e.AllowedCommands) > 0 {
+		// The allowlist applies to the command sudo or su runs, and scripts are checked by their interpreter
+		if _, ok := e.AllowedCommands[job.Program()]; !ok {
+			return classify(ErrPolicyDenied, fmt.Errorf("command %s not allowed", job.Program()))
+		}
 	}
 
-	if len(e.AllowedCommands) > 0 {
-		if _, ok := e.AllowedCommands[job.Command]; !ok {
-			return fmt.Errorf("command %s not allowed", job.Command)
+	for _, name := range slices.Sorted(maps.Keys(job.Env)) {
+		if envDenied(name) {
+			return classify(ErrPolicyDenied, fmt.Errorf("env %s not allowed", name))
 		}
 	}
 
+	if len(job.Expect) > 0 && !e.AllowExpect {
+		return classify(ErrPolicyDenied, errors.New("expect steps not allowed"))
+	}
+
+	if job.Become != nil && !e.becomeAllowed(job.Become.BecomeUser()) {
+		return classify(ErrPolicyDenied, fmt.Errorf("become %s not allowed", job.Become.BecomeUser()))
+	}
+
 	// Recompute checksum locally fo integrity
-	sum := sha256.Sum256([]byte(job.Command))
+	sum := sha256.Sum256([]byte(job.ChecksumInput()))
 	if hex.EncodeToString(sum[:]) != job.Checksum {
-		return errors.New("checksum mismatch")
+		return ErrChecksumMismatch
 	}
 
 	return nil
@@ -105,10 +413,10 @@ func (e *SSHExecutor) validateJob(job jobs.JobDefinition) error {
 
 func (e *SSHExecutor) newClient(ctx context.Context, creds SSHCredentials) (*ssh.Client, error) {
 	if creds.Address == "" || creds.Username == "" {
-		return nil, errors.New("missing SSH address or username")
+		return nil, classify(ErrValidation, errors.New("missing SSH address or username"))
 	}
 	if creds.Password == "" {
-		return nil, errors.New("missing password")
+		return nil, classify(ErrValidation, errors.New("missing password"))
 	}
 
 	config := &ssh.ClientConfig{
@@ -122,15 +430,18 @@ func (e *SSHExecutor) newClient(ctx context.Context, creds SSHCredentials) (*ssh
 	dialer := &net.Dialer{Timeout: e.DialTimeout}
 	conn, err := dialer.DialContext(ctx, "tcp", creds.Address)
 	if err != nil {
-		return nil, fmt.Errorf("dial %s: %w", creds.Address, err)
+		if ctx.Err() != nil {
+			return nil, contextError(ctx.Err(), nil)
+		}
+		return nil, classify(ErrDialFailed, fmt.Errorf("dial %s: %w", creds.Address, err))
 	}
 
 	c, chans, reqs, err := ssh.NewClientConn(conn, creds.Address, config)
 	if err != nil {
-		return nil, fmt.Errorf("handshake: %w", err)
+		return nil, handshakeError(fmt.Errorf("handshake: %w", err))
 	}
 
-	return ssh.NewClient(c, chans, reqs), nil
+	return ssh.NewClient(signalConn{c}, chans, reqs), nil
 }
 
 func (e *SSHExecutor) makeHostKeyCallback(expected string) ssh.HostKeyCallback {
@@ -141,41 +452,54 @@ func (e *SSHExecutor) makeHostKeyCallback(expected string) ssh.HostKeyCallback {
 	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
 		fingerprint := ssh.FingerprintSHA256(key)
 		if fingerprint != expected {
-			return fmt.Errorf("host key mismatch for %s: got %s want %s", hostname, fingerprint, expected)
+			return fmt.Errorf("%w for %s: got %s want %s", ErrHostKeyMismatch, hostname, fingerprint, expected)
 		}
 		return nil
 	}
 }
 
 func (e *SSHExecutor) buildResult(job jobs.JobDefinition, started time.Time, stdout, stderr string, runErr error) jobs.Result {
-	exitCode := 0
-	if runErr != nil {
-		if exitErr, ok := runErr.(*ssh.ExitError); ok {
-			exitCode = exitErr.ExitStatus()
-		} else {
-			exitCode = -1
-		}
-	}
-
-	return jobs.Result{
+	result := jobs.Result{
 		JobID:      job.ID,
 		Status:     e.statusFromError(runErr),
 		StartedAt:  started,
 		FinishedAt: time.Now().UTC(),
-		ExitCode:   exitCode,
 		Stdout:     stdout,
 		Stderr:     stderr,
 		Error:      errorString(runErr),
+		ErrorClass: Classify(runErr),
 		Metadata:   job.Metadata,
 	}
+
+	// x/crypto/ssh folds a signal death into ExitStatus as 128+n but keeps the name and message apart.
+	// ssh.Session drops the core-dump flag; callers holding the session fill it in with coreDumped
+	var exitErr *ssh.ExitError
+	var missingErr *ssh.ExitMissingError
+	switch {
+	case runErr == nil:
+	case errors.As(runErr, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+		result.ExitSignal = exitErr.Signal()
+		result.SignalMessage = exitErr.Msg()
+	case errors.As(runErr, &missingErr):
+		result.ExitCode = -1
+		result.ExitStatusMissing = true
+	default:
+		result.ExitCode = -1
+	}
+
+	return result
 }
 
 func (e *SSHExecutor) statusFromError(err error) jobs.Status {
-	if err != nil {
+	switch {
+	case err == nil:
+		return jobs.StatusSucceeded
+	case errors.Is(err, ErrTimeout):
+		return jobs.StatusTimedOut
+	default:
 		return jobs.StatusFailed
 	}
-
-	return jobs.StatusSucceeded
 }
 
 func errorString(err error) string {
@@ -0,0 +1,258 @@
+package executor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// stallConn stops delivering the client's messages once stalled, as a hung server would, while
+// still noticing when the client closes the connection
+type stallConn struct {
+	net.Conn
+	stalled atomic.Bool
+}
+
+func (c *stallConn) Read(p []byte) (int, error) {
+	for {
+		n, err := c.Conn.Read(p)
+		if err != nil || !c.stalled.Load() {
+			return n, err
+		}
+	}
+}
+
+// testServer is a loopback SSH server accepting any password that hands each exec request to exec
+type testServer struct {
+	addr string
+	gone chan struct{} // closed once the client's connection is closed
+	exec func(conn *stallConn, ch ssh.Channel, reqs <-chan *ssh.Request)
+}
+
+func newTestServer(t *testing.T, exec func(conn *stallConn, ch ssh.Channel, reqs <-chan *ssh.Request)) *testServer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, nil },
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	srv := &testServer{addr: ln.Addr().String(), gone: make(chan struct{}), exec: exec}
+	go func() {
+		raw, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { raw.Close() })
+		defer close(srv.gone)
+
+		conn := &stallConn{Conn: raw}
+		server, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			for newCh := range chans {
+				ch, chReqs, err := newCh.Accept()
+				if err != nil {
+					continue
+				}
+				go srv.session(conn, ch, chReqs)
+			}
+		}()
+		server.Wait()
+	}()
+
+	return srv
+}
+
+// session answers the requests that come before exec, then leaves the channel to exec
+func (srv *testServer) session(conn *stallConn, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		srv.exec(conn, ch, reqs)
+		return
+	}
+}
+
+// commandJob returns a valid job running command, with its checksum filled in
+func commandJob(command string) jobs.JobDefinition {
+	job := jobs.JobDefinition{
+		ID:          "j",
+		TargetHost:  "web01",
+		TargetUser:  "ops",
+		Command:     command,
+		Credentials: jobs.CredentialBundle{Username: "ops", Password: "secret"},
+	}
+	sum := sha256.Sum256([]byte(job.ChecksumInput()))
+	job.Checksum = hex.EncodeToString(sum[:])
+
+	return job
+}
+
+func TestExecuteTerminateEscalation(t *testing.T) {
+	const grace = 100 * time.Millisecond
+	tests := []struct {
+		name  string
+		stall bool // the server stops reading once it got SIGKILL, so it never acknowledges the close
+	}{
+		{"channel close acknowledged", false},
+		{"connection hung up", true},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var signals []string
+		srv := newTestServer(t, func(conn *stallConn, ch ssh.Channel, reqs <-chan *ssh.Request) {
+			// The command ignores every signal and never exits
+			for req := range reqs {
+				if req.Type == "signal" {
+					var msg struct{ Signal string }
+					ssh.Unmarshal(req.Payload, &msg)
+					mu.Lock()
+					signals = append(signals, msg.Signal)
+					mu.Unlock()
+					if msg.Signal == string(ssh.SIGKILL) && tt.stall {
+						conn.stalled.Store(true)
+					}
+				}
+				if req.WantReply {
+					req.Reply(false, nil)
+				}
+			}
+		})
+
+		pool := &Pool{}
+		e := &SSHExecutor{KillGrace: grace, Pool: pool}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		returned := make(chan struct{})
+		var result jobs.Result
+		var err error
+		go func() {
+			defer close(returned)
+			result, err = e.Execute(ctx, commandJob("sleep 600"), SSHCredentials{Address: srv.addr, Username: "ops", Password: "secret"})
+		}()
+		select {
+		case <-returned:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: Execute still running after the escalation", tt.name)
+		}
+		cancel()
+
+		if !errors.Is(err, ErrTimeout) || result.Status != jobs.StatusTimedOut || result.ErrorClass != jobs.ErrorTimeout {
+			t.Errorf("%s: Execute = %s (%s), %v; want timed_out", tt.name, result.Status, result.ErrorClass, err)
+		}
+		mu.Lock()
+		if len(signals) != 2 || signals[0] != string(ssh.SIGTERM) || signals[1] != string(ssh.SIGKILL) {
+			t.Errorf("%s: server got signals %v, want TERM then KILL", tt.name, signals)
+		}
+		mu.Unlock()
+
+		// Only a server that never acknowledged the close gets its connection dropped; the
+		// pool keeps the connection otherwise
+		select {
+		case <-srv.gone:
+			if !tt.stall {
+				t.Errorf("%s: connection closed although the channel close was acknowledged", tt.name)
+			}
+		case <-time.After(time.Second):
+			if tt.stall {
+				t.Errorf("%s: connection left open after the hang-up", tt.name)
+			}
+		}
+		pool.Close()
+	}
+}
+
+func TestExecuteExitDetails(t *testing.T) {
+	type exitStatusMsg struct{ Status uint32 }
+	tests := []struct {
+		name    string
+		request string // sent before the channel closes; empty sends none
+		payload any
+		want    jobs.Result
+		wantErr error
+	}{
+		{
+			name:    "exit status",
+			request: "exit-status",
+			payload: exitStatusMsg{3},
+			want:    jobs.Result{ExitCode: 3},
+			wantErr: ErrRemoteExitNonZero,
+		},
+		{
+			name:    "signal with core dump",
+			request: "exit-signal",
+			payload: exitSignalMsg{Signal: "SEGV", CoreDumped: true, Error: "Segmentation fault"},
+			want:    jobs.Result{ExitCode: 139, ExitSignal: "SEGV", SignalMessage: "Segmentation fault", CoreDumped: true},
+			wantErr: ErrRemoteSignal,
+		},
+		{
+			name:    "signal without core dump",
+			request: "exit-signal",
+			payload: exitSignalMsg{Signal: "KILL"},
+			want:    jobs.Result{ExitCode: 137, ExitSignal: "KILL"},
+			wantErr: ErrRemoteSignal,
+		},
+		{
+			name:    "nothing reported",
+			want:    jobs.Result{ExitCode: -1, ExitStatusMissing: true},
+			wantErr: ErrSessionFailed,
+		},
+	}
+	for _, tt := range tests {
+		srv := newTestServer(t, func(_ *stallConn, ch ssh.Channel, reqs <-chan *ssh.Request) {
+			go ssh.DiscardRequests(reqs)
+			if tt.request != "" {
+				ch.SendRequest(tt.request, false, ssh.Marshal(tt.payload))
+			}
+			ch.Close()
+		})
+
+		e := &SSHExecutor{}
+		result, err := e.Execute(context.Background(), commandJob("/usr/bin/crash"), SSHCredentials{Address: srv.addr, Username: "ops", Password: "secret"})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Execute error %v, want %v", tt.name, err, tt.wantErr)
+		}
+		got := jobs.Result{
+			ExitCode:          result.ExitCode,
+			ExitSignal:        result.ExitSignal,
+			SignalMessage:     result.SignalMessage,
+			CoreDumped:        result.CoreDumped,
+			ExitStatusMissing: result.ExitStatusMissing,
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: exit details %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"maps"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// requestPty asks for the job's pseudo-terminal and returns the settings used, as result metadata
+func requestPty(session *ssh.Session, t *jobs.TerminalSpec) (map[string]string, error) {
+	term, cols, rows := t.Settings()
+	modes := ssh.TerminalModes{}
+	if t != nil {
+		for name, value := range t.Modes {
+			modes[jobs.TerminalModeOpcodes[name]] = value
+		}
+	}
+	if err := session.RequestPty(term, rows, cols, modes); err != nil {
+		return nil, err
+	}
+
+	settings := map[string]string{
+		"pty_term": term,
+		"pty_cols": strconv.Itoa(cols),
+		"pty_rows": strconv.Itoa(rows),
+	}
+	if m := t.ModeString(); m != "" {
+		settings["pty_modes"] = m
+	}
+
+	return settings, nil
+}
+
+// withMetadata returns metadata with extra added, leaving the job's own map untouched
+func withMetadata(metadata, extra map[string]string) map[string]string {
+	merged := make(map[string]string, len(metadata)+len(extra))
+	maps.Copy(merged, metadata)
+	maps.Copy(merged, extra)
+
+	return merged
+}
@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// transfer runs an upload or download job over an SFTP subsystem on the job's SSH client
+func (e *SSHExecutor) transfer(ctx context.Context, job jobs.JobDefinition, client *ssh.Client, started time.Time) (jobs.Result, error) {
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		err = classify(ErrSessionFailed, fmt.Errorf("start sftp: %w", err))
+		return e.buildResult(job, started, "", "", err), err
+	}
+	defer sc.Close()
+
+	// SFTP calls take no context, so closing the client is what interrupts a stalled transfer
+	stop := context.AfterFunc(ctx, func() { sc.Close() })
+	defer stop()
+
+	var summary string
+	var artifact *jobs.Artifact
+	switch job.Kind() {
+	case jobs.JobUpload:
+		summary, err = e.upload(ctx, sc, job)
+	case jobs.JobDownload:
+		artifact, summary, err = e.download(ctx, sc, job)
+	}
+	if err != nil && ctx.Err() != nil {
+		err = contextError(ctx.Err(), err)
+	}
+
+	result := e.buildResult(job, started, summary, "", err)
+	result.StdoutBytes = int64(len(summary))
+	if artifact != nil {
+		result.Artifacts = append(result.Artifacts, *artifact)
+	}
+
+	return result, err
+}
+
+// upload streams the job's payload artifact into a temporary file beside the destination,
+// verifies it, applies mode and owner, then renames it into place so readers never see a partial file
+func (e *SSHExecutor) upload(ctx context.Context, sc *sftp.Client, job jobs.JobDefinition) (string, error) {
+	t := job.Transfer
+	if e.ArtifactSource == nil {
+		return "", classify(ErrTransferFailed, errors.New("engine has no artifact source for uploads"))
+	}
+	src, _, err := e.ArtifactSource(ctx, job.PayloadJob(), t.ArtifactName())
+	if err != nil {
+		return "", classify(ErrTransferFailed, fmt.Errorf("fetch artifact %s: %w", t.ArtifactName(), err))
+	}
+	defer src.Close()
+
+	dir, base := path.Split(t.RemotePath)
+	tmp := path.Join(dir, "."+base+"."+job.ID+".tmp")
+	f, err := sc.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", classify(ErrTransferFailed, fmt.Errorf("create %s: %w", tmp, err))
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			sc.Remove(tmp)
+		}
+	}()
+
+	// Restrict the file before any data lands in it: the requested mode, or owner-only
+	mode := os.FileMode(0o600)
+	if m, ok, _ := t.FileMode(); ok {
+		mode = m
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return "", classify(ErrTransferFailed, fmt.Errorf("chmod %s: %w", tmp, err))
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(f, io.TeeReader(src, hash))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", classify(ErrTransferFailed, fmt.Errorf("write %s: %w", tmp, err))
+	}
+
+	sent := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(sent, t.SHA256) {
+		return "", fmt.Errorf("%w: payload sha256 %s, expected %s", ErrChecksumMismatch, sent, t.SHA256)
+	}
+	// Read the file back so the digest covers what actually landed on the target
+	written, err := remoteSHA256(sc, tmp)
+	if err != nil {
+		return "", classify(ErrTransferFailed, err)
+	}
+	if written != sent {
+		return "", fmt.Errorf("%w: %s has sha256 %s after upload, sent %s", ErrChecksumMismatch, tmp, written, sent)
+	}
+
+	if uid, gid, ok, _ := t.IDs(); ok {
+		if gid < 0 {
+			info, err := sc.Stat(tmp)
+			if err != nil {
+				return "", classify(ErrTransferFailed, fmt.Errorf("stat %s: %w", tmp, err))
+			}
+			if st, ok := info.Sys().(*sftp.FileStat); ok {
+				gid = int(st.GID)
+			}
+		}
+		if err := sc.Chown(tmp, uid, gid); err != nil {
+			return "", classify(ErrTransferFailed, fmt.Errorf("chown %s: %w", tmp, err))
+		}
+	}
+
+	// Plain SFTP rename refuses to replace an existing file; the OpenSSH extension swaps it atomically
+	rename := sc.Rename
+	if _, ok := sc.HasExtension("posix-rename@openssh.com"); ok {
+		rename = sc.PosixRename
+	}
+	if err := rename(tmp, t.RemotePath); err != nil {
+		return "", classify(ErrTransferFailed, fmt.Errorf("rename %s to %s: %w", tmp, t.RemotePath, err))
+	}
+	committed = true
+
+	return fmt.Sprintf("uploaded %d bytes to %s (sha256 %s)\n", n, t.RemotePath, sent), nil
+}
+
+// download streams a remote file into a job artifact, hashing it on the way
+func (e *SSHExecutor) download(ctx context.Context, sc *sftp.Client, job jobs.JobDefinition) (*jobs.Artifact, string, error) {
+	t := job.Transfer
+	if e.ArtifactSink == nil {
+		return nil, "", classify(ErrTransferFailed, errors.New("engine has no artifact sink for downloads"))
+	}
+	f, err := sc.Open(t.RemotePath)
+	if err != nil {
+		return nil, "", classify(ErrTransferFailed, fmt.Errorf("open %s: %w", t.RemotePath, err))
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	artifact, err := e.ArtifactSink(ctx, job.ID, t.ArtifactName(), io.TeeReader(f, hash))
+	if err != nil {
+		return nil, "", classify(ErrTransferFailed, fmt.Errorf("store artifact %s: %w", t.ArtifactName(), err))
+	}
+
+	got := hex.EncodeToString(hash.Sum(nil))
+	if artifact.SHA256 != "" && artifact.SHA256 != got {
+		return &artifact, "", fmt.Errorf("%w: controller stored sha256 %s, read %s", ErrChecksumMismatch, artifact.SHA256, got)
+	}
+	if t.SHA256 != "" && !strings.EqualFold(got, t.SHA256) {
+		return &artifact, "", fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrChecksumMismatch, t.RemotePath, got, t.SHA256)
+	}
+
+	return &artifact, fmt.Sprintf("downloaded %d bytes from %s to artifact %s (sha256 %s)\n",
+		artifact.Size, t.RemotePath, artifact.Name, got), nil
+}
+
+func remoteSHA256(sc *sftp.Client, name string) (string, error) {
+	f, err := sc.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("reopen %s: %w", name, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("read back %s: %w", name, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// pathAllowed reports whether a transfer may touch remotePath. Without AllowedPaths, transfers
+// are only permitted on engines that do not restrict commands either
+func (e *SSHExecutor) pathAllowed(remotePath string) bool {
+	if len(e.AllowedPaths) == 0 {
+		return len(e.AllowedCommands) == 0
+	}
+	for _, prefix := range e.AllowedPaths {
+		prefix = strings.TrimSuffix(path.Clean(prefix), "/")
+		if remotePath == prefix || strings.HasPrefix(remotePath, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
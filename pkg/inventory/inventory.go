@@ -0,0 +1,338 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+// Host is a managed machine and the defaults used to reach it
+type Host struct {
+	Name    string `yaml:"name" json:"name"`
+	Address string `yaml:"address" json:"address"`
+	Port    int    `yaml:"port" json:"port,omitempty"`
+	User    string `yaml:"user" json:"user,omitempty"`
+	// HostKey is the pinned SHA256 fingerprint (ssh.FingerprintSHA256 format)
+	HostKey string            `yaml:"host_key" json:"host_key,omitempty"`
+	Labels  map[string]string `yaml:"labels" json:"labels,omitempty"`
+	Vars    map[string]string `yaml:"vars" json:"vars,omitempty"`
+}
+
+// Group collects hosts and other groups; variables apply to every member
+type Group struct {
+	Name     string            `yaml:"name" json:"name"`
+	Hosts    []string          `yaml:"hosts" json:"hosts,omitempty"`
+	Children []string          `yaml:"children" json:"children,omitempty"`
+	Vars     map[string]string `yaml:"vars" json:"vars,omitempty"`
+}
+
+// Snapshot is the serialisable form of an inventory
+type Snapshot struct {
+	Hosts  []Host  `yaml:"hosts" json:"hosts"`
+	Groups []Group `yaml:"groups" json:"groups"`
+}
+
+// Inventory stores hosts and groups in memory and resolves job targets against them
+type Inventory struct {
+	mu     sync.RWMutex
+	hosts  map[string]Host
+	groups map[string]Group
+}
+
+// New returns an empty inventory
+func New() *Inventory {
+	return &Inventory{
+		hosts:  make(map[string]Host),
+		groups: make(map[string]Group),
+	}
+}
+
+func (h Host) Validate() error {
+	if strings.TrimSpace(h.Name) == "" {
+		return errors.New("host name cannot be empty")
+	}
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("host %s port %d out of range", h.Name, h.Port)
+	}
+
+	return nil
+}
+
+func (g Group) Validate() error {
+	if strings.TrimSpace(g.Name) == "" {
+		return errors.New("group name cannot be empty")
+	}
+	for _, child := range g.Children {
+		if child == g.Name {
+			return fmt.Errorf("group %s cannot contain itself", g.Name)
+		}
+	}
+
+	return nil
+}
+
+// PutHost creates or replaces a host
+func (inv *Inventory) PutHost(h Host) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.hosts[h.Name] = h
+
+	return nil
+}
+
+// PutGroup creates or replaces a group; members may be defined later
+func (inv *Inventory) PutGroup(g Group) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.groups[g.Name] = g
+
+	return nil
+}
+
+// DeleteHost removes a host; group memberships naming it are ignored on resolution
+func (inv *Inventory) DeleteHost(name string) bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if _, ok := inv.hosts[name]; !ok {
+		return false
+	}
+	delete(inv.hosts, name)
+
+	return true
+}
+
+// DeleteGroup removes a group
+func (inv *Inventory) DeleteGroup(name string) bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if _, ok := inv.groups[name]; !ok {
+		return false
+	}
+	delete(inv.groups, name)
+
+	return true
+}
+
+// Host returns a host by name
+func (inv *Inventory) Host(name string) (Host, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	h, ok := inv.hosts[name]
+	return h, ok
+}
+
+// Group returns a group by name
+func (inv *Inventory) Group(name string) (Group, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	g, ok := inv.groups[name]
+	return g, ok
+}
+
+// Snapshot returns every host and group sorted by name
+func (inv *Inventory) Snapshot() Snapshot {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	snap := Snapshot{
+		Hosts:  make([]Host, 0, len(inv.hosts)),
+		Groups: make([]Group, 0, len(inv.groups)),
+	}
+	for _, h := range inv.hosts {
+		snap.Hosts = append(snap.Hosts, h)
+	}
+	for _, g := range inv.groups {
+		snap.Groups = append(snap.Groups, g)
+	}
+	sort.Slice(snap.Hosts, func(i, j int) bool { return snap.Hosts[i].Name < snap.Hosts[j].Name })
+	sort.Slice(snap.Groups, func(i, j int) bool { return snap.Groups[i].Name < snap.Groups[j].Name })
+
+	return snap
+}
+
+// Merge adds every host and group from snap, replacing existing entries with the same name
+func (inv *Inventory) Merge(snap Snapshot) error {
+	for _, h := range snap.Hosts {
+		if err := inv.PutHost(h); err != nil {
+			return err
+		}
+	}
+	for _, g := range snap.Groups {
+		if err := inv.PutGroup(g); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GroupHosts expands a group, including nested child groups, into unique host names.
+// Hosts keep the order they are listed in; unknown names and cycles are reported
+func (inv *Inventory) GroupHosts(name string) ([]string, error) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	if _, ok := inv.groups[name]; !ok {
+		return nil, fmt.Errorf("inventory group %s not found", name)
+	}
+
+	var (
+		out     []string
+		seen    = make(map[string]struct{})
+		visited = make(map[string]bool) // true while on the current path
+	)
+
+	var walk func(group string) error
+	walk = func(group string) error {
+		if onPath, done := visited[group]; done {
+			if onPath {
+				return fmt.Errorf("inventory group %s is nested in itself", group)
+			}
+			return nil
+		}
+		g, ok := inv.groups[group]
+		if !ok {
+			return fmt.Errorf("inventory group %s not found", group)
+		}
+
+		visited[group] = true
+		for _, host := range g.Hosts {
+			if _, ok := inv.hosts[host]; !ok {
+				return fmt.Errorf("inventory group %s references unknown host %s", group, host)
+			}
+			if _, dup := seen[host]; dup {
+				continue
+			}
+			seen[host] = struct{}{}
+			out = append(out, host)
+		}
+		for _, child := range g.Children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		visited[group] = false
+
+		return nil
+	}
+
+	if err := walk(name); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// HostVars merges variables of every group containing the host (directly or through nesting),
+// with the host's own variables taking precedence
+func (inv *Inventory) HostVars(name string) map[string]string {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	h, ok := inv.hosts[name]
+	if !ok {
+		return nil
+	}
+
+	vars := make(map[string]string)
+	names := make([]string, 0, len(inv.groups))
+	for gname := range inv.groups {
+		names = append(names, gname)
+	}
+	sort.Strings(names) // deterministic precedence between sibling groups
+
+	for _, gname := range names {
+		if inv.groupContains(gname, name, make(map[string]struct{})) {
+			for k, v := range inv.groups[gname].Vars {
+				vars[k] = v
+			}
+		}
+	}
+	for k, v := range h.Vars {
+		vars[k] = v
+	}
+
+	return vars
+}
+
+// groupContains reports whether host is a member of group or any group nested in it; caller holds the lock
+func (inv *Inventory) groupContains(group, host string, seen map[string]struct{}) bool {
+	if _, dup := seen[group]; dup {
+		return false
+	}
+	seen[group] = struct{}{}
+
+	g := inv.groups[group]
+	for _, member := range g.Hosts {
+		if member == host {
+			return true
+		}
+	}
+	for _, child := range g.Children {
+		if inv.groupContains(child, host, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveJob rewrites a job whose target_host names an inventory host into concrete connection details.
+// Fields already set on the job win over inventory defaults; unknown names pass through unchanged.
+// The endpoint is resolved before the host key, and a job pinning a different key than the inventory is refused
+func (inv *Inventory) ResolveJob(job jobs.JobDefinition) (jobs.JobDefinition, error) {
+	h, ok := inv.Host(job.TargetHost)
+	if !ok {
+		return job, nil
+	}
+
+	job.Metadata = copyMap(job.Metadata)
+	job.Metadata["inventory_host"] = h.Name
+
+	if h.Address != "" {
+		job.TargetHost = h.Address
+	}
+	if job.TargetPort == 0 {
+		job.TargetPort = h.Port
+	}
+	if job.TargetUser == "" {
+		job.TargetUser = h.User
+	}
+
+	switch {
+	case h.HostKey == "":
+	case job.HostKeyFingerprint == "":
+		job.HostKeyFingerprint = h.HostKey
+	case job.HostKeyFingerprint != h.HostKey:
+		return job, fmt.Errorf("host key %s does not match %s pinned for inventory host %s", job.HostKeyFingerprint, h.HostKey, h.Name)
+	}
+
+	return job, nil
+}
+
+func copyMap(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in)+1)
+	for k, v := range in {
+		out[k] = v
+	}
+
+	return out
+}
@@ -0,0 +1,74 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
+)
+
+func testInventory(t *testing.T) *Inventory {
+	t.Helper()
+
+	inv := New()
+	err := inv.Merge(Snapshot{
+		Hosts: []Host{
+			{Name: "web01", Address: "10.0.0.11", Port: 2222, User: "ops", HostKey: "SHA256:web01", Vars: map[string]string{"env": "canary"}},
+			{Name: "web02", Address: "10.0.0.12"},
+		},
+		Groups: []Group{
+			{Name: "all", Children: []string{"web"}, Vars: map[string]string{"env": "prod", "dc": "east"}},
+			{Name: "web", Hosts: []string{"web01", "web02"}, Vars: map[string]string{"http_port": "80"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	return inv
+}
+
+func TestHostVars(t *testing.T) {
+	inv := testInventory(t)
+
+	tests := []struct {
+		host string
+		want map[string]string
+	}{
+		{"web01", map[string]string{"env": "canary", "dc": "east", "http_port": "80"}},
+		{"web02", map[string]string{"env": "prod", "dc": "east", "http_port": "80"}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		if got := inv.HostVars(tt.host); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("HostVars(%s) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestResolveJob(t *testing.T) {
+	inv := testInventory(t)
+
+	job, err := inv.ResolveJob(jobs.JobDefinition{ID: "j", TargetHost: "web01"})
+	if err != nil {
+		t.Fatalf("ResolveJob: %v", err)
+	}
+	if job.TargetHost != "10.0.0.11" || job.TargetPort != 2222 || job.TargetUser != "ops" ||
+		job.HostKeyFingerprint != "SHA256:web01" || job.Metadata["inventory_host"] != "web01" {
+		t.Errorf("ResolveJob = %+v", job)
+	}
+
+	// Fields set on the job win, except a host key that contradicts the inventory
+	job, err = inv.ResolveJob(jobs.JobDefinition{ID: "j", TargetHost: "web01", TargetPort: 22, TargetUser: "root"})
+	if err != nil || job.TargetPort != 22 || job.TargetUser != "root" {
+		t.Errorf("ResolveJob with overrides = %+v, %v", job, err)
+	}
+	if _, err := inv.ResolveJob(jobs.JobDefinition{ID: "j", TargetHost: "web01", HostKeyFingerprint: "SHA256:other"}); err == nil {
+		t.Error("ResolveJob accepted a host key the inventory does not pin")
+	}
+
+	job, err = inv.ResolveJob(jobs.JobDefinition{ID: "j", TargetHost: "db.example.com"})
+	if err != nil || job.TargetHost != "db.example.com" || job.Metadata != nil {
+		t.Errorf("ResolveJob of an unknown host = %+v, %v", job, err)
+	}
+}
@@ -0,0 +1,207 @@
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileFormat mirrors the YAML inventory layout where hosts and groups are keyed by name
+type fileFormat struct {
+	Hosts  map[string]Host  `yaml:"hosts"`
+	Groups map[string]Group `yaml:"groups"`
+}
+
+// LoadFile parses a YAML (.yaml/.yml) or INI inventory file
+func LoadFile(path string) (Snapshot, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("read inventory %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	default:
+		return ParseINI(data)
+	}
+}
+
+// ParseYAML reads the keyed YAML layout:
+//
+//	hosts:
+//	  web01: {address: 10.0.0.11, user: ops, host_key: "SHA256:..."}
+//	groups:
+//	  web: {hosts: [web01], children: [canary], vars: {http_port: "80"}}
+func ParseYAML(data []byte) (Snapshot, error) {
+	var f fileFormat
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return Snapshot{}, fmt.Errorf("parse inventory yaml: %w", err)
+	}
+
+	var snap Snapshot
+	for name, h := range f.Hosts {
+		h.Name = name
+		snap.Hosts = append(snap.Hosts, h)
+	}
+	for name, g := range f.Groups {
+		g.Name = name
+		snap.Groups = append(snap.Groups, g)
+	}
+	sortSnapshot(&snap)
+
+	return snap, nil
+}
+
+// ParseINI reads an Ansible-style inventory:
+//
+//	web01 address=10.0.0.11 port=22 user=ops host_key=SHA256:... label.role=web env=prod
+//	[web]
+//	web01
+//	[prod:children]
+//	web
+//	[web:vars]
+//	http_port=80
+//
+// Host lines accept address/port/user/host_key (or the ansible_* spellings), label.NAME for labels,
+// and treat any other key as a variable. A host may appear in several sections; its attributes merge
+func ParseINI(data []byte) (Snapshot, error) {
+	hosts := make(map[string]*Host)
+	groups := make(map[string]*Group)
+
+	group := func(name string) *Group {
+		g, ok := groups[name]
+		if !ok {
+			g = &Group{Name: name}
+			groups[name] = g
+		}
+		return g
+	}
+
+	section, kind := "", ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return Snapshot{}, fmt.Errorf("line %d: unterminated section header", lineNo)
+			}
+			section, kind, _ = strings.Cut(strings.TrimSpace(line[1:len(line)-1]), ":")
+			switch kind {
+			case "", "children", "vars":
+			default:
+				return Snapshot{}, fmt.Errorf("line %d: unknown section suffix %q", lineNo, kind)
+			}
+			group(section)
+			continue
+		}
+
+		switch kind {
+		case "children":
+			g := group(section)
+			g.Children = append(g.Children, line)
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return Snapshot{}, fmt.Errorf("line %d: expected key=value", lineNo)
+			}
+			g := group(section)
+			if g.Vars == nil {
+				g.Vars = make(map[string]string)
+			}
+			g.Vars[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+		default:
+			fields := strings.Fields(line)
+			name := fields[0]
+			h, ok := hosts[name]
+			if !ok {
+				h = &Host{Name: name}
+				hosts[name] = h
+			}
+			if err := applyHostAttrs(h, fields[1:]); err != nil {
+				return Snapshot{}, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if section != "" {
+				g := group(section)
+				g.Hosts = append(g.Hosts, name)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Snapshot{}, fmt.Errorf("parse inventory ini: %w", err)
+	}
+
+	var snap Snapshot
+	for _, h := range hosts {
+		snap.Hosts = append(snap.Hosts, *h)
+	}
+	for _, g := range groups {
+		snap.Groups = append(snap.Groups, *g)
+	}
+	sortSnapshot(&snap)
+
+	return snap, nil
+}
+
+func applyHostAttrs(h *Host, attrs []string) error {
+	for _, attr := range attrs {
+		key, value, ok := strings.Cut(attr, "=")
+		if !ok {
+			return fmt.Errorf("host %s: expected key=value, got %q", h.Name, attr)
+		}
+		value = unquote(value)
+
+		switch key {
+		case "address", "ansible_host":
+			h.Address = value
+		case "port", "ansible_port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("host %s: invalid port %q", h.Name, value)
+			}
+			h.Port = port
+		case "user", "ansible_user":
+			h.User = value
+		case "host_key":
+			h.HostKey = value
+		default:
+			if label, isLabel := strings.CutPrefix(key, "label."); isLabel {
+				if h.Labels == nil {
+					h.Labels = make(map[string]string)
+				}
+				h.Labels[label] = value
+				continue
+			}
+			if h.Vars == nil {
+				h.Vars = make(map[string]string)
+			}
+			h.Vars[key] = value
+		}
+	}
+
+	return nil
+}
+
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' && v[len(v)-1] == '"' || v[0] == '\'' && v[len(v)-1] == '\'') {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
+
+func sortSnapshot(snap *Snapshot) {
+	sort.Slice(snap.Hosts, func(i, j int) bool { return snap.Hosts[i].Name < snap.Hosts[j].Name })
+	sort.Slice(snap.Groups, func(i, j int) bool { return snap.Groups[i].Name < snap.Groups[j].Name })
+}
@@ -0,0 +1,69 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseINI(t *testing.T) {
+	snap, err := ParseINI([]byte(`
+# comment
+web01 address=10.0.0.11 port=2222 user=ops host_key=SHA256:abc label.role=web env=prod
+db01 ansible_host=10.0.0.21 ansible_user='dba' ansible_port=22
+
+[web]
+web01
+web02 address="10.0.0.12"
+
+[db]
+db01
+
+[prod:children]
+web
+db
+
+[web:vars]
+; comment
+http_port = 80
+`))
+	if err != nil {
+		t.Fatalf("ParseINI: %v", err)
+	}
+
+	want := Snapshot{
+		Hosts: []Host{
+			{Name: "db01", Address: "10.0.0.21", Port: 22, User: "dba"},
+			{Name: "web01", Address: "10.0.0.11", Port: 2222, User: "ops", HostKey: "SHA256:abc",
+				Labels: map[string]string{"role": "web"}, Vars: map[string]string{"env": "prod"}},
+			{Name: "web02", Address: "10.0.0.12"},
+		},
+		Groups: []Group{
+			{Name: "db", Hosts: []string{"db01"}},
+			{Name: "prod", Children: []string{"web", "db"}},
+			{Name: "web", Hosts: []string{"web01", "web02"}, Vars: map[string]string{"http_port": "80"}},
+		},
+	}
+	if !reflect.DeepEqual(snap, want) {
+		t.Errorf("ParseINI =\n%+v\nwant\n%+v", snap, want)
+	}
+}
+
+func TestParseINIErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"[web", "line 1: unterminated section header"},
+		{"[web:hosts]", `line 1: unknown section suffix "hosts"`},
+		{"[web:vars]\nhttp_port", "line 2: expected key=value"},
+		{"\nweb01 port=ssh", `line 2: host web01: invalid port "ssh"`},
+		{"web01 address", `line 1: host web01: expected key=value, got "address"`},
+	}
+	for _, tt := range tests {
+		_, err := ParseINI([]byte(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseINI(%q) error %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Artifact describes a file the controller stores alongside a job, such as full command output
+// or a file payload, so large content never travels inline in job or result JSON
+type Artifact struct {
+	Name   string `yaml:"name" json:"name"`
+	Size   int64  `yaml:"size" json:"size"`
+	SHA256 string `yaml:"sha256" json:"sha256"`
+	// ContentType is recorded from the upload, e.g. application/gzip for compressed output
+	ContentType string `yaml:"content_type,omitempty" json:"content_type,omitempty"`
+}
+
+var artifactNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// ValidateArtifactName rejects names that could escape the artifact directory or break URLs
+func ValidateArtifactName(name string) error {
+	if !artifactNameRe.MatchString(name) {
+		return fmt.Errorf("artifact name %q is invalid", name)
+	}
+
+	return nil
+}
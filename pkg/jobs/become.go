@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Become methods
+const (
+	// BecomeSudo runs the command through sudo -S, answering its prompt with the credential password
+	BecomeSudo = "sudo"
+	// BecomeSu runs the command through su, which reads the password from a terminal and so needs allow_tty
+	BecomeSu = "su"
+)
+
+// BecomeSpec switches to another user on the target before running the command. The password
+// sent to sudo or su is the job's credential password
+type BecomeSpec struct {
+	Method string `yaml:"method" json:"method"`
+	// User is the account to become (default root)
+	User string `yaml:"user,omitempty" json:"user,omitempty"`
+}
+
+// BecomeUser returns the account to become, defaulting to root
+func (b BecomeSpec) BecomeUser() string {
+	if b.User == "" {
+		return "root"
+	}
+
+	return b.User
+}
+
+func (j JobDefinition) validateBecome() error {
+	if j.WorkingDir != "" && (!path.IsAbs(j.WorkingDir) || strings.ContainsRune(j.WorkingDir, 0)) {
+		return fmt.Errorf("job %s working_dir %q must be an absolute path", j.ID, j.WorkingDir)
+	}
+	if (j.WorkingDir != "" || j.Become != nil) && (j.Kind() == JobUpload || j.Kind() == JobDownload) {
+		return fmt.Errorf("job %s %s jobs cannot set working_dir or become", j.ID, j.Kind())
+	}
+
+	b := j.Become
+	if b == nil {
+		return nil
+	}
+	if !validUnixName(b.BecomeUser()) {
+		return fmt.Errorf("job %s become user %q is invalid", j.ID, b.User)
+	}
+	switch b.Method {
+	case BecomeSudo:
+	case BecomeSu:
+		if !j.AllowTTY {
+			return fmt.Errorf("job %s become method su needs allow_tty", j.ID)
+		}
+	default:
+		return fmt.Errorf("job %s become method %q must be sudo or su", j.ID, b.Method)
+	}
+	// The private temporary script file is only readable by the login user and root
+	if j.Kind() == JobScript && j.Script != nil && j.Script.DeliveryMode() == ScriptFile && b.BecomeUser() != "root" {
+		return fmt.Errorf("job %s script delivery file can only become root", j.ID)
+	}
+
+	return nil
+}
+
+// validUnixName accepts portable user names, which never need shell quoting
+func validUnixName(name string) bool {
+	if name == "" || len(name) > 32 || name[0] == '-' {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("._-", c)) {
+			return false
+		}
+	}
+
+	return true
+}
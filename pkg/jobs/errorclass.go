@@ -0,0 +1,40 @@
+package jobs
+
+// ErrorClass is a machine-readable category for why a job did not succeed, so retries,
+// metrics and alerts can key off it instead of parsing Result.Error
+type ErrorClass string
+
+const (
+	// ErrorValidation marks jobs rejected before any connection was attempted
+	ErrorValidation ErrorClass = "validation"
+	// ErrorPolicyDenied marks commands outside the engine allowlist
+	ErrorPolicyDenied ErrorClass = "policy_denied"
+	// ErrorChecksumMismatch marks jobs whose checksum, or transferred file's digest, did not verify
+	ErrorChecksumMismatch ErrorClass = "checksum_mismatch"
+	// ErrorDialFailed covers DNS and TCP connection failures
+	ErrorDialFailed ErrorClass = "dial_failed"
+	// ErrorHandshakeFailed covers SSH protocol negotiation failures other than auth and host keys
+	ErrorHandshakeFailed ErrorClass = "handshake_failed"
+	// ErrorAuthFailed marks rejected credentials
+	ErrorAuthFailed ErrorClass = "auth_failed"
+	// ErrorHostKeyMismatch marks hosts whose key did not match the pinned fingerprint
+	ErrorHostKeyMismatch ErrorClass = "host_key_mismatch"
+	// ErrorSessionFailed covers failures opening or running the SSH session itself
+	ErrorSessionFailed ErrorClass = "session_failed"
+	// ErrorTimeout marks jobs stopped for exceeding their time limit
+	ErrorTimeout ErrorClass = "timeout"
+	// ErrorCancelled marks jobs withdrawn by a user
+	ErrorCancelled ErrorClass = "cancelled"
+	// ErrorRemoteExitNonZero marks commands that ran and exited with a non-zero status
+	ErrorRemoteExitNonZero ErrorClass = "remote_exit_nonzero"
+	// ErrorRemoteSignal marks commands terminated by a signal on the remote host
+	ErrorRemoteSignal ErrorClass = "remote_signal"
+	// ErrorTransferFailed covers SFTP and artifact failures while moving a file
+	ErrorTransferFailed ErrorClass = "transfer_failed"
+	// ErrorBecomeFailed marks jobs whose sudo or su step failed for a reason other than the password
+	ErrorBecomeFailed ErrorClass = "become_failed"
+	// ErrorBecomeAuthFailed marks jobs whose sudo or su step rejected the password
+	ErrorBecomeAuthFailed ErrorClass = "become_auth_failed"
+	// ErrorExpectFailed marks jobs whose expect step timed out or saw the command exit before its pattern
+	ErrorExpectFailed ErrorClass = "expect_failed"
+)
@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// SecretPassword is the send_secret name for the job's credential password, unless Secrets
+// defines a value of that name itself
+const SecretPassword = "password"
+
+// DefaultExpectTimeout is how long a step waits for its pattern when it sets no timeout
+const DefaultExpectTimeout = 30
+
+// ExpectStep waits for terminal output matching Expect and then types Send, or the secret named
+// by SendSecret, followed by Enter
+type ExpectStep struct {
+	// Expect is a regular expression matched against output received since the previous step
+	Expect     string `yaml:"expect" json:"expect"`
+	Send       string `yaml:"send,omitempty" json:"send,omitempty"`
+	SendSecret string `yaml:"send_secret,omitempty" json:"send_secret,omitempty"`
+	// NoEnter sends the response without the trailing carriage return, e.g. for single-key menus
+	NoEnter bool `yaml:"no_enter,omitempty" json:"no_enter,omitempty"`
+	// TimeoutSeconds bounds the wait for Expect (default DefaultExpectTimeout)
+	TimeoutSeconds int `yaml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"`
+}
+
+// Timeout returns the step's wait in seconds, applying the default
+func (s ExpectStep) Timeout() int {
+	if s.TimeoutSeconds <= 0 {
+		return DefaultExpectTimeout
+	}
+
+	return s.TimeoutSeconds
+}
+
+// TranscriptEntry records one expect step: the output that led up to the match and what was
+// typed in reply. Secrets are masked in both
+type TranscriptEntry struct {
+	Step   int    `yaml:"step" json:"step"`
+	Expect string `yaml:"expect" json:"expect"`
+	Output string `yaml:"output" json:"output"`
+	Sent   string `yaml:"sent,omitempty" json:"sent,omitempty"`
+	// Matched is false for the step that timed out or saw the command exit first
+	Matched   bool  `yaml:"matched" json:"matched"`
+	ElapsedMs int64 `yaml:"elapsed_ms" json:"elapsed_ms"`
+}
+
+// SecretValue returns the value a send_secret name refers to
+func (j JobDefinition) SecretValue(name string) (string, bool) {
+	if value, ok := j.Secrets[name]; ok {
+		return value, true
+	}
+	if name == SecretPassword {
+		return j.Credentials.Password, true
+	}
+
+	return "", false
+}
+
+func (j JobDefinition) validateExpect() error {
+	if len(j.Expect) == 0 {
+		return nil
+	}
+	if !j.AllowTTY {
+		return fmt.Errorf("job %s expect steps need allow_tty", j.ID)
+	}
+	if j.Kind() == JobUpload || j.Kind() == JobDownload {
+		return fmt.Errorf("job %s %s jobs cannot set expect steps", j.ID, j.Kind())
+	}
+
+	for i, step := range j.Expect {
+		if step.Expect == "" {
+			return fmt.Errorf("job %s expect step %d missing expect", j.ID, i+1)
+		}
+		if _, err := regexp.Compile(step.Expect); err != nil {
+			return fmt.Errorf("job %s expect step %d: %w", j.ID, i+1, err)
+		}
+		if step.Send != "" && step.SendSecret != "" {
+			return fmt.Errorf("job %s expect step %d sets both send and send_secret", j.ID, i+1)
+		}
+		if step.SendSecret != "" {
+			if _, ok := j.Secrets[step.SendSecret]; !ok && step.SendSecret != SecretPassword {
+				return fmt.Errorf("job %s expect step %d send_secret %q is not set in secrets", j.ID, i+1, step.SendSecret)
+			}
+		}
+		if step.TimeoutSeconds < 0 {
+			return fmt.Errorf("job %s expect step %d timeout_seconds cannot be negative", j.ID, i+1)
+		}
+	}
+
+	return nil
+}
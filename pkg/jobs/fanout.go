@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FanOutHalted marks a fan-out that stopped launching batches after too many failures
+const FanOutHalted WorkflowState = "halted"
+
+// FanOutDefinition expands one job template into per-host jobs executed in rolling batches
+type FanOutDefinition struct {
+	ID    string   `yaml:"id" json:"id"`
+	Hosts []string `yaml:"hosts" json:"hosts"`
+	// Group names an inventory group whose members are appended to Hosts by the controller
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+	// Template is copied per host with TargetHost replaced and an ID derived from the fan-out
+	Template JobDefinition `yaml:"template" json:"template"`
+	// BatchSize runs this many hosts at a time; BatchPercent sizes batches relative to the host count.
+	// When both are zero every host runs in a single batch
+	BatchSize    int `yaml:"batch_size" json:"batch_size,omitempty"`
+	BatchPercent int `yaml:"batch_percent" json:"batch_percent,omitempty"`
+	// MaxFailures is how many failed hosts are tolerated before remaining batches are halted (0 halts on the first)
+	MaxFailures int `yaml:"max_failures" json:"max_failures"`
+}
+
+// FanOutStatus aggregates per-host outcomes of a fan-out
+type FanOutStatus struct {
+	ID           string             `yaml:"id" json:"id"`
+	State        WorkflowState      `yaml:"state" json:"state"`
+	Batch        int                `yaml:"batch" json:"batch"`
+	TotalBatches int                `yaml:"total_batches" json:"total_batches"`
+	Succeeded    int                `yaml:"succeeded" json:"succeeded"`
+	Failed       int                `yaml:"failed" json:"failed"`
+	Hosts        []FanOutHostStatus `yaml:"hosts" json:"hosts"`
+}
+
+// FanOutHostStatus is the outcome for one host of a fan-out
+type FanOutHostStatus struct {
+	Host     string `yaml:"host" json:"host"`
+	JobID    string `yaml:"job_id" json:"job_id"`
+	Batch    int    `yaml:"batch" json:"batch"`
+	Status   Status `yaml:"status" json:"status"`
+	ExitCode int    `yaml:"exit_code" json:"exit_code"`
+	Error    string `yaml:"error" json:"error,omitempty"`
+}
+
+func (f FanOutDefinition) Validate() error {
+	if strings.TrimSpace(f.ID) == "" {
+		return errors.New("fan-out id cannot be empty")
+	}
+	if len(f.Hosts) == 0 {
+		return fmt.Errorf("fan-out %s has no hosts", f.ID)
+	}
+
+	seen := make(map[string]struct{}, len(f.Hosts))
+	for _, host := range f.Hosts {
+		if strings.TrimSpace(host) == "" {
+			return fmt.Errorf("fan-out %s contains an empty host", f.ID)
+		}
+		if _, dup := seen[host]; dup {
+			return fmt.Errorf("fan-out %s lists host %s twice", f.ID, host)
+		}
+		seen[host] = struct{}{}
+	}
+
+	if f.BatchSize < 0 || f.BatchPercent < 0 || f.BatchPercent > 100 {
+		return fmt.Errorf("fan-out %s batch size must be positive and batch_percent within 1-100", f.ID)
+	}
+	if f.BatchSize > 0 && f.BatchPercent > 0 {
+		return fmt.Errorf("fan-out %s sets both batch_size and batch_percent", f.ID)
+	}
+	if f.MaxFailures < 0 {
+		return fmt.Errorf("fan-out %s max_failures cannot be negative", f.ID)
+	}
+
+	// Each shell needs an operator attached, and only a single-host submit hands one the token
+	if f.Template.Kind() == JobShell {
+		return fmt.Errorf("fan-out %s: shell jobs must be submitted to one host at a time", f.ID)
+	}
+
+	tmpl := f.Template
+	tmpl.ID = f.ID + "-template"
+	tmpl.TargetHost = f.Hosts[0]
+	if err := tmpl.Validate(); err != nil {
+		return fmt.Errorf("fan-out %s template invalid: %w", f.ID, err)
+	}
+	if err := tmpl.ValidateSharedInputs(); err != nil {
+		return fmt.Errorf("fan-out %s template invalid: %w", f.ID, err)
+	}
+
+	return nil
+}
+
+// Batches splits the host list into rolling batches
+func (f FanOutDefinition) Batches() [][]string {
+	size := len(f.Hosts)
+	switch {
+	case f.BatchSize > 0:
+		size = f.BatchSize
+	case f.BatchPercent > 0:
+		size = (len(f.Hosts)*f.BatchPercent + 99) / 100
+	}
+	if size <= 0 {
+		size = 1
+	}
+
+	batches := make([][]string, 0, (len(f.Hosts)+size-1)/size)
+	for start := 0; start < len(f.Hosts); start += size {
+		end := min(start+size, len(f.Hosts))
+		batches = append(batches, f.Hosts[start:end])
+	}
+
+	return batches
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatches(t *testing.T) {
+	hosts := []string{"h1", "h2", "h3", "h4", "h5"}
+
+	tests := []struct {
+		name    string
+		size    int
+		percent int
+		want    [][]string
+	}{
+		{"single batch by default", 0, 0, [][]string{hosts}},
+		{"fixed size", 2, 0, [][]string{{"h1", "h2"}, {"h3", "h4"}, {"h5"}}},
+		{"size above host count", 10, 0, [][]string{hosts}},
+		{"percent rounds up", 0, 30, [][]string{{"h1", "h2"}, {"h3", "h4"}, {"h5"}}},
+		{"small percent keeps one host", 0, 1, [][]string{{"h1"}, {"h2"}, {"h3"}, {"h4"}, {"h5"}}},
+		{"full percent", 0, 100, [][]string{hosts}},
+	}
+	for _, tt := range tests {
+		got := FanOutDefinition{Hosts: hosts, BatchSize: tt.size, BatchPercent: tt.percent}.Batches()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: batches %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := (FanOutDefinition{BatchSize: 2}).Batches(); len(got) != 0 {
+		t.Errorf("batches without hosts = %v, want none", got)
+	}
+}
+
+func TestValidateSharedInputs(t *testing.T) {
+	const sum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	upload := JobDefinition{ID: "push", TargetUser: "u", Checksum: "x", Type: JobUpload,
+		Transfer:    &FileTransfer{RemotePath: "/etc/app.conf", Artifact: "app.conf", SHA256: sum},
+		Credentials: CredentialBundle{Username: "u", Password: "p"}}
+
+	def := FanOutDefinition{ID: "push", Hosts: []string{"web01", "web02"}, Template: upload}
+	if err := def.Validate(); err == nil || !strings.Contains(err.Error(), "artifact_job") {
+		t.Errorf("Validate accepted a fan-out upload reading its own artifacts: %v", err)
+	}
+
+	def.Template.Transfer = &FileTransfer{RemotePath: "/etc/app.conf", Artifact: "app.conf", ArtifactJob: "staged", SHA256: sum}
+	if err := def.Validate(); err != nil {
+		t.Errorf("Validate with artifact_job: %v", err)
+	}
+
+	def.Template.Stdin = &StdinSource{Artifact: "stdin"}
+	if err := def.Validate(); err == nil {
+		t.Error("Validate accepted a fan-out reading stdin from its own artifacts")
+	}
+}
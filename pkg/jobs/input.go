@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// RedactedValue replaces secret values in job records once they are no longer needed
+const RedactedValue = "[REDACTED]"
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// StdinSource feeds a command's standard input from inline text or a job artifact
+type StdinSource struct {
+	Data string `yaml:"data,omitempty" json:"data,omitempty"`
+	// Artifact names the job artifact streamed to stdin; ArtifactJob is the job it is stored
+	// under (empty means the job itself)
+	Artifact    string `yaml:"artifact,omitempty" json:"artifact,omitempty"`
+	ArtifactJob string `yaml:"artifact_job,omitempty" json:"artifact_job,omitempty"`
+}
+
+// ArtifactRef points at an artifact stored under a job
+type ArtifactRef struct {
+	Job  string
+	Name string
+}
+
+// Inputs lists the controller artifacts the engine reads to run the job
+func (j JobDefinition) Inputs() []ArtifactRef {
+	var refs []ArtifactRef
+	switch {
+	case j.Kind() == JobUpload && j.Transfer != nil:
+		refs = append(refs, ArtifactRef{Job: j.PayloadJob(), Name: j.Transfer.ArtifactName()})
+	case j.Kind() == JobScript && j.Script != nil && j.Script.Artifact != "":
+		refs = append(refs, ArtifactRef{Job: j.PayloadJob(), Name: j.Script.Artifact})
+	}
+	if j.Stdin != nil && j.Stdin.Artifact != "" {
+		refs = append(refs, ArtifactRef{Job: j.StdinJob(), Name: j.Stdin.Artifact})
+	}
+
+	return refs
+}
+
+// ValidateSharedInputs checks that a job run on many hosts names the job every input is stored
+// under with artifact_job: each host runs as a job of its own, <id>-<host>, without artifacts
+func (j JobDefinition) ValidateSharedInputs() error {
+	for _, ref := range j.Inputs() {
+		if ref.Job == j.ID {
+			return fmt.Errorf("job %s runs on many hosts, so its input %s needs artifact_job", j.ID, ref.Name)
+		}
+	}
+
+	return nil
+}
+
+// StdinJob returns the job ID the stdin artifact is stored under
+func (j JobDefinition) StdinJob() string {
+	if j.Stdin != nil && j.Stdin.ArtifactJob != "" {
+		return j.Stdin.ArtifactJob
+	}
+
+	return j.ID
+}
+
+// Redacted returns a copy of the job with the values of secret environment variables and expect
+// secrets replaced, for records kept after the job was handed to an engine and for API responses
+func (j JobDefinition) Redacted() JobDefinition {
+	if len(j.Secrets) > 0 {
+		secrets := make(map[string]string, len(j.Secrets))
+		for name := range j.Secrets {
+			secrets[name] = RedactedValue
+		}
+		j.Secrets = secrets
+	}
+	if len(j.SecretEnv) == 0 {
+		return j
+	}
+
+	env := make(map[string]string, len(j.Env))
+	for name, value := range j.Env {
+		if slices.Contains(j.SecretEnv, name) {
+			value = RedactedValue
+		}
+		env[name] = value
+	}
+	j.Env = env
+
+	return j
+}
+
+func (j JobDefinition) validateInput() error {
+	for name := range j.Env {
+		if !envNameRe.MatchString(name) {
+			return fmt.Errorf("job %s env name %q is invalid", j.ID, name)
+		}
+	}
+	for _, name := range j.SecretEnv {
+		if _, ok := j.Env[name]; !ok {
+			return fmt.Errorf("job %s secret_env %q is not set in env", j.ID, name)
+		}
+	}
+
+	s := j.Stdin
+	if s == nil {
+		return nil
+	}
+	switch {
+	case j.Kind() == JobUpload || j.Kind() == JobDownload:
+		return fmt.Errorf("job %s %s jobs cannot set stdin", j.ID, j.Kind())
+	case j.Kind() == JobScript && j.Script != nil && j.Script.DeliveryMode() == ScriptStdin:
+		return fmt.Errorf("job %s script delivery stdin already uses stdin; use file", j.ID)
+	case j.AllowTTY:
+		return fmt.Errorf("job %s stdin cannot be combined with allow_tty", j.ID)
+	case s.Data != "" && s.Artifact != "":
+		return fmt.Errorf("job %s stdin sets both data and artifact", j.ID)
+	case s.Artifact != "":
+		if err := ValidateArtifactName(s.Artifact); err != nil {
+			return fmt.Errorf("job %s stdin: %w", j.ID, err)
+		}
+		if s.ArtifactJob != "" && ValidateArtifactName(s.ArtifactJob) != nil {
+			return fmt.Errorf("job %s stdin artifact_job %q is not a valid job id", j.ID, s.ArtifactJob)
+		}
+	}
+
+	return nil
+}
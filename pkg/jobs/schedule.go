@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OverlapPolicy decides what a schedule does when its previous run is still active
+type OverlapPolicy string
+
+const (
+	// OverlapSkip drops the new tick while the previous run is pending or running
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue holds the new run until the previous one is final, then enqueues it. Only one
+	// run waits; further ticks meanwhile are skipped
+	OverlapQueue OverlapPolicy = "queue"
+	// OverlapReplace cancels the previous run, then enqueues the new one
+	OverlapReplace OverlapPolicy = "replace"
+)
+
+// ScheduleDefinition describes a recurring job spawned by the controller
+type ScheduleDefinition struct {
+	ID string `yaml:"id" json:"id"`
+	// Cron is a standard 5-field expression (minute hour dom month dow) or @hourly style macro
+	Cron string `yaml:"cron" json:"cron"`
+	// Timezone is an IANA name the expression is evaluated in; UTC when empty
+	Timezone string        `yaml:"timezone" json:"timezone"`
+	Overlap  OverlapPolicy `yaml:"overlap" json:"overlap"`
+	Paused   bool          `yaml:"paused" json:"paused"`
+	// Template is copied for every run; its ID is replaced with one derived from the schedule
+	Template JobDefinition `yaml:"template" json:"template"`
+}
+
+// ScheduleStatus reports a schedule together with its run bookkeeping
+type ScheduleStatus struct {
+	ScheduleDefinition
+	NextRun time.Time `yaml:"next_run" json:"next_run,omitzero"`
+	LastRun time.Time `yaml:"last_run" json:"last_run,omitzero"`
+	// History lists spawned job IDs, oldest first
+	History []string `yaml:"history" json:"history"`
+	// Queued is the run waiting for the previous one to finish, under the queue overlap policy
+	Queued string `yaml:"queued" json:"queued,omitempty"`
+}
+
+func (s ScheduleDefinition) Validate() error {
+	if strings.TrimSpace(s.ID) == "" {
+		return errors.New("schedule id cannot be empty")
+	}
+	if strings.TrimSpace(s.Cron) == "" {
+		return fmt.Errorf("schedule %s missing cron expression", s.ID)
+	}
+
+	switch s.Overlap {
+	case "", OverlapSkip, OverlapQueue, OverlapReplace:
+	default:
+		return fmt.Errorf("schedule %s has unknown overlap policy %q", s.ID, s.Overlap)
+	}
+
+	// A scheduled shell would wait for an operator nobody handed the token to
+	if s.Template.Kind() == JobShell {
+		return fmt.Errorf("schedule %s: shell jobs cannot be scheduled", s.ID)
+	}
+
+	// Validate the template as it will be spawned
+	tmpl := s.Template
+	tmpl.ID = s.ID + "-template"
+	if err := tmpl.Validate(); err != nil {
+		return fmt.Errorf("schedule %s template invalid: %w", s.ID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// JobScript runs a script shipped with the job through an interpreter on the target
+const JobScript JobType = "script"
+
+// Script delivery modes
+const (
+	// ScriptStdin pipes the script into the interpreter's standard input
+	ScriptStdin = "stdin"
+	// ScriptFile uploads the script to a temporary file, runs it and removes it afterwards
+	ScriptFile = "file"
+)
+
+// ScriptSpec describes the script of a script job. The body is either inline or a controller
+// artifact; either way the job checksum covers it
+type ScriptSpec struct {
+	// Interpreter is the absolute path of the program that runs the script; the engine allowlist applies to it
+	Interpreter string `yaml:"interpreter" json:"interpreter"`
+	// InterpreterArgs go between the interpreter and the script, such as the options of a #! line
+	InterpreterArgs []string `yaml:"interpreter_args,omitempty" json:"interpreter_args,omitempty"`
+	// Body is the script text when it is shipped inline
+	Body string `yaml:"body,omitempty" json:"body,omitempty"`
+	// Artifact names the job artifact holding the script, with SHA256 its required digest and
+	// ArtifactJob the job it is stored under (empty means the script job itself)
+	Artifact    string `yaml:"artifact,omitempty" json:"artifact,omitempty"`
+	ArtifactJob string `yaml:"artifact_job,omitempty" json:"artifact_job,omitempty"`
+	SHA256      string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+	// Delivery is "stdin" (default) or "file"; PTY jobs must use "file" since the terminal owns stdin
+	Delivery string `yaml:"delivery,omitempty" json:"delivery,omitempty"`
+}
+
+// DeliveryMode returns the delivery, defaulting to stdin
+func (s ScriptSpec) DeliveryMode() string {
+	if s.Delivery == "" {
+		return ScriptStdin
+	}
+
+	return s.Delivery
+}
+
+// Program returns what the engine allowlist is checked against: the interpreter for scripts, and
+// the full command line, arguments included, for commands
+func (j JobDefinition) Program() string {
+	if j.Kind() == JobScript && j.Script != nil {
+		return j.Script.Interpreter
+	}
+
+	return j.CommandLine()
+}
+
+func (s ScriptSpec) checksumInput() string {
+	body := s.Body
+	if s.Artifact != "" {
+		// The digest pins the artifact's content, so covering it covers the script body
+		body = "artifact:" + s.ArtifactJob + "/" + s.Artifact + "@" + strings.ToLower(s.SHA256)
+	}
+
+	return strings.Join([]string{string(JobScript), s.Interpreter, s.DeliveryMode(), body}, "\n")
+}
+
+func (j JobDefinition) validateScript() error {
+	s := j.Script
+	if s == nil {
+		return fmt.Errorf("job %s missing script", j.ID)
+	}
+	if j.Command != "" || j.Transfer != nil {
+		return fmt.Errorf("job %s script jobs cannot set command or transfer", j.ID)
+	}
+	if !path.IsAbs(s.Interpreter) {
+		return fmt.Errorf("job %s script interpreter %q must be an absolute path", j.ID, s.Interpreter)
+	}
+	for _, arg := range s.InterpreterArgs {
+		if strings.ContainsRune(arg, 0) {
+			return fmt.Errorf("job %s script interpreter_args contain a NUL byte", j.ID)
+		}
+	}
+	if (s.Body == "") == (s.Artifact == "") {
+		return fmt.Errorf("job %s script needs exactly one of body or artifact", j.ID)
+	}
+	if s.Artifact != "" {
+		if err := ValidateArtifactName(s.Artifact); err != nil {
+			return fmt.Errorf("job %s script: %w", j.ID, err)
+		}
+		if s.ArtifactJob != "" && ValidateArtifactName(s.ArtifactJob) != nil {
+			return fmt.Errorf("job %s script artifact_job %q is not a valid job id", j.ID, s.ArtifactJob)
+		}
+		if !isHexDigest(s.SHA256) {
+			return fmt.Errorf("job %s script artifact needs a sha256 of 64 hex characters", j.ID)
+		}
+	} else if s.ArtifactJob != "" || s.SHA256 != "" {
+		return fmt.Errorf("job %s inline scripts cannot set artifact_job or sha256", j.ID)
+	}
+	switch s.DeliveryMode() {
+	case ScriptStdin:
+		if j.AllowTTY {
+			return fmt.Errorf("job %s script delivery stdin cannot be combined with allow_tty; use file", j.ID)
+		}
+	case ScriptFile:
+	default:
+		return fmt.Errorf("job %s script delivery %q must be stdin or file", j.ID, s.Delivery)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package jobs
+
+import "fmt"
+
+// JobShell opens an interactive login shell on the target, relayed to an operator through the controller
+const JobShell JobType = "shell"
+
+// ShellRecording names the asciicast artifact the controller stores for every shell job
+const ShellRecording = "session.cast"
+
+// Shell relay control messages, sent as WebSocket text messages. Terminal data travels as binary messages
+const (
+	// ShellResize carries the operator's new terminal size
+	ShellResize = "resize"
+	// ShellExit reports how the shell ended, just before the engine closes its end
+	ShellExit = "exit"
+	// ShellError explains why the controller refused or dropped an end of the relay
+	ShellError = "error"
+)
+
+// ShellMessage is a control message on a shell relay
+type ShellMessage struct {
+	Type     string `json:"type"`
+	Cols     int    `json:"cols,omitempty"`
+	Rows     int    `json:"rows,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// validateShell checks a shell job: the engine starts the login shell itself, so the job only
+// describes where to log in and how the terminal looks
+func (j JobDefinition) validateShell() error {
+	switch {
+	case j.Command != "" || len(j.Arguments) > 0:
+		return fmt.Errorf("job %s shell jobs run the login shell and cannot set command or arguments", j.ID)
+	case j.Transfer != nil || j.Script != nil:
+		return fmt.Errorf("job %s transfer and script require a matching type", j.ID)
+	case !j.AllowTTY:
+		return fmt.Errorf("job %s shell jobs need allow_tty", j.ID)
+	case j.Stdin != nil || len(j.Expect) > 0 || len(j.Env) > 0:
+		return fmt.Errorf("job %s shell jobs cannot set stdin, env or expect steps", j.ID)
+	case j.Become != nil || j.WorkingDir != "" || j.UploadOutput:
+		return fmt.Errorf("job %s shell jobs cannot set become, working_dir or upload_output", j.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShellJobsOnlyRunOnTheirOwn(t *testing.T) {
+	command := JobDefinition{ID: "j", TargetHost: "web01", TargetUser: "ops", Command: "true",
+		Checksum: "x", Credentials: CredentialBundle{Username: "ops", Password: "p"}}
+	shell := command
+	shell.Type, shell.Command, shell.AllowTTY = JobShell, "", true
+	if err := shell.Validate(); err != nil {
+		t.Fatalf("shell job invalid on its own: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		validate func(JobDefinition) error
+		want     string
+	}{
+		{
+			name: "workflow",
+			validate: func(job JobDefinition) error {
+				return WorkflowDefinition{ID: "wf", Jobs: []WorkflowJob{{JobDefinition: job}}}.Validate()
+			},
+			want: "workflow wf: job j: shell jobs must be submitted on their own",
+		},
+		{
+			name: "fan-out",
+			validate: func(job JobDefinition) error {
+				return FanOutDefinition{ID: "fo", Hosts: []string{"web01", "web02"}, Template: job}.Validate()
+			},
+			want: "fan-out fo: shell jobs must be submitted to one host at a time",
+		},
+		{
+			name: "schedule",
+			validate: func(job JobDefinition) error {
+				return ScheduleDefinition{ID: "nightly", Cron: "0 3 * * *", Template: job}.Validate()
+			},
+			want: "schedule nightly: shell jobs cannot be scheduled",
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.validate(command); err != nil {
+			t.Errorf("%s: command job rejected: %v", tt.name, err)
+		}
+		if err := tt.validate(shell); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: shell job error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import "time"
+
+// JobSummary is the compact view of a job used for listings and status checks
+type JobSummary struct {
+	ID              string    `yaml:"id" json:"id"`
+	Status          Status    `yaml:"status" json:"status"`
+	Queue           string    `yaml:"queue" json:"queue"`
+	Priority        int       `yaml:"priority" json:"priority"`
+	TargetHost      string    `yaml:"target_host" json:"target_host"`
+	Command         string    `yaml:"command" json:"command"`
+	SubmittedAt     time.Time `yaml:"submitted_at" json:"submitted_at"`
+	StartedAt       time.Time `yaml:"started_at,omitempty" json:"started_at,omitzero"`
+	FinishedAt      time.Time `yaml:"finished_at,omitempty" json:"finished_at,omitzero"`
+	ExitCode        int       `yaml:"exit_code" json:"exit_code"`
+	Engine          string    `yaml:"engine,omitempty" json:"engine,omitempty"`
+	CancelRequested bool      `yaml:"cancel_requested" json:"cancel_requested,omitempty"`
+}
+
+// LogChunk is a slice of live output streamed by the engine while a job runs
+type LogChunk struct {
+	Stream string    `yaml:"stream" json:"stream"` // stdout or stderr
+	Data   string    `yaml:"data" json:"data"`
+	Time   time.Time `yaml:"time" json:"time"`
+}
+
+// LogPage is one read of a job's streamed output starting at an offset
+type LogPage struct {
+	Chunks []LogChunk `yaml:"chunks" json:"chunks"`
+	// Next is the offset to pass on the following read
+	Next int `yaml:"next" json:"next"`
+	// Done is true once the job reached a final state and no more chunks will arrive
+	Done bool `yaml:"done" json:"done"`
+	// Dropped counts chunks between the requested offset and the first returned chunk that the
+	// controller discarded to stay within its per-job log limit
+	Dropped int `yaml:"dropped,omitempty" json:"dropped,omitempty"`
+}
+
+// Final reports whether a status will no longer change
+func (s Status) Final() bool {
+	switch s {
+	case StatusSucceeded, StatusFailed, StatusExpired, StatusCancelled, StatusSkipped, StatusTimedOut:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,341 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ParamType restricts what a template parameter accepts
+type ParamType string
+
+const (
+	ParamString ParamType = "string"
+	ParamInt    ParamType = "int"
+	ParamEnum   ParamType = "enum"
+	// ParamHost must name a host known to the controller inventory
+	ParamHost ParamType = "host"
+)
+
+// JobTemplate is a reusable job with ${name} placeholders in its command, arguments and metadata.
+// Values substituted into the command are shell-quoted; arguments are passed as single words anyway
+type JobTemplate struct {
+	Name        string          `yaml:"name" json:"name"`
+	Description string          `yaml:"description" json:"description,omitempty"`
+	Parameters  []TemplateParam `yaml:"parameters" json:"parameters"`
+	// Job is rendered per run; ID, checksum and credentials are supplied at instantiation
+	Job JobDefinition `yaml:"job" json:"job"`
+}
+
+// TemplateParam declares one typed template parameter
+type TemplateParam struct {
+	Name     string    `yaml:"name" json:"name"`
+	Type     ParamType `yaml:"type" json:"type"`
+	Required bool      `yaml:"required" json:"required,omitempty"`
+	Default  string    `yaml:"default" json:"default,omitempty"`
+	// Values lists the accepted choices for enum parameters
+	Values []string `yaml:"values" json:"values,omitempty"`
+	// Pattern optionally constrains string parameters with an anchored regular expression
+	Pattern string `yaml:"pattern" json:"pattern,omitempty"`
+}
+
+// placeholderRe matches ${name}; "$$" escapes a literal dollar sign
+var placeholderRe = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var paramNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (t JobTemplate) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return errors.New("template name cannot be empty")
+	}
+	if strings.TrimSpace(t.Job.Command) == "" {
+		return fmt.Errorf("template %s missing command", t.Name)
+	}
+
+	declared := make(map[string]struct{}, len(t.Parameters))
+	for _, p := range t.Parameters {
+		if !paramNameRe.MatchString(p.Name) {
+			return fmt.Errorf("template %s parameter name %q is invalid", t.Name, p.Name)
+		}
+		if _, dup := declared[p.Name]; dup {
+			return fmt.Errorf("template %s declares parameter %s twice", t.Name, p.Name)
+		}
+		declared[p.Name] = struct{}{}
+
+		if err := p.validateSpec(); err != nil {
+			return fmt.Errorf("template %s: %w", t.Name, err)
+		}
+		if p.Default != "" && p.Type != ParamHost {
+			if err := p.check(p.Default); err != nil {
+				return fmt.Errorf("template %s parameter %s default: %w", t.Name, p.Name, err)
+			}
+		}
+	}
+
+	for _, field := range t.fields() {
+		for _, m := range placeholderRe.FindAllStringSubmatch(field, -1) {
+			if m[1] == "" {
+				continue
+			}
+			if _, ok := declared[m[1]]; !ok {
+				return fmt.Errorf("template %s references undeclared parameter %s", t.Name, m[1])
+			}
+		}
+	}
+	if err := checkCommandPlaceholders(t.Job.Command); err != nil {
+		return fmt.Errorf("template %s: %w", t.Name, err)
+	}
+
+	return nil
+}
+
+// checkCommandPlaceholders requires every placeholder in a command to be a shell word of its own:
+// outside quotes and between whitespace or the ends of the line. Quoting a value only makes it one
+// word where it stands alone; inside quotes it could close them, and next to other characters an
+// empty value would change what the word means
+func checkCommandPlaceholders(command string) error {
+	var quote byte // the quote character we are inside, if any
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'' && c == '\'':
+			quote = 0
+			continue
+		case quote == '\'' && c != '$':
+			// Backslashes are literal within single quotes, but placeholders are still substituted
+			continue
+		case c == '\\':
+			i++ // the escaped character is literal
+			continue
+		case quote == '"' && c == '"':
+			quote = 0
+			continue
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+			continue
+		case c != '$':
+			continue
+		}
+
+		loc := placeholderRe.FindStringSubmatchIndex(command[i:])
+		if loc == nil || loc[0] != 0 {
+			continue
+		}
+		end := i + loc[1]
+		if loc[2] < 0 {
+			// "$$" is a literal dollar sign
+			i = end - 1
+			continue
+		}
+
+		name := command[i:end]
+		if quote != 0 {
+			return fmt.Errorf("command placeholder %s must not be inside quotes; it is quoted already", name)
+		}
+		if i > 0 && !isShellSpace(command[i-1]) || end < len(command) && !isShellSpace(command[end]) {
+			return fmt.Errorf("command placeholder %s must be a separate word, not joined to other characters", name)
+		}
+		i = end - 1
+	}
+
+	return nil
+}
+
+func isShellSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// fields lists every string the template substitutes into
+func (t JobTemplate) fields() []string {
+	out := []string{t.Job.TargetHost, t.Job.TargetGroup, t.Job.Command}
+	out = append(out, t.Job.Arguments...)
+	for _, v := range t.Job.Metadata {
+		out = append(out, v)
+	}
+
+	return out
+}
+
+func (p TemplateParam) validateSpec() error {
+	switch p.Type {
+	case ParamString, ParamInt, ParamHost:
+	case ParamEnum:
+		if len(p.Values) == 0 {
+			return fmt.Errorf("enum parameter %s has no values", p.Name)
+		}
+	default:
+		return fmt.Errorf("parameter %s has unknown type %q", p.Name, p.Type)
+	}
+
+	if p.Pattern != "" {
+		if p.Type != ParamString {
+			return fmt.Errorf("parameter %s: pattern only applies to string parameters", p.Name)
+		}
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return fmt.Errorf("parameter %s pattern: %w", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// check validates a supplied value against the parameter type. Host existence is checked by the caller
+func (p TemplateParam) check(value string) error {
+	// Values land in commands; refuse anything that could break out of a single line
+	if strings.ContainsAny(value, "\x00\r\n") {
+		return errors.New("value contains control characters")
+	}
+
+	switch p.Type {
+	case ParamInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+	case ParamEnum:
+		if !slices.Contains(p.Values, value) {
+			return fmt.Errorf("%q is not one of %s", value, strings.Join(p.Values, ", "))
+		}
+	case ParamHost:
+		if !hostnameRe.MatchString(value) {
+			return fmt.Errorf("%q is not a valid host name", value)
+		}
+	case ParamString:
+		if p.Pattern != "" && !regexp.MustCompile(`^(?:`+p.Pattern+`)$`).MatchString(value) {
+			return fmt.Errorf("%q does not match pattern %s", value, p.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// Target returns the target host the template renders to with params, falling back to parameter
+// defaults. Values are not checked; Render does that
+func (t JobTemplate) Target(params map[string]string) string {
+	defaults := make(map[string]string, len(t.Parameters))
+	for _, p := range t.Parameters {
+		defaults[p.Name] = p.Default
+	}
+
+	return placeholderRe.ReplaceAllStringFunc(t.Job.TargetHost, func(m string) string {
+		if m == "$$" {
+			return "$"
+		}
+		name := m[2 : len(m)-1]
+		if v, ok := params[name]; ok {
+			return v
+		}
+		return defaults[name]
+	})
+}
+
+// safeShellWord matches words that need no quoting for a POSIX shell
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellQuote returns word as a single POSIX shell word, single-quoting it unless it is plainly safe
+func ShellQuote(word string) string {
+	if safeShellWord.MatchString(word) {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+var hostnameRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+// Render substitutes params into a copy of the template job. Unknown parameters, missing required
+// values and type mismatches are all reported together. hostExists resolves ParamHost values;
+// nil skips the existence check. The returned job has no ID, checksum or credentials
+func (t JobTemplate) Render(params map[string]string, hostExists func(string) bool) (JobDefinition, error) {
+	values := make(map[string]string, len(t.Parameters))
+	var problems []string
+
+	declared := make(map[string]TemplateParam, len(t.Parameters))
+	for _, p := range t.Parameters {
+		declared[p.Name] = p
+	}
+	for name := range params {
+		if _, ok := declared[name]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown parameter %s", name))
+		}
+	}
+
+	for _, p := range t.Parameters {
+		value, supplied := params[p.Name]
+		if !supplied {
+			if p.Required && p.Default == "" {
+				problems = append(problems, fmt.Sprintf("parameter %s is required", p.Name))
+				continue
+			}
+			value = p.Default
+		}
+		if value == "" && !p.Required {
+			// Optional parameters without a default render as empty
+			values[p.Name] = ""
+			continue
+		}
+
+		if err := p.check(value); err != nil {
+			problems = append(problems, fmt.Sprintf("parameter %s: %v", p.Name, err))
+			continue
+		}
+		if p.Type == ParamHost && hostExists != nil && !hostExists(value) {
+			problems = append(problems, fmt.Sprintf("parameter %s: host %s not in inventory", p.Name, value))
+			continue
+		}
+		values[p.Name] = value
+	}
+
+	// Validate rejects these too; templates built without it must not render unsafely either
+	if err := checkCommandPlaceholders(t.Job.Command); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return JobDefinition{}, fmt.Errorf("template %s: %s", t.Name, strings.Join(problems, "; "))
+	}
+
+	substitute := func(s string, quote func(string) string) string {
+		return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+			if m == "$$" {
+				return "$"
+			}
+			return quote(values[m[2:len(m)-1]])
+		})
+	}
+	sub := func(s string) string {
+		return substitute(s, func(v string) string { return v })
+	}
+	// The command runs through the remote shell and every placeholder stands alone as a word (see
+	// checkCommandPlaceholders): each value becomes exactly that word, or none when empty, so
+	// parameters cannot add commands, expansions or redirections
+	subCommand := func(s string) string {
+		return substitute(s, func(v string) string {
+			if v == "" {
+				return ""
+			}
+			return ShellQuote(v)
+		})
+	}
+
+	job := t.Job
+	job.ID = ""
+	job.Checksum = ""
+	job.Credentials = CredentialBundle{}
+	job.TargetHost = sub(job.TargetHost)
+	job.TargetGroup = sub(job.TargetGroup)
+	job.Command = subCommand(job.Command)
+	job.Arguments = make([]string, len(t.Job.Arguments))
+	for i, arg := range t.Job.Arguments {
+		job.Arguments[i] = sub(arg)
+	}
+	job.Metadata = make(map[string]string, len(t.Job.Metadata)+1)
+	for k, v := range t.Job.Metadata {
+		job.Metadata[k] = sub(v)
+	}
+	job.Metadata["template"] = t.Name
+
+	return job, nil
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplateRenderQuotesCommand(t *testing.T) {
+	tmpl := JobTemplate{
+		Name: "grep",
+		Parameters: []TemplateParam{
+			{Name: "pattern", Type: ParamString, Required: true},
+			{Name: "flags", Type: ParamString},
+			{Name: "host", Type: ParamHost, Default: "web01"},
+		},
+		Job: JobDefinition{
+			TargetHost: "${host}",
+			Command:    "grep ${flags} -- ${pattern} /var/log/app.log | tail -n $$LINES",
+			Arguments:  []string{"${pattern}"},
+			Metadata:   map[string]string{"pattern": "${pattern}"},
+		},
+	}
+	if err := tmpl.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	tests := []struct {
+		params  map[string]string
+		command string
+	}{
+		{map[string]string{"pattern": "error"}, "grep  -- error /var/log/app.log | tail -n $LINES"},
+		{map[string]string{"pattern": "x; rm -rf /", "flags": "-i"}, "grep -i -- 'x; rm -rf /' /var/log/app.log | tail -n $LINES"},
+		{map[string]string{"pattern": "$(id)`id`"}, "grep  -- '$(id)`id`' /var/log/app.log | tail -n $LINES"},
+		{map[string]string{"pattern": "it's"}, `grep  -- 'it'\''s' /var/log/app.log | tail -n $LINES`},
+	}
+	for _, tt := range tests {
+		job, err := tmpl.Render(tt.params, nil)
+		if err != nil {
+			t.Errorf("Render(%v): %v", tt.params, err)
+			continue
+		}
+		if job.Command != tt.command {
+			t.Errorf("Render(%v) command %q, want %q", tt.params, job.Command, tt.command)
+		}
+		// Only the command goes through the shell
+		if job.Arguments[0] != tt.params["pattern"] || job.Metadata["pattern"] != tt.params["pattern"] {
+			t.Errorf("Render(%v) quoted arguments or metadata: %q, %q", tt.params, job.Arguments[0], job.Metadata["pattern"])
+		}
+	}
+}
+
+func TestTemplateRenderErrors(t *testing.T) {
+	tmpl := JobTemplate{
+		Name: "restart",
+		Parameters: []TemplateParam{
+			{Name: "service", Type: ParamEnum, Values: []string{"nginx", "redis"}, Required: true},
+			{Name: "count", Type: ParamInt},
+			{Name: "tag", Type: ParamString, Pattern: `[a-z]+`},
+		},
+		Job: JobDefinition{Command: "systemctl restart ${service}"},
+	}
+
+	_, err := tmpl.Render(map[string]string{"count": "two", "tag": "v1\nid", "extra": "x"}, nil)
+	if err == nil {
+		t.Fatal("Render accepted invalid parameters")
+	}
+	for _, want := range []string{"unknown parameter extra", "parameter count", "parameter service is required", "parameter tag: value contains control characters"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestTemplateCommandPlaceholders(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string // empty: accepted
+	}{
+		{"echo ${msg}", ""},
+		{"${msg}", ""},
+		{"echo ${msg}\tdone", ""},
+		{"echo '$$' ${msg} | tail -n $$LINES", ""},
+		{`echo \${msg}`, ""},
+		{"echo 'lit ${x' ${msg}", ""},
+		{"echo '${msg}'", "inside quotes"},
+		{`echo "${msg}"`, "inside quotes"},
+		{`echo "it's ${msg}"`, "inside quotes"},
+		{"rm -rf /srv/${msg}", "separate word"},
+		{"echo a${msg}", "separate word"},
+		{"echo ${msg};", "separate word"},
+		{"echo ${msg}>/tmp/out", "separate word"},
+	}
+	for _, tt := range tests {
+		tmpl := JobTemplate{
+			Name:       "t",
+			Parameters: []TemplateParam{{Name: "msg", Type: ParamString}},
+			Job:        JobDefinition{Command: tt.command},
+		}
+		err := tmpl.Validate()
+		if tt.want == "" && err != nil {
+			t.Errorf("Validate(%q) = %v", tt.command, err)
+		} else if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("Validate(%q) = %v, want %q", tt.command, err, tt.want)
+		}
+
+		// Render refuses the same commands for templates that skipped validation
+		_, err = tmpl.Render(map[string]string{"msg": ""}, nil)
+		if (err == nil) != (tt.want == "") {
+			t.Errorf("Render(%q) with an empty value = %v", tt.command, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Terminal defaults, matching what the engine requested before jobs could choose
+const (
+	DefaultTerm = "xterm"
+	DefaultCols = 80
+	DefaultRows = 24
+	// MaxTerminalSize bounds both rows and columns
+	MaxTerminalSize = 1000
+)
+
+var termNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_-]{0,63}$`)
+
+// TerminalModeOpcodes maps the mode names jobs may set to their SSH opcodes (RFC 4254 section 8).
+// Every mode is a 0/1 flag except ispeed and ospeed, which take a baud rate
+var TerminalModeOpcodes = map[string]uint8{
+	"inlcr":   34,
+	"igncr":   35,
+	"icrnl":   36,
+	"ixon":    38,
+	"ixany":   39,
+	"ixoff":   40,
+	"imaxbel": 41,
+	"iutf8":   42,
+	"isig":    50,
+	"icanon":  51,
+	"echo":    53,
+	"echoe":   54,
+	"echok":   55,
+	"echonl":  56,
+	"noflsh":  57,
+	"tostop":  58,
+	"iexten":  59,
+	"echoctl": 60,
+	"echoke":  61,
+	"opost":   70,
+	"onlcr":   72,
+	"ocrnl":   73,
+	"onocr":   74,
+	"onlret":  75,
+	"ispeed":  128,
+	"ospeed":  129,
+}
+
+// terminalSpeeds are the baud rates accepted for ispeed and ospeed
+var terminalSpeeds = []uint32{1200, 2400, 4800, 9600, 19200, 38400, 57600, 115200, 230400}
+
+// TerminalSpec sets up the pseudo-terminal of an allow_tty job. Unset fields take the defaults;
+// modes left out keep the server's defaults
+type TerminalSpec struct {
+	Term string `yaml:"term,omitempty" json:"term,omitempty"`
+	Cols int    `yaml:"cols,omitempty" json:"cols,omitempty"`
+	Rows int    `yaml:"rows,omitempty" json:"rows,omitempty"`
+	// Modes sets terminal modes by name, e.g. {echo: 0, icrnl: 1, ospeed: 38400}
+	Modes map[string]uint32 `yaml:"modes,omitempty" json:"modes,omitempty"`
+}
+
+// Settings returns the terminal type and size with defaults applied
+func (t *TerminalSpec) Settings() (term string, cols, rows int) {
+	term, cols, rows = DefaultTerm, DefaultCols, DefaultRows
+	if t == nil {
+		return term, cols, rows
+	}
+	if t.Term != "" {
+		term = t.Term
+	}
+	if t.Cols > 0 {
+		cols = t.Cols
+	}
+	if t.Rows > 0 {
+		rows = t.Rows
+	}
+
+	return term, cols, rows
+}
+
+// ModeString renders the modes as sorted name=value pairs, e.g. "echo=0 icrnl=1"
+func (t *TerminalSpec) ModeString() string {
+	if t == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(t.Modes))
+	for _, name := range slices.Sorted(maps.Keys(t.Modes)) {
+		pairs = append(pairs, name+"="+strconv.FormatUint(uint64(t.Modes[name]), 10))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func (j JobDefinition) validateTerminal() error {
+	t := j.Terminal
+	if t == nil {
+		return nil
+	}
+	if !j.AllowTTY {
+		return fmt.Errorf("job %s terminal needs allow_tty", j.ID)
+	}
+	if t.Term != "" && !termNameRe.MatchString(t.Term) {
+		return fmt.Errorf("job %s terminal term %q is invalid", j.ID, t.Term)
+	}
+	if t.Cols < 0 || t.Cols > MaxTerminalSize || t.Rows < 0 || t.Rows > MaxTerminalSize {
+		return fmt.Errorf("job %s terminal cols and rows must be between 1 and %d", j.ID, MaxTerminalSize)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(t.Modes)) {
+		value := t.Modes[name]
+		switch _, known := TerminalModeOpcodes[name]; {
+		case !known:
+			return fmt.Errorf("job %s terminal mode %q is not supported", j.ID, name)
+		case name == "ispeed" || name == "ospeed":
+			if !slices.Contains(terminalSpeeds, value) {
+				return fmt.Errorf("job %s terminal %s %d is not a supported baud rate", j.ID, name, value)
+			}
+		case value > 1:
+			return fmt.Errorf("job %s terminal mode %s must be 0 or 1", j.ID, name)
+		}
+	}
+
+	return nil
+}
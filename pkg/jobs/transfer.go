@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// JobType selects what the engine does with a job; the zero value runs Command
+type JobType string
+
+const (
+	JobCommand JobType = "command"
+	// JobUpload copies an artifact stored by the controller to a path on the target over SFTP
+	JobUpload JobType = "upload"
+	// JobDownload copies a file from the target over SFTP and stores it as a job artifact
+	JobDownload JobType = "download"
+)
+
+// FileTransfer describes the file moved by an upload or download job. Payloads never travel
+// inline: uploads read a controller artifact and downloads produce one
+type FileTransfer struct {
+	// RemotePath is the absolute destination (upload) or source (download) path on the target
+	RemotePath string `yaml:"remote_path" json:"remote_path"`
+	// Artifact names the job artifact holding the payload; downloads default to the remote base name
+	Artifact string `yaml:"artifact,omitempty" json:"artifact,omitempty"`
+	// ArtifactJob is the job whose artifacts hold an upload payload, so one payload can feed
+	// many jobs such as a group fan-out; empty means the upload job itself
+	ArtifactJob string `yaml:"artifact_job,omitempty" json:"artifact_job,omitempty"`
+	// Mode is the octal permission set applied to an uploaded file before it is renamed into place
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+	// Owner is a numeric "uid[:gid]" applied to an uploaded file before it is renamed into place
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	// SHA256 is the expected digest of the file. Uploads require it, so the signed job pins the
+	// payload rather than trusting whatever the controller serves
+	SHA256 string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+}
+
+// ArtifactName returns the artifact the transfer reads or writes
+func (t FileTransfer) ArtifactName() string {
+	if t.Artifact != "" {
+		return t.Artifact
+	}
+
+	return path.Base(t.RemotePath)
+}
+
+func (t FileTransfer) checksumInput(kind JobType) string {
+	return strings.Join([]string{string(kind), t.RemotePath, t.ArtifactJob, t.ArtifactName(), t.Mode, t.Owner, t.SHA256}, "\n")
+}
+
+// FileMode parses Mode; ok is false when no mode was requested
+func (t FileTransfer) FileMode() (mode os.FileMode, ok bool, err error) {
+	if t.Mode == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseUint(t.Mode, 8, 32)
+	if err != nil || v > 0o7777 {
+		return 0, false, fmt.Errorf("mode %q is not an octal permission set", t.Mode)
+	}
+
+	return os.FileMode(v), true, nil
+}
+
+// IDs parses Owner; a missing gid is returned as -1. ok is false when no owner was requested
+func (t FileTransfer) IDs() (uid, gid int, ok bool, err error) {
+	if t.Owner == "" {
+		return 0, 0, false, nil
+	}
+	u, g, hasGroup := strings.Cut(t.Owner, ":")
+	gid = -1
+	if uid, err = strconv.Atoi(u); err != nil || uid < 0 {
+		return 0, 0, false, fmt.Errorf("owner %q must be a numeric uid[:gid]", t.Owner)
+	}
+	if hasGroup {
+		if gid, err = strconv.Atoi(g); err != nil || gid < 0 {
+			return 0, 0, false, fmt.Errorf("owner %q must be a numeric uid[:gid]", t.Owner)
+		}
+	}
+
+	return uid, gid, true, nil
+}
+
+func (j JobDefinition) validateTransfer() error {
+	t := j.Transfer
+	if t == nil {
+		return fmt.Errorf("job %s missing transfer", j.ID)
+	}
+	if j.Command != "" || j.Script != nil {
+		return fmt.Errorf("job %s %s jobs cannot set command or script", j.ID, j.Kind())
+	}
+	if !path.IsAbs(t.RemotePath) || path.Clean(t.RemotePath) != t.RemotePath || t.RemotePath == "/" {
+		return fmt.Errorf("job %s transfer remote_path %q must be a clean absolute file path", j.ID, t.RemotePath)
+	}
+	if j.Kind() == JobUpload && t.Artifact == "" {
+		return fmt.Errorf("job %s upload missing transfer artifact", j.ID)
+	}
+	if j.Kind() == JobUpload && t.SHA256 == "" {
+		return fmt.Errorf("job %s upload missing transfer sha256", j.ID)
+	}
+	if err := ValidateArtifactName(t.ArtifactName()); err != nil {
+		return fmt.Errorf("job %s transfer: %w", j.ID, err)
+	}
+	if t.ArtifactJob != "" && ValidateArtifactName(t.ArtifactJob) != nil {
+		return fmt.Errorf("job %s transfer artifact_job %q is not a valid job id", j.ID, t.ArtifactJob)
+	}
+	if j.Kind() == JobDownload && (t.Mode != "" || t.Owner != "" || t.ArtifactJob != "") {
+		return fmt.Errorf("job %s download cannot set mode, owner or artifact_job", j.ID)
+	}
+	if _, _, err := t.FileMode(); err != nil {
+		return fmt.Errorf("job %s transfer %w", j.ID, err)
+	}
+	if _, _, _, err := t.IDs(); err != nil {
+		return fmt.Errorf("job %s transfer %w", j.ID, err)
+	}
+	if t.SHA256 != "" && !isHexDigest(t.SHA256) {
+		return fmt.Errorf("job %s transfer sha256 must be 64 hex characters", j.ID)
+	}
+
+	return nil
+}
+
+func isHexDigest(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUploadRequiresSHA256(t *testing.T) {
+	job := JobDefinition{ID: "push", TargetHost: "h", TargetUser: "u", Checksum: "x", Type: JobUpload,
+		Transfer:    &FileTransfer{RemotePath: "/etc/app.conf", Artifact: "app.conf"},
+		Credentials: CredentialBundle{Username: "u", Password: "p"}}
+	if err := job.Validate(); err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("Validate accepted an upload without a pinned sha256: %v", err)
+	}
+
+	job.Transfer.SHA256 = strings.Repeat("a", 64)
+	if err := job.Validate(); err != nil {
+		t.Errorf("Validate with sha256: %v", err)
+	}
+
+	// Downloads produce the artifact, so the digest stays optional
+	download := JobDefinition{ID: "pull", TargetHost: "h", TargetUser: "u", Checksum: "x", Type: JobDownload,
+		Transfer:    &FileTransfer{RemotePath: "/etc/app.conf"},
+		Credentials: CredentialBundle{Username: "u", Password: "p"}}
+	if err := download.Validate(); err != nil {
+		t.Errorf("Validate download without sha256: %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package jobs
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 )
@@ -14,21 +17,72 @@ const (
 	StatusRunning   Status = "running"
 	StatusFailed    Status = "failed"
 	StatusSucceeded Status = "succeeded"
+	// StatusScheduled marks jobs held by the controller until their start time
+	StatusScheduled Status = "scheduled"
+	// StatusExpired marks jobs that passed their deadline before an engine claimed them
+	StatusExpired Status = "expired"
+	// StatusCancelled marks jobs withdrawn before an engine picked them up
+	StatusCancelled Status = "cancelled"
+	// StatusTimedOut marks jobs the engine stopped for exceeding their time limit
+	StatusTimedOut Status = "timed_out"
 )
 
 type JobDefinition struct {
 	ID         string `yaml:"id" json:"id"`
 	TargetHost string `yaml:"target_host" json:"target_host"`
+	// TargetGroup names an inventory group; the controller expands it into one job per member host
+	TargetGroup string `yaml:"target_group,omitempty" json:"target_group,omitempty"`
 	// Defaults ingested when zero
-	TargetPort int      `yaml:"target_port" json:"target_port"`
-	TargetUser string   `yaml:"target_user" json:"target_user"`
-	Command    string   `yaml:"command" json:"command"`
-	Arguments  []string `yaml:"arguments" json:"arguments"`
+	TargetPort int    `yaml:"target_port" json:"target_port"`
+	TargetUser string `yaml:"target_user" json:"target_user"`
+	// Command is a shell command line, or the program to run when Arguments are given
+	Command   string   `yaml:"command" json:"command"`
+	Arguments []string `yaml:"arguments" json:"arguments"`
 	// Controls whether the engine requests a pseudo-terminal for interactive commands
 	AllowTTY    bool              `yaml:"allow_tty" json:"allow_tty"`
 	Checksum    string            `yaml:"checksum" json:"checksum"`
 	Metadata    map[string]string `yaml:"metadata" json:"metadata"`
 	Credentials CredentialBundle  `yaml:"credentials" json:"credentials"`
+	// HostKeyFingerprint pins the target's SHA256 host key, usually filled in from the inventory
+	HostKeyFingerprint string `yaml:"host_key_fingerprint,omitempty" json:"host_key_fingerprint,omitempty"`
+	// Queue names the controller queue the job waits in (empty means "default")
+	Queue string `yaml:"queue,omitempty" json:"queue,omitempty"`
+	// Priority orders jobs within a queue; higher values are dispatched first
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+	// NotBefore and RunAt hold the job in the controller until the later of the two has passed
+	NotBefore time.Time `yaml:"not_before,omitempty" json:"not_before,omitzero"`
+	RunAt     time.Time `yaml:"run_at,omitempty" json:"run_at,omitzero"`
+	// Deadline expires the job instead of running it late when no engine claimed it in time
+	Deadline time.Time `yaml:"deadline,omitempty" json:"deadline,omitzero"`
+	// TimeoutSeconds bounds execution on the engine; it can only shorten the engine's job_timeout_seconds
+	TimeoutSeconds int `yaml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"`
+	// MaxOutputBytes caps captured stdout and stderr each; it can only lower the engine's max_output_bytes
+	MaxOutputBytes int64 `yaml:"max_output_bytes,omitempty" json:"max_output_bytes,omitempty"`
+	// UploadOutput stores the full output as compressed artifacts and keeps only the capped preview inline
+	UploadOutput bool `yaml:"upload_output,omitempty" json:"upload_output,omitempty"`
+	// Type selects a file transfer, script or interactive shell instead of running Command; Transfer and Script describe the first two
+	Type     JobType       `yaml:"type,omitempty" json:"type,omitempty"`
+	Transfer *FileTransfer `yaml:"transfer,omitempty" json:"transfer,omitempty"`
+	Script   *ScriptSpec   `yaml:"script,omitempty" json:"script,omitempty"`
+	// Stdin feeds the command's standard input
+	Stdin *StdinSource `yaml:"stdin,omitempty" json:"stdin,omitempty"`
+	// Env is sent as SSH environment requests, falling back to an `env` prefix when the server
+	// refuses them; SecretEnv names the variables whose values are redacted once the job is dispatched
+	Env       map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	SecretEnv []string          `yaml:"secret_env,omitempty" json:"secret_env,omitempty"`
+	// WorkingDir is the absolute directory the command runs in
+	WorkingDir string `yaml:"working_dir,omitempty" json:"working_dir,omitempty"`
+	// Become runs the command as another user via sudo or su after logging in
+	Become *BecomeSpec `yaml:"become,omitempty" json:"become,omitempty"`
+	// Expect drives the command's terminal with expect/send steps, in order; Secrets holds the values
+	// steps may send by name, which are masked in output and redacted once the job is dispatched
+	Expect  []ExpectStep      `yaml:"expect,omitempty" json:"expect,omitempty"`
+	Secrets map[string]string `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	// Terminal sets the pseudo-terminal's type, size and modes for allow_tty jobs
+	Terminal *TerminalSpec `yaml:"terminal,omitempty" json:"terminal,omitempty"`
+	// IdleTimeoutSeconds ends a shell job after that long without operator input; it can only
+	// shorten the engine's shell_idle_timeout_seconds
+	IdleTimeoutSeconds int `yaml:"idle_timeout_seconds,omitempty" json:"idle_timeout_seconds,omitempty"`
 }
 
 type CredentialBundle struct {
@@ -46,6 +100,26 @@ type Result struct {
 	Stderr     string            `yaml:"stderr" json:"stderr"`
 	Error      string            `yaml:"error" json:"error"`
 	Metadata   map[string]string `yaml:"metadata" json:"metadata"`
+	// ErrorClass categorizes the failure; empty when the job succeeded
+	ErrorClass ErrorClass `yaml:"error_class,omitempty" json:"error_class,omitempty"`
+	// ExitSignal names the signal that killed the remote command (e.g. "KILL" for the OOM killer),
+	// with SignalMessage carrying the server's explanation when it sent one and CoreDumped set when
+	// the server reported a core dump
+	ExitSignal    string `yaml:"exit_signal,omitempty" json:"exit_signal,omitempty"`
+	SignalMessage string `yaml:"signal_message,omitempty" json:"signal_message,omitempty"`
+	CoreDumped    bool   `yaml:"core_dumped,omitempty" json:"core_dumped,omitempty"`
+	// ExitStatusMissing is true when the session ended without the server reporting an exit status or signal
+	ExitStatusMissing bool `yaml:"exit_status_missing,omitempty" json:"exit_status_missing,omitempty"`
+	// StdoutBytes and StderrBytes count everything the command wrote; when a stream exceeded the
+	// output cap only its head and tail are kept inline and the matching Truncated flag is set
+	StdoutBytes     int64 `yaml:"stdout_bytes" json:"stdout_bytes"`
+	StderrBytes     int64 `yaml:"stderr_bytes" json:"stderr_bytes"`
+	StdoutTruncated bool  `yaml:"stdout_truncated,omitempty" json:"stdout_truncated,omitempty"`
+	StderrTruncated bool  `yaml:"stderr_truncated,omitempty" json:"stderr_truncated,omitempty"`
+	// Artifacts lists files stored by the controller for this job, such as the full output
+	Artifacts []Artifact `yaml:"artifacts,omitempty" json:"artifacts,omitempty"`
+	// Transcript records each expect step of the job
+	Transcript []TranscriptEntry `yaml:"transcript,omitempty" json:"transcript,omitempty"`
 }
 
 func (j JobDefinition) Validate() error {
@@ -58,18 +132,180 @@ func (j JobDefinition) Validate() error {
 	if j.TargetUser == "" {
 		return fmt.Errorf("job %s missing target_user", j.ID)
 	}
-	if j.Command == "" {
-		return fmt.Errorf("job %s missing command", j.ID)
+	if err := j.validateType(); err != nil {
+		return err
+	}
+	if err := j.validateInput(); err != nil {
+		return err
+	}
+	if err := j.validateBecome(); err != nil {
+		return err
+	}
+	if err := j.validateTerminal(); err != nil {
+		return err
+	}
+	if err := j.validateExpect(); err != nil {
+		return err
 	}
 	if j.Checksum == "" {
 		return fmt.Errorf("job %s missing checksum", j.ID)
 	}
+	if strings.ContainsRune(j.baseChecksumInput(), 0) {
+		return fmt.Errorf("job %s command, script or transfer contains a NUL byte", j.ID)
+	}
 	if err := j.Credentials.Validate(); err != nil {
 		return fmt.Errorf("job %s credentials invalid: %w", j.ID, err)
 	}
+	if j.TimeoutSeconds < 0 {
+		return fmt.Errorf("job %s timeout_seconds cannot be negative", j.ID)
+	}
+	if j.IdleTimeoutSeconds < 0 {
+		return fmt.Errorf("job %s idle_timeout_seconds cannot be negative", j.ID)
+	}
+	if j.MaxOutputBytes < 0 {
+		return fmt.Errorf("job %s max_output_bytes cannot be negative", j.ID)
+	}
+	if due := j.DueAt(); !j.Deadline.IsZero() && !due.IsZero() && !j.Deadline.After(due) {
+		return fmt.Errorf("job %s deadline must be after its start time", j.ID)
+	}
 	return nil
 }
 
+// validateType checks that the fields of the job's type are set and those of other types are not
+func (j JobDefinition) validateType() error {
+	switch j.Kind() {
+	case JobCommand:
+		if j.Command == "" {
+			return fmt.Errorf("job %s missing command", j.ID)
+		}
+		if j.Transfer != nil || j.Script != nil {
+			return fmt.Errorf("job %s transfer and script require a matching type", j.ID)
+		}
+		return nil
+	case JobUpload, JobDownload:
+		return j.validateTransfer()
+	case JobScript:
+		return j.validateScript()
+	case JobShell:
+		return j.validateShell()
+	default:
+		return fmt.Errorf("job %s has unknown type %q", j.ID, j.Type)
+	}
+}
+
+// Kind returns the job type, treating an empty type as a command job
+func (j JobDefinition) Kind() JobType {
+	if j.Type == "" {
+		return JobCommand
+	}
+
+	return j.Type
+}
+
+// ChecksumInput returns the text the job checksum covers: the command for command jobs, the
+// transfer description for file transfers, the interpreter and script body for scripts and
+// the job type for shells. Other fields that change what runs follow after a NUL byte, which
+// Validate keeps out of the first part; jobs that set none of them keep the plain form
+func (j JobDefinition) ChecksumInput() string {
+	base := j.baseChecksumInput()
+	extra, _ := json.Marshal(j.checksumExtras())
+	if string(extra) == "{}" {
+		return base
+	}
+
+	return base + "\x00" + string(extra)
+}
+
+func (j JobDefinition) baseChecksumInput() string {
+	switch {
+	case j.Kind() == JobScript && j.Script != nil:
+		return j.Script.checksumInput()
+	case (j.Kind() == JobUpload || j.Kind() == JobDownload) && j.Transfer != nil:
+		return j.Transfer.checksumInput(j.Kind())
+	case j.Kind() == JobShell:
+		return string(JobShell)
+	default:
+		return j.Command
+	}
+}
+
+// checksumExtras lists the checksummed fields beyond the base input, all omitted when empty.
+// encoding/json writes struct fields in declaration order and map keys sorted, so the encoding is stable
+type checksumExtras struct {
+	Arguments       []string          `json:"arguments,omitempty"`
+	InterpreterArgs []string          `json:"interpreter_args,omitempty"`
+	Env             map[string]string `json:"env,omitempty"`        // values of variables not in secret_env
+	SecretEnv       []string          `json:"secret_env,omitempty"` // names only, sorted
+	Stdin           *StdinSource      `json:"stdin,omitempty"`
+	WorkingDir      string            `json:"working_dir,omitempty"`
+	Become          *BecomeSpec       `json:"become,omitempty"`
+	Expect          []ExpectStep      `json:"expect,omitempty"`
+	Secrets         []string          `json:"secrets,omitempty"` // names only, sorted
+}
+
+// checksumExtras collects the extras; with Arguments and InterpreterArgs a script job's
+// checksum covers the interpreter's whole argv. Secret values never enter the checksum, since
+// anyone holding the job could test guesses against it
+func (j JobDefinition) checksumExtras() checksumExtras {
+	extras := checksumExtras{Arguments: j.Arguments, Stdin: j.Stdin, WorkingDir: j.WorkingDir, Become: j.Become, Expect: j.Expect}
+	if len(j.Secrets) > 0 {
+		extras.Secrets = slices.Sorted(maps.Keys(j.Secrets))
+	}
+	if j.Kind() == JobScript && j.Script != nil {
+		extras.InterpreterArgs = j.Script.InterpreterArgs
+	}
+	for name, value := range j.Env {
+		if slices.Contains(j.SecretEnv, name) {
+			extras.SecretEnv = append(extras.SecretEnv, name)
+			continue
+		}
+		if extras.Env == nil {
+			extras.Env = make(map[string]string, len(j.Env))
+		}
+		extras.Env[name] = value
+	}
+	slices.Sort(extras.SecretEnv)
+
+	return extras
+}
+
+// CommandLine returns the shell command line a command job runs: Command as written, or with
+// Arguments, Command quoted as the program followed by each argument as a single word
+func (j JobDefinition) CommandLine() string {
+	if len(j.Arguments) == 0 {
+		return j.Command
+	}
+
+	words := make([]string, 0, len(j.Arguments)+1)
+	words = append(words, ShellQuote(j.Command))
+	for _, arg := range j.Arguments {
+		words = append(words, ShellQuote(arg))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// PayloadJob returns the job ID an upload payload or script artifact is stored under
+func (j JobDefinition) PayloadJob() string {
+	switch {
+	case j.Transfer != nil && j.Transfer.ArtifactJob != "":
+		return j.Transfer.ArtifactJob
+	case j.Script != nil && j.Script.ArtifactJob != "":
+		return j.Script.ArtifactJob
+	default:
+		return j.ID
+	}
+}
+
+// DueAt returns the earliest time the job may run; zero means immediately
+func (j JobDefinition) DueAt() time.Time {
+	if j.RunAt.After(j.NotBefore) {
+		return j.RunAt
+	}
+
+	return j.NotBefore
+}
+
 func (c CredentialBundle) Validate() error {
 	if strings.TrimSpace(c.Username) == "" {
 		return errors.New("username required")
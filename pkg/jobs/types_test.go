@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"systemctl restart nginx", nil, "systemctl restart nginx"},
+		{"echo $HOME | wc -c", nil, "echo $HOME | wc -c"},
+		{"/usr/bin/uptime", []string{"-p"}, "/usr/bin/uptime -p"},
+		{"/opt/my tool", []string{"a b", "it's", "$(id)", ""}, `'/opt/my tool' 'a b' 'it'\''s' '$(id)' ''`},
+	}
+	for _, tt := range tests {
+		job := JobDefinition{Command: tt.command, Arguments: tt.args}
+		if got := job.CommandLine(); got != tt.want {
+			t.Errorf("CommandLine(%q, %q) = %q, want %q", tt.command, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestChecksumInput(t *testing.T) {
+	plain := JobDefinition{Command: "uptime"}
+	if got := plain.ChecksumInput(); got != "uptime" {
+		t.Errorf("plain command checksum input = %q, want the command alone", got)
+	}
+	if got := (JobDefinition{Command: "uptime", Arguments: []string{}}).ChecksumInput(); got != "uptime" {
+		t.Errorf("empty arguments changed the checksum input to %q", got)
+	}
+
+	// Every field that changes what runs must change the input
+	script := func(args, interpreterArgs []string) JobDefinition {
+		return JobDefinition{Type: JobScript, Arguments: args,
+			Script: &ScriptSpec{Interpreter: "/bin/bash", InterpreterArgs: interpreterArgs, Body: "echo hi"}}
+	}
+	variants := map[string]JobDefinition{
+		"arguments":               {Command: "uptime", Arguments: []string{"-p"}},
+		"script":                  script(nil, nil),
+		"script arguments":        script([]string{"-x"}, nil),
+		"script interpreter args": script(nil, []string{"-x"}),
+		"env":                     {Command: "uptime", Env: map[string]string{"LANG": "C"}},
+		"secret env":              {Command: "uptime", Env: map[string]string{"LANG": "C"}, SecretEnv: []string{"LANG"}},
+		"stdin":                   {Command: "uptime", Stdin: &StdinSource{Data: "y"}},
+		"working dir":             {Command: "uptime", WorkingDir: "/srv"},
+		"become":                  {Command: "uptime", Become: &BecomeSpec{Method: BecomeSudo}},
+		"become user":             {Command: "uptime", Become: &BecomeSpec{Method: BecomeSudo, User: "app"}},
+		"become method":           {Command: "uptime", Become: &BecomeSpec{Method: BecomeSu, User: "app"}},
+		"expect":                  {Command: "uptime", Expect: []ExpectStep{{Expect: "ok", Send: "y"}}},
+		"expect secret":           {Command: "uptime", Expect: []ExpectStep{{Expect: "ok", SendSecret: "pin"}}},
+		"secrets":                 {Command: "uptime", Secrets: map[string]string{"pin": "1234"}},
+	}
+	seen := map[string]string{plain.ChecksumInput(): "plain"}
+	for name, job := range variants {
+		input := job.ChecksumInput()
+		if prev, dup := seen[input]; dup {
+			t.Errorf("%s has the same checksum input as %s: %q", name, prev, input)
+		}
+		seen[input] = name
+	}
+
+	// Secret values stay out of the input, and the order of the names does not matter
+	a := JobDefinition{Command: "uptime", Env: map[string]string{"A": "1", "B": "2"}, SecretEnv: []string{"A", "B"}}
+	b := JobDefinition{Command: "uptime", Env: map[string]string{"A": "x", "B": "y"}, SecretEnv: []string{"B", "A"}}
+	if a.ChecksumInput() != b.ChecksumInput() || strings.Contains(a.ChecksumInput(), `"1"`) {
+		t.Errorf("secret env checksum inputs %q and %q", a.ChecksumInput(), b.ChecksumInput())
+	}
+	a = JobDefinition{Command: "uptime", Secrets: map[string]string{"pin": "1234", "otp": "9"}}
+	b = JobDefinition{Command: "uptime", Secrets: map[string]string{"otp": "x", "pin": "y"}}
+	if a.ChecksumInput() != b.ChecksumInput() || strings.Contains(a.ChecksumInput(), "1234") {
+		t.Errorf("secrets checksum inputs %q and %q", a.ChecksumInput(), b.ChecksumInput())
+	}
+
+	// The command cannot smuggle in what looks like other fields
+	forged := JobDefinition{ID: "j", TargetHost: "h", TargetUser: "u", Checksum: "x",
+		Command:     variants["arguments"].ChecksumInput(),
+		Credentials: CredentialBundle{Username: "u", Password: "p"}}
+	if err := forged.Validate(); err == nil || !strings.Contains(err.Error(), "NUL") {
+		t.Errorf("Validate accepted a command containing a NUL byte: %v", err)
+	}
+}
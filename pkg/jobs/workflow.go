@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// StatusWaiting marks workflow nodes whose parents have not finished yet
+	StatusWaiting Status = "waiting"
+	// StatusSkipped marks workflow nodes that never ran because an upstream node failed
+	StatusSkipped Status = "skipped"
+)
+
+// FailurePolicy decides how a workflow reacts when one of its jobs does not succeed
+type FailurePolicy string
+
+const (
+	// FailureSkipDownstream skips every descendant of the failed job; other branches keep going
+	FailureSkipDownstream FailurePolicy = "skip_downstream"
+	// FailureContinue treats a failed parent as finished and still runs its children
+	FailureContinue FailurePolicy = "continue"
+	// FailureAbort cancels everything still pending or running and marks the workflow aborted
+	FailureAbort FailurePolicy = "abort"
+)
+
+// WorkflowState is the aggregate state of a workflow run
+type WorkflowState string
+
+const (
+	WorkflowRunning   WorkflowState = "running"
+	WorkflowSucceeded WorkflowState = "succeeded"
+	WorkflowFailed    WorkflowState = "failed"
+	WorkflowAborted   WorkflowState = "aborted"
+)
+
+// WorkflowDefinition groups jobs connected by depends_on edges into a DAG
+type WorkflowDefinition struct {
+	ID        string        `yaml:"id" json:"id"`
+	OnFailure FailurePolicy `yaml:"on_failure" json:"on_failure"`
+	Jobs      []WorkflowJob `yaml:"jobs" json:"jobs"`
+}
+
+// WorkflowJob is a job definition plus the IDs of the workflow jobs it waits for
+type WorkflowJob struct {
+	JobDefinition `yaml:",inline"`
+	DependsOn     []string `yaml:"depends_on" json:"depends_on,omitempty"`
+}
+
+// WorkflowStatus summarises a workflow run and every node in it
+type WorkflowStatus struct {
+	ID        string               `yaml:"id" json:"id"`
+	State     WorkflowState        `yaml:"state" json:"state"`
+	OnFailure FailurePolicy        `yaml:"on_failure" json:"on_failure"`
+	Nodes     []WorkflowNodeStatus `yaml:"nodes" json:"nodes"`
+}
+
+// WorkflowNodeStatus reports one node: its workflow-local ID, the controller job it spawned and where it stands
+type WorkflowNodeStatus struct {
+	ID        string   `yaml:"id" json:"id"`
+	JobID     string   `yaml:"job_id" json:"job_id,omitempty"`
+	Status    Status   `yaml:"status" json:"status"`
+	DependsOn []string `yaml:"depends_on" json:"depends_on,omitempty"`
+	ExitCode  int      `yaml:"exit_code" json:"exit_code"`
+	Error     string   `yaml:"error" json:"error,omitempty"`
+}
+
+// Validate checks every job, rejects unknown or duplicate IDs and refuses cycles
+func (w WorkflowDefinition) Validate() error {
+	if strings.TrimSpace(w.ID) == "" {
+		return errors.New("workflow id cannot be empty")
+	}
+	if len(w.Jobs) == 0 {
+		return fmt.Errorf("workflow %s has no jobs", w.ID)
+	}
+
+	switch w.OnFailure {
+	case "", FailureSkipDownstream, FailureContinue, FailureAbort:
+	default:
+		return fmt.Errorf("workflow %s has unknown on_failure policy %q", w.ID, w.OnFailure)
+	}
+
+	for _, job := range w.Jobs {
+		// Nodes are enqueued as single jobs; a group would need one node per host to gate on
+		if job.TargetGroup != "" {
+			return fmt.Errorf("workflow %s: job %s: target_group is not supported in workflows; add a job per host", w.ID, job.ID)
+		}
+		// Only a job submitted on its own hands the operator the token to attach to its shell
+		if job.Kind() == JobShell {
+			return fmt.Errorf("workflow %s: job %s: shell jobs must be submitted on their own", w.ID, job.ID)
+		}
+		if err := job.Validate(); err != nil {
+			return fmt.Errorf("workflow %s: %w", w.ID, err)
+		}
+	}
+
+	if _, err := w.TopologicalOrder(); err != nil {
+		return fmt.Errorf("workflow %s: %w", w.ID, err)
+	}
+
+	return nil
+}
+
+// TopologicalOrder returns job IDs so that every job comes after its dependencies.
+// It fails on duplicate IDs, unknown dependencies and cycles
+func (w WorkflowDefinition) TopologicalOrder() ([]string, error) {
+	indegree := make(map[string]int, len(w.Jobs))
+	children := make(map[string][]string, len(w.Jobs))
+
+	for _, job := range w.Jobs {
+		if _, dup := indegree[job.ID]; dup {
+			return nil, fmt.Errorf("duplicate job id %s", job.ID)
+		}
+		indegree[job.ID] = 0
+	}
+
+	for _, job := range w.Jobs {
+		seen := make(map[string]struct{}, len(job.DependsOn))
+		for _, dep := range job.DependsOn {
+			if _, ok := indegree[dep]; !ok {
+				return nil, fmt.Errorf("job %s depends on unknown job %s", job.ID, dep)
+			}
+			if dep == job.ID {
+				return nil, fmt.Errorf("job %s depends on itself", job.ID)
+			}
+			if _, dup := seen[dep]; dup {
+				continue
+			}
+			seen[dep] = struct{}{}
+			indegree[job.ID]++
+			children[dep] = append(children[dep], job.ID)
+		}
+	}
+
+	// Kahn's algorithm; whatever never reaches indegree zero sits on a cycle
+	ready := make([]string, 0, len(w.Jobs))
+	for _, job := range w.Jobs {
+		if indegree[job.ID] == 0 {
+			ready = append(ready, job.ID)
+		}
+	}
+
+	order := make([]string, 0, len(w.Jobs))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+
+		for _, child := range children[id] {
+			indegree[child]--
+			if indegree[child] == 0 {
+				ready = append(ready, child)
+			}
+		}
+	}
+
+	if len(order) != len(w.Jobs) {
+		cyclic := make([]string, 0)
+		for id, deg := range indegree {
+			if deg > 0 {
+				cyclic = append(cyclic, id)
+			}
+		}
+		sort.Strings(cyclic)
+		return nil, fmt.Errorf("dependency cycle involving %s", strings.Join(cyclic, ", "))
+	}
+
+	return order, nil
+}
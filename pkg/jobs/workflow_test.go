@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func workflow(edges map[string][]string, ids ...string) WorkflowDefinition {
+	w := WorkflowDefinition{ID: "wf"}
+	for _, id := range ids {
+		w.Jobs = append(w.Jobs, WorkflowJob{JobDefinition: JobDefinition{ID: id}, DependsOn: edges[id]})
+	}
+	return w
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		ids   []string
+		edges map[string][]string
+		want  []string
+	}{
+		{"independent", []string{"a", "b", "c"}, nil, []string{"a", "b", "c"}},
+		{"chain listed backwards", []string{"c", "b", "a"}, map[string][]string{"c": {"b"}, "b": {"a"}}, []string{"a", "b", "c"}},
+		{"diamond", []string{"d", "b", "c", "a"}, map[string][]string{"b": {"a"}, "c": {"a"}, "d": {"b", "c"}}, []string{"a", "b", "c", "d"}},
+		{"repeated dependency", []string{"a", "b"}, map[string][]string{"b": {"a", "a"}}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		got, err := workflow(tt.edges, tt.ids...).TopologicalOrder()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: order %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTopologicalOrderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		ids   []string
+		edges map[string][]string
+		want  string
+	}{
+		{"duplicate", []string{"a", "a"}, nil, "duplicate job id a"},
+		{"unknown", []string{"a"}, map[string][]string{"a": {"x"}}, "unknown job x"},
+		{"self", []string{"a"}, map[string][]string{"a": {"a"}}, "depends on itself"},
+		{"cycle", []string{"a", "b", "c", "d"}, map[string][]string{"a": {"c"}, "b": {"a"}, "c": {"b"}, "d": {"a"}}, "cycle involving a, b, c, d"},
+	}
+	for _, tt := range tests {
+		_, err := workflow(tt.edges, tt.ids...).TopologicalOrder()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestWorkflowValidateRejectsGroups(t *testing.T) {
+	job := JobDefinition{ID: "deploy", TargetHost: "web01", TargetGroup: "web", TargetUser: "ops", Command: "true",
+		Checksum: "x", Credentials: CredentialBundle{Username: "ops", Password: "p"}}
+	w := WorkflowDefinition{ID: "wf", Jobs: []WorkflowJob{{JobDefinition: job}}}
+	if err := w.Validate(); err == nil || !strings.Contains(err.Error(), "target_group is not supported") {
+		t.Errorf("Validate = %v, want target_group rejected", err)
+	}
+
+	w.Jobs[0].TargetGroup = ""
+	if err := w.Validate(); err != nil {
+		t.Errorf("Validate without the group = %v", err)
+	}
+}
@@ -2,22 +2,38 @@ package transport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Jeremiahtaylor2017/orchestration_engine/pkg/jobs"
 )
 
+// EngineIDHeader identifies the calling engine so the controller can track engine health
+const EngineIDHeader = "X-Engine-ID"
+
+// ShellTokenHeader carries the operator token a shell job's submit returned when attaching to it
+const ShellTokenHeader = "X-Shell-Token"
+
+// DefaultArtifactIdleTimeout aborts an artifact transfer that moved no data for this long
+const DefaultArtifactIdleTimeout = time.Minute
+
 // HTTPTransport polls the controller for pending jobs and reports results back.
 type HTTPTransport struct {
 	BaseURL      string
 	Client       *http.Client
 	PollInterval time.Duration
+	// EngineID is sent with every request; empty leaves the engine anonymous
+	EngineID string
+	// ArtifactIdleTimeout replaces the client timeout for artifact transfers, which may take
+	// arbitrarily long but must keep moving (default DefaultArtifactIdleTimeout)
+	ArtifactIdleTimeout time.Duration
 }
 
 // NextJob continuously polls /v1/queue/next until a job arrives or the caller cancels via stop.
@@ -38,6 +54,8 @@ func (t *HTTPTransport) NextJob(stop <-chan struct{}) (*jobs.JobDefinition, stri
 				return nil, "", err
 			}
 
+			t.identify(req)
+
 			resp, err := client.Do(req)
 			if err != nil {
 				time.Sleep(t.sleepInterval())
@@ -91,6 +109,7 @@ func (t *HTTPTransport) WriteResult(jobID string, result jobs.Result) error {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	t.identify(req)
 
 	resp, err := t.httpClient().Do(req)
 	if err != nil {
@@ -109,6 +128,230 @@ func (t *HTTPTransport) WriteResult(jobID string, result jobs.Result) error {
 	return nil
 }
 
+// AppendLogs streams output chunks for a running job to /v1/jobs/{id}/logs.
+// It also serves as a heartbeat: the returned flag is true once a user asked to cancel the job
+func (t *HTTPTransport) AppendLogs(jobID string, chunks []jobs.LogChunk) (bool, error) {
+	if chunks == nil {
+		chunks = []jobs.LogChunk{}
+	}
+
+	payload, err := json.Marshal(chunks)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		fmt.Sprintf("%s/v1/jobs/%s/logs", t.BaseURL, jobID),
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	t.identify(req)
+
+	resp, err := t.httpClient().Do(req)
+	if err != nil {
+		return false, err
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readErr != nil {
+		return false, readErr
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("controller rejected logs (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var reply struct {
+		Cancel bool `json:"cancel"`
+	}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return false, err
+	}
+
+	return reply.Cancel, nil
+}
+
+// UploadArtifact stores a file for the job on the controller via /v1/jobs/{id}/artifacts/{name}
+func (t *HTTPTransport) UploadArtifact(ctx context.Context, jobID, name string, body io.Reader) (jobs.Artifact, error) {
+	ctx, idle := t.idleDeadline(ctx)
+	defer idle.stop()
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPut,
+		fmt.Sprintf("%s/v1/jobs/%s/artifacts/%s", t.BaseURL, jobID, name),
+		idle.reader(body),
+	)
+	if err != nil {
+		return jobs.Artifact{}, err
+	}
+	contentType := "application/octet-stream"
+	if strings.HasSuffix(name, ".gz") {
+		contentType = "application/gzip"
+	}
+	req.Header.Set("Content-Type", contentType)
+	t.identify(req)
+
+	// Artifacts can be large, so the idle deadline replaces the per-request timeout of t.Client
+	client := *t.httpClient()
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return jobs.Artifact{}, idle.err(err)
+	}
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readErr != nil {
+		return jobs.Artifact{}, readErr
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return jobs.Artifact{}, fmt.Errorf("controller rejected artifact (%d): %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var artifact jobs.Artifact
+	if err := json.Unmarshal(respBody, &artifact); err != nil {
+		return jobs.Artifact{}, err
+	}
+
+	return artifact, nil
+}
+
+// DownloadArtifact opens a job artifact stored on the controller, such as an upload payload.
+// The returned artifact carries the size and digest the controller recorded. The download is
+// aborted when ctx ends or the caller stops reading for longer than the idle timeout
+func (t *HTTPTransport) DownloadArtifact(ctx context.Context, jobID, name string) (io.ReadCloser, jobs.Artifact, error) {
+	ctx, idle := t.idleDeadline(ctx)
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("%s/v1/jobs/%s/artifacts/%s", t.BaseURL, jobID, name),
+		nil,
+	)
+	if err != nil {
+		idle.stop()
+		return nil, jobs.Artifact{}, err
+	}
+	t.identify(req)
+
+	client := *t.httpClient()
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		idle.stop()
+		return nil, jobs.Artifact{}, idle.err(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		idle.stop()
+		return nil, jobs.Artifact{}, fmt.Errorf("controller returned %d for artifact %s: %s", resp.StatusCode, name, strings.TrimSpace(string(body)))
+	}
+
+	return idleBody{Reader: idle.reader(resp.Body), body: resp.Body, idle: idle}, jobs.Artifact{
+		Name:        name,
+		Size:        resp.ContentLength,
+		SHA256:      resp.Header.Get("X-Checksum-Sha256"),
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+// AttachShell opens the engine's end of a shell job's relay at /v1/jobs/{id}/shell/engine
+func (t *HTTPTransport) AttachShell(ctx context.Context, jobID string) (*WSConn, error) {
+	header := http.Header{}
+	if t.EngineID != "" {
+		header.Set(EngineIDHeader, t.EngineID)
+	}
+
+	return DialWebSocket(ctx, t.httpClient(), fmt.Sprintf("%s/v1/jobs/%s/shell/engine", t.BaseURL, jobID), header)
+}
+
+// idleTimer cancels an artifact transfer's context once no data moved for the idle timeout
+type idleTimer struct {
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+	expired chan struct{} // closed when the timer first fired
+	once    sync.Once
+}
+
+func (t *HTTPTransport) idleDeadline(ctx context.Context) (context.Context, *idleTimer) {
+	timeout := t.ArtifactIdleTimeout
+	if timeout <= 0 {
+		timeout = DefaultArtifactIdleTimeout
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	idle := &idleTimer{timeout: timeout, cancel: cancel, expired: make(chan struct{})}
+	idle.timer = time.AfterFunc(timeout, func() {
+		idle.once.Do(func() { close(idle.expired) })
+		cancel()
+	})
+
+	return ctx, idle
+}
+
+// reader resets the deadline whenever r returns data
+func (i *idleTimer) reader(r io.Reader) io.Reader {
+	return readFunc(func(p []byte) (int, error) {
+		n, err := r.Read(p)
+		if n > 0 {
+			i.timer.Reset(i.timeout)
+		}
+		return n, err
+	})
+}
+
+func (i *idleTimer) stop() {
+	i.timer.Stop()
+	i.cancel()
+}
+
+// err names the idle timeout as the cause of a transfer it cancelled
+func (i *idleTimer) err(err error) error {
+	select {
+	case <-i.expired:
+		return fmt.Errorf("artifact transfer idle for %s: %w", i.timeout, err)
+	default:
+		return err
+	}
+}
+
+type readFunc func(p []byte) (int, error)
+
+func (f readFunc) Read(p []byte) (int, error) { return f(p) }
+
+// idleBody is a downloaded artifact whose idle deadline ends when it is closed
+type idleBody struct {
+	io.Reader
+	body io.Closer
+	idle *idleTimer
+}
+
+func (b idleBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.idle.err(err)
+	}
+	return n, err
+}
+
+func (b idleBody) Close() error {
+	b.idle.stop()
+	return b.body.Close()
+}
+
+func (t *HTTPTransport) identify(req *http.Request) {
+	if t.EngineID != "" {
+		req.Header.Set(EngineIDHeader, t.EngineID)
+	}
+}
+
 func (t *HTTPTransport) sleepInterval() time.Duration {
 	if t.PollInterval <= 0 {
 		return 2 * time.Second
@@ -0,0 +1,336 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes carried by WSConn messages
+const (
+	WSText   = 1
+	WSBinary = 2
+
+	wsContinuation = 0
+	wsClose        = 8
+	wsPing         = 9
+	wsPong         = 10
+)
+
+// wsGUID is the fixed suffix RFC 6455 hashes into Sec-WebSocket-Accept
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxWSMessage bounds one reassembled message so a peer cannot grow our memory
+const MaxWSMessage = 1 << 20
+
+// wsMaxControl is the largest payload a control frame may carry
+const wsMaxControl = 125
+
+// wsCloseWait is how long Close waits for the peer to answer the closing handshake
+const wsCloseWait = 5 * time.Second
+
+// WSConn is a minimal RFC 6455 WebSocket: whole messages in both directions, automatic pongs
+// and the closing handshake. Reads must come from one goroutine; writes may come from any
+type WSConn struct {
+	rw     io.ReadWriteCloser
+	br     *bufio.Reader
+	client bool // clients mask what they send
+
+	wmu    sync.Mutex
+	closed bool
+	// peerClosed closes once the peer's close frame was read
+	peerClosed chan struct{}
+	closeOnce  sync.Once
+}
+
+func newWSConn(rw io.ReadWriteCloser, br *bufio.Reader, client bool) *WSConn {
+	return &WSConn{rw: rw, br: br, client: client, peerClosed: make(chan struct{})}
+}
+
+// UpgradeWebSocket answers a WebSocket handshake and takes over the connection
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WSConn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("not a websocket request")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("unsupported websocket version")
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin websocket request", http.StatusForbidden)
+		return nil, fmt.Errorf("cross-origin websocket request from %s", r.Header.Get("Origin"))
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return nil, fmt.Errorf("hijack: %w", err)
+	}
+	// The handshake has no deadline of its own; clear any the server set for plain requests
+	conn.SetDeadline(time.Time{})
+
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAccept(key))
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("write handshake: %w", err)
+	}
+
+	return newWSConn(conn, brw.Reader, false), nil
+}
+
+// DialWebSocket opens a WebSocket to an http:// or https:// URL through client's transport,
+// so proxies and TLS settings apply. client's overall Timeout does not, since the connection is long-lived
+func DialWebSocket(ctx context.Context, client *http.Client, url string, header http.Header) (*WSConn, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	streaming := &http.Client{}
+	if client != nil {
+		streaming.Transport = client.Transport
+	}
+	resp, err := streaming.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("controller returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		resp.Body.Close()
+		return nil, errors.New("websocket handshake: bad Sec-WebSocket-Accept")
+	}
+
+	// Since Go 1.12 the body of a 101 response is the raw, writable connection
+	rw, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, errors.New("websocket handshake: connection is not writable")
+	}
+
+	return newWSConn(rw, bufio.NewReader(rw), true), nil
+}
+
+// ReadMessage returns the next text or binary message, answering pings on the way. It returns
+// io.EOF once the peer closed the connection
+func (c *WSConn) ReadMessage() (int, []byte, error) {
+	var op int
+	var msg []byte
+	for {
+		fin, frameOp, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frameOp {
+		case wsPing:
+			c.writeFrame(wsPong, payload)
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.closeOnce.Do(func() { close(c.peerClosed) })
+			// Echo the close so the peer's closing handshake completes
+			c.writeFrame(wsClose, nil)
+			return 0, nil, io.EOF
+		case wsContinuation:
+			if op == 0 {
+				return 0, nil, errors.New("websocket: continuation without a message")
+			}
+		case WSText, WSBinary:
+			if op != 0 {
+				return 0, nil, errors.New("websocket: new message inside a fragmented one")
+			}
+			op = frameOp
+		default:
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %d", frameOp)
+		}
+
+		if len(msg)+len(payload) > MaxWSMessage {
+			return 0, nil, errors.New("websocket: message too large")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+func (c *WSConn) readFrame() (fin bool, op int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = head[0]&0x80 != 0, int(head[0]&0x0f)
+	masked := head[1]&0x80 != 0
+	// Clients must mask every frame and servers must not (RFC 6455 section 5.1)
+	if masked == c.client {
+		if c.client {
+			return false, 0, nil, errors.New("websocket: masked server frame")
+		}
+		return false, 0, nil, errors.New("websocket: unmasked client frame")
+	}
+
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if size > MaxWSMessage {
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+	// Control frames cannot be fragmented and carry at most 125 bytes (section 5.5)
+	if op >= wsClose && (!fin || size > wsMaxControl) {
+		return false, 0, nil, fmt.Errorf("websocket: invalid control frame (opcode %d, %d bytes)", op, size)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, op, payload, nil
+}
+
+// WriteMessage sends one text or binary message
+func (c *WSConn) WriteMessage(op int, data []byte) error {
+	return c.writeFrame(op, data)
+}
+
+func (c *WSConn) writeFrame(op int, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closed {
+		return errors.New("websocket: connection closed")
+	}
+	if op == wsClose {
+		c.closed = true
+	}
+
+	frame := make([]byte, 0, len(data)+14)
+	frame = append(frame, 0x80|byte(op))
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(data); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if !c.client {
+		frame = append(frame, data...)
+	} else {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		for i, b := range data {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+
+	_, err := c.rw.Write(frame)
+	return err
+}
+
+// Close starts the closing handshake, waits briefly for the peer's answer and closes the
+// connection. A reader still running sees io.EOF when the answer arrives
+func (c *WSConn) Close() error {
+	if err := c.writeFrame(wsClose, nil); err == nil {
+		select {
+		case <-c.peerClosed:
+		case <-time.After(wsCloseWait):
+		}
+	}
+
+	return c.rw.Close()
+}
+
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sameOrigin reports whether a browser's Origin header, if any, names the host the request was
+// sent to. Browsers attach cookies and credentials to cross-site WebSockets, so those are refused;
+// orchcli and engines send no Origin
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// headerContains reports whether a comma-separated header lists token, ignoring case
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// wsWire feeds a WSConn canned input and collects what it writes
+type wsWire struct {
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func (w *wsWire) Read(p []byte) (int, error)  { return w.in.Read(p) }
+func (w *wsWire) Write(p []byte) (int, error) { return w.out.Write(p) }
+func (w *wsWire) Close() error                { return nil }
+
+// wsPeer returns a connection reading in, and the wire it writes to
+func wsPeer(client bool, in []byte) (*WSConn, *wsWire) {
+	w := &wsWire{in: bytes.NewReader(in)}
+	return newWSConn(w, bufio.NewReader(w), client), w
+}
+
+// rawFrame encodes one frame, masked with a fixed key when mask is set
+func rawFrame(fin bool, op int, payload []byte, mask bool) []byte {
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	maskBit := byte(0)
+	if mask {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if !mask {
+		return append(frame, payload...)
+	}
+
+	key := [4]byte{1, 2, 3, 4}
+	frame = append(frame, key[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+	return frame
+}
+
+func TestWSRoundTrip(t *testing.T) {
+	sizes := []int{0, 1, 125, 126, 0xffff, 0x10000}
+	for _, fromClient := range []bool{true, false} {
+		for _, op := range []int{WSText, WSBinary} {
+			for _, size := range sizes {
+				data := bytes.Repeat([]byte{'x'}, size)
+				sender, wire := wsPeer(fromClient, nil)
+				if err := sender.WriteMessage(op, data); err != nil {
+					t.Fatalf("client=%v op=%d size=%d: write: %v", fromClient, op, size, err)
+				}
+
+				receiver, _ := wsPeer(!fromClient, wire.out.Bytes())
+				gotOp, got, err := receiver.ReadMessage()
+				if err != nil || gotOp != op || !bytes.Equal(got, data) {
+					t.Errorf("client=%v op=%d size=%d: read op %d, %d bytes, %v", fromClient, op, size, gotOp, len(got), err)
+				}
+			}
+		}
+	}
+}
+
+func TestWSReadMessage(t *testing.T) {
+	var fragmented []byte
+	fragmented = append(fragmented, rawFrame(false, WSText, []byte("hel"), true)...)
+	fragmented = append(fragmented, rawFrame(true, wsPing, []byte("p"), true)...)
+	fragmented = append(fragmented, rawFrame(true, wsContinuation, []byte("lo"), true)...)
+
+	// A ping inside a fragmented message is answered and the message reassembled
+	server, wire := wsPeer(false, fragmented)
+	op, msg, err := server.ReadMessage()
+	if err != nil || op != WSText || string(msg) != "hello" {
+		t.Fatalf("ReadMessage = %d, %q, %v; want the reassembled text", op, msg, err)
+	}
+	client, _ := wsPeer(true, wire.out.Bytes())
+	if _, op, payload, err := client.readFrame(); err != nil || op != wsPong || string(payload) != "p" {
+		t.Errorf("ping answered with opcode %d %q, %v; want a pong echoing it", op, payload, err)
+	}
+
+	// A close frame ends the stream and is echoed
+	server, wire = wsPeer(false, rawFrame(true, wsClose, nil, true))
+	if _, _, err := server.ReadMessage(); err != io.EOF {
+		t.Errorf("ReadMessage after close = %v, want io.EOF", err)
+	}
+	if got := wire.out.Bytes(); len(got) != 2 || got[0] != 0x80|wsClose {
+		t.Errorf("close echoed as % x", got)
+	}
+}
+
+func TestWSRejectsInvalidFrames(t *testing.T) {
+	tests := []struct {
+		name   string
+		client bool // which end reads the frame
+		frame  []byte
+		want   string
+	}{
+		{"unmasked client frame", false, rawFrame(true, WSText, []byte("hi"), false), "unmasked client frame"},
+		{"masked server frame", true, rawFrame(true, WSText, []byte("hi"), true), "masked server frame"},
+		{"oversized ping", false, rawFrame(true, wsPing, make([]byte, 126), true), "invalid control frame"},
+		{"oversized close", true, rawFrame(true, wsClose, make([]byte, 200), false), "invalid control frame"},
+		{"fragmented ping", false, rawFrame(false, wsPing, []byte("p"), true), "invalid control frame"},
+		{"fragmented close", true, rawFrame(false, wsClose, nil, false), "invalid control frame"},
+		{"orphan continuation", false, rawFrame(true, wsContinuation, []byte("x"), true), "continuation without a message"},
+		{"unknown opcode", false, rawFrame(true, 3, nil, true), "unknown opcode"},
+	}
+	for _, tt := range tests {
+		conn, wire := wsPeer(tt.client, tt.frame)
+		_, _, err := conn.ReadMessage()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ReadMessage error = %v, want %q", tt.name, err, tt.want)
+		}
+		if wire.out.Len() != 0 {
+			t.Errorf("%s: answered an invalid frame with % x", tt.name, wire.out.Bytes())
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://controller.example:8080", true},
+		{"https://CONTROLLER.example:8080", true},
+		{"http://evil.example", false},
+		{"http://controller.example", false},
+		{"null", false},
+		{"::", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://controller.example:8080/v1/jobs/j/shell", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := sameOrigin(r); got != tt.want {
+			t.Errorf("sameOrigin(Origin %q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}